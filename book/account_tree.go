@@ -0,0 +1,330 @@
+package book
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Account in an account tree, with the postings to it and its sub-accounts
+type AccountNode struct {
+	Account  string // Full account name ("" for the root)
+	Name     string // Last component of the account name
+	Depth    int    // Number of components (0 for the root)
+	Parent   *AccountNode
+	Children []*AccountNode
+
+	Balance      map[string]*big.Rat // Balance of the account itself by currency
+	Total        map[string]*big.Rat // Balance including sub-accounts by currency
+	Value        *big.Rat            // Total converted (nil if no currency for the tree)
+	Postings     int                 // Postings to the account itself
+	Transactions int                 // Transactions with postings to the account or sub-accounts
+	First        Date                // Date of the first of the transactions
+	Last         Date                // Date of the last of the transactions
+
+	lastTrans int // index of the last transaction counted
+}
+
+// Tree of the accounts of a book split by a divider
+type AccountTree struct {
+	Root    *AccountNode
+	divider string
+	toCCY   string
+	nodes   map[string]*AccountNode
+}
+
+// Return the tree of the accounts of the book, split into components by divider.
+//
+// Each node has the balances and activity of the account and its sub-accounts,
+// and if toCCY is not empty the total value converted to toCCY (each posting at
+// the price of its date). The children are sorted by name.
+func (b *Book) AccountTree(divider string, toCCY string) *AccountTree {
+	t := &AccountTree{
+		Root:    newAccountNode("", "", 0, nil),
+		divider: divider,
+		toCCY:   toCCY,
+		nodes:   make(map[string]*AccountNode),
+	}
+	if toCCY != "" {
+		t.Root.Value = new(big.Rat)
+	}
+
+	for ti, trans := range b.Transactions() {
+		for _, p := range trans {
+			n := t.node(p.acct)
+			addToBalance(n.Balance, p.ccy, p.val)
+			n.Postings++
+
+			var val *big.Rat
+			if toCCY != "" {
+				val = p.val
+				if p.ccy != toCCY {
+					r, _ := b.GetPrice(p.date, p.ccy, toCCY)
+					val = new(big.Rat).Mul(p.val, r)
+				}
+			}
+
+			for ; n != nil; n = n.Parent {
+				addToBalance(n.Total, p.ccy, p.val)
+				if val != nil {
+					n.Value.Add(n.Value, val)
+				}
+				if n.lastTrans != ti {
+					n.lastTrans = ti
+					n.Transactions++
+					if n.First == 0 {
+						n.First = p.date
+					}
+					n.Last = p.date
+				}
+			}
+		}
+	}
+
+	t.SortBy(func(a, b *AccountNode) bool {
+		return a.Account < b.Account
+	})
+
+	return t
+}
+
+func newAccountNode(acct string, name string, depth int, parent *AccountNode) *AccountNode {
+	return &AccountNode{
+		Account:   acct,
+		Name:      name,
+		Depth:     depth,
+		Parent:    parent,
+		Children:  make([]*AccountNode, 0),
+		Balance:   make(map[string]*big.Rat),
+		Total:     make(map[string]*big.Rat),
+		lastTrans: -1,
+	}
+}
+
+func addToBalance(bals map[string]*big.Rat, ccy string, amt *big.Rat) {
+	v, ok := bals[ccy]
+	if !ok {
+		v = new(big.Rat)
+		bals[ccy] = v
+	}
+	v.Add(v, amt)
+}
+
+// Return the node for the account, adding it and its parents if needed
+func (t *AccountTree) node(acct string) *AccountNode {
+	if n, ok := t.nodes[acct]; ok {
+		return n
+	}
+
+	parent, name := t.Root, acct
+	if idx := strings.LastIndex(acct, t.divider); idx >= 0 && t.divider != "" {
+		parent, name = t.node(acct[:idx]), acct[idx+len(t.divider):]
+	}
+
+	n := newAccountNode(acct, name, parent.Depth+1, parent)
+	if t.toCCY != "" {
+		n.Value = new(big.Rat)
+	}
+	parent.Children = append(parent.Children, n)
+	t.nodes[acct] = n
+	return n
+}
+
+// Find the node of the account, or nil if it has no postings or sub-accounts
+func (t *AccountTree) Find(acct string) *AccountNode {
+	return t.nodes[acct]
+}
+
+// Sort the children of every node
+func (t *AccountTree) SortBy(less func(a, b *AccountNode) bool) {
+	t.Root.Walk(func(n *AccountNode) bool {
+		sort.SliceStable(n.Children, func(i, j int) bool {
+			return less(n.Children[i], n.Children[j])
+		})
+		return true
+	})
+}
+
+// Sort the children of every node by value, largest first, as accumulated
+// transactions are sorted (see Accumulate)
+func (t *AccountTree) SortByValue() {
+	t.SortBy(func(a, b *AccountNode) bool {
+		if a.Value == nil || b.Value == nil {
+			return false
+		}
+		return a.Value.Cmp(b.Value) > 0
+	})
+}
+
+// Return all of the nodes, except the root, depth-first in the order of the children
+func (t *AccountTree) Nodes() []*AccountNode {
+	nodes := make([]*AccountNode, 0, len(t.nodes))
+	t.Root.Walk(func(n *AccountNode) bool {
+		if n != t.Root {
+			nodes = append(nodes, n)
+		}
+		return true
+	})
+	return nodes
+}
+
+// Remove the nodes that are not kept and have no kept sub-accounts
+func (t *AccountTree) Prune(keep func(n *AccountNode) bool) {
+	var prune func(n *AccountNode) bool
+	prune = func(n *AccountNode) bool {
+		children := make([]*AccountNode, 0, len(n.Children))
+		for _, c := range n.Children {
+			if prune(c) {
+				children = append(children, c)
+			} else {
+				delete(t.nodes, c.Account)
+			}
+		}
+		n.Children = children
+		return len(children) > 0 || (n != t.Root && keep(n))
+	}
+	prune(t.Root)
+}
+
+func (t *AccountTree) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.Root.Children)
+}
+
+// Walk the node and its sub-accounts depth-first, skipping the sub-accounts
+// of a node if f returns false
+func (n *AccountNode) Walk(f func(n *AccountNode) bool) {
+	if !f(n) {
+		return
+	}
+	for _, c := range n.Children {
+		c.Walk(f)
+	}
+}
+
+// Check if the account has no sub-accounts
+func (n *AccountNode) IsLeaf() bool {
+	return len(n.Children) == 0
+}
+
+// Check if the account itself has a non-zero balance in any currency
+func (n *AccountNode) HasBalance() bool {
+	for _, v := range n.Balance {
+		if v.Sign() != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Return the currencies of the account's own postings, sorted
+func (n *AccountNode) CCYs() []string {
+	ccys := make([]string, 0, len(n.Balance))
+	for ccy := range n.Balance {
+		ccys = append(ccys, ccy)
+	}
+	sort.Strings(ccys)
+	return ccys
+}
+
+// Check if the account is summarised in accumulated transactions: it has postings
+// and sub-accounts, several currencies, or a currency other than toCCY
+func (n *AccountNode) isSummary(toCCY string) bool {
+	if n.Parent == nil {
+		return false
+	}
+	if len(n.Balance)+len(n.Children) > 1 {
+		return true
+	}
+	for ccy := range n.Balance {
+		if ccy != toCCY {
+			return true
+		}
+	}
+	return false
+}
+
+// Return the level and term of the account in accumulated transactions: the number
+// of summarised parents (and the account itself if includeSelf) and the name after
+// the nearest of them (empty for the account itself)
+func (t *AccountTree) summaryLevel(acct string, accum map[string]bool, includeSelf bool) (int, string) {
+	n, ok := t.nodes[acct]
+	if !ok {
+		return 0, acct
+	}
+	level := 0
+	var nearest *AccountNode
+	for p := n.Parent; p != t.Root; p = p.Parent {
+		if accum[p.Account] {
+			level++
+			if nearest == nil {
+				nearest = p
+			}
+		}
+	}
+
+	if includeSelf && accum[acct] {
+		return level + 1, ""
+	} else if nearest == nil {
+		return level, acct
+	}
+	return level, acct[len(nearest.Account)+len(t.divider):]
+}
+
+// Warn if the account and its sub-accounts have postings in the same currency
+func warnBothBalances(n *AccountNode) {
+	for _, ccy := range n.CCYs() {
+		for _, c := range n.Children {
+			if _, ok := c.Total[ccy]; ok {
+				fmt.Fprintf(os.Stderr, "WARNING: account '%s' (%s) and '%s' (%s) both have balances\n", n.Account, ccy, c.Account, ccy)
+				break
+			}
+		}
+	}
+}
+
+func (n *AccountNode) MarshalJSON() ([]byte, error) {
+
+	type JsonAccountNode struct {
+		Account      string             `json:"account"`
+		Name         string             `json:"name"`
+		Depth        int                `json:"depth"`
+		Balance      map[string]float64 `json:"balance,omitempty"`
+		Total        map[string]float64 `json:"total"`
+		Value        *float64           `json:"value,omitempty"`
+		Postings     int                `json:"postings"`
+		Transactions int                `json:"transactions"`
+		First        Date               `json:"first"`
+		Last         Date               `json:"last"`
+		Children     []*AccountNode     `json:"children,omitempty"`
+	}
+
+	floats := func(bals map[string]*big.Rat) map[string]float64 {
+		f := make(map[string]float64, len(bals))
+		for ccy, v := range bals {
+			f[ccy], _ = v.Float64()
+		}
+		return f
+	}
+
+	jn := &JsonAccountNode{
+		Account:      n.Account,
+		Name:         n.Name,
+		Depth:        n.Depth,
+		Balance:      floats(n.Balance),
+		Total:        floats(n.Total),
+		Postings:     n.Postings,
+		Transactions: n.Transactions,
+		First:        n.First,
+		Last:         n.Last,
+		Children:     n.Children,
+	}
+	if n.Value != nil {
+		v, _ := n.Value.Float64()
+		jn.Value = &v
+	}
+
+	return json.Marshal(jn)
+}
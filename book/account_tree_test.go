@@ -0,0 +1,89 @@
+package book
+
+import (
+	"encoding/json"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func TestAccountTree(t *testing.T) {
+	book := GetBook([]QuickBook{
+		{"2020-01-01", "Opening", []QuickPosting{
+			{"Asset:Bank", "GBP", 1000},
+			{"Equity:Opening", "GBP", -1000},
+		}},
+		{"2020-01-15", "Shop", []QuickPosting{
+			{"Expense:Food", "GBP", 30},
+			{"Expense:Food:Snacks", "GBP", 5},
+			{"Expense:Travel", "GBP", 200},
+			{"Asset:Bank", "GBP", -235},
+		}},
+		{"2020-02-10", "Holiday", []QuickPosting{
+			{"Expense:Travel", "USD", 100},
+			{"Asset:Bank", "GBP", -80},
+			{"Equity:Exchange", "USD", -100},
+			{"Equity:Exchange", "GBP", 80},
+		}},
+	}, []QuickPrice{{"USD", "GBP", 2}})
+
+	tree := book.AccountTree(":", "GBP")
+
+	accts := make([]string, 0)
+	for _, n := range tree.Nodes() {
+		accts = append(accts, strings.Repeat(" ", n.Depth-1)+n.Name)
+	}
+	if got := strings.Join(accts, ","); got != "Asset, Bank,Equity, Exchange, Opening,Expense, Food,  Snacks, Travel" {
+		t.Errorf("unexpected tree %s", got)
+	}
+
+	exp := tree.Find("Expense")
+	if exp.Transactions != 2 || exp.First != 20200115 || exp.Last != 20200210 {
+		t.Errorf("expected 2 transactions from 20200115 to 20200210, got %d from %d to %d", exp.Transactions, exp.First, exp.Last)
+	}
+	if exp.Total["GBP"].Cmp(big.NewRat(235, 1)) != 0 || exp.Total["USD"].Cmp(big.NewRat(100, 1)) != 0 {
+		t.Errorf("unexpected total %v", exp.Total)
+	}
+	if len(exp.Balance) != 0 || exp.Value.Cmp(big.NewRat(435, 1)) != 0 {
+		t.Errorf("expected no balance and value 435, got %v and %s", exp.Balance, exp.Value.FloatString(2))
+	}
+
+	food := tree.Find("Expense:Food")
+	if food.Postings != 1 || food.Balance["GBP"].Cmp(big.NewRat(30, 1)) != 0 || food.Total["GBP"].Cmp(big.NewRat(35, 1)) != 0 {
+		t.Errorf("expected own balance 30 and total 35, got %v and %v", food.Balance, food.Total)
+	}
+	if food.Children[0].Parent != food || food.Parent != exp || exp.Parent != tree.Root {
+		t.Errorf("unexpected parents")
+	}
+
+	// Largest value first
+	tree.SortByValue()
+	if exp.Children[0].Name != "Travel" || tree.Root.Children[0].Name != "Asset" {
+		t.Errorf("expected Travel and Asset first, got %s and %s", exp.Children[0].Name, tree.Root.Children[0].Name)
+	}
+
+	tree.Prune(func(n *AccountNode) bool {
+		return strings.HasPrefix(n.Account, "Expense:Food")
+	})
+	if nodes := tree.Nodes(); len(nodes) != 3 || tree.Find("Asset:Bank") != nil {
+		t.Errorf("expected Expense, Food and Snacks after pruning, got %d nodes", len(nodes))
+	}
+
+	data, err := json.Marshal(tree)
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
+	}
+	var nested []struct {
+		Account  string
+		Children []struct {
+			Account string
+			Total   map[string]float64
+		}
+	}
+	if err := json.Unmarshal(data, &nested); err != nil {
+		t.Fatalf("failed to unmarshal: %v", err)
+	}
+	if len(nested) != 1 || nested[0].Children[0].Account != "Expense:Food" || nested[0].Children[0].Total["GBP"] != 35 {
+		t.Errorf("unexpected json %s", data)
+	}
+}
@@ -0,0 +1,133 @@
+package book
+
+import (
+	"fmt"
+	"math/big"
+	"math/rand"
+	"regexp"
+	"testing"
+)
+
+// Benchmarks of loading, reporting and registers for large books
+//
+//	go test -run XXX -bench . -benchtime 3x ./book
+//
+// The target is for report and register, which apply ten operations, to be at
+// least 3x faster at 1M postings than compacting (sorting by strings) after every
+// operation, which took about 16s for each. Loading is bound by the arithmetic
+// of the amounts and balances.
+
+// Sizes of the benchmark books in postings
+var benchSizes = []int{100_000, 1_000_000}
+
+// Postings of a synthetic ledger: about three postings a transaction over ten
+// years, with 200 expense accounts, 500 payees and an account in USD
+type benchPosting struct {
+	date  Date
+	payee string
+	acct  string
+	ccy   string
+	amt   int64
+}
+
+func getBenchPostings(n int) []benchPosting {
+	r := rand.New(rand.NewSource(1))
+	payees := make([]string, 500)
+	for i := range payees {
+		payees[i] = fmt.Sprintf("Payee %d", i)
+	}
+	expenses := make([]string, 200)
+	for i := range expenses {
+		expenses[i] = fmt.Sprintf("Expense:Category%d:Item%d", i/10, i%10)
+	}
+	banks := []string{"Asset:Bank:Current", "Asset:Bank:Savings", "Liability:Card"}
+
+	posts := make([]benchPosting, 0, n)
+	start := GetDate(2010, 1, 1)
+	for len(posts) < n {
+		date := start.AddDays(r.Intn(3650))
+		payee := payees[r.Intn(len(payees))]
+		bank := banks[r.Intn(len(banks))]
+		if r.Intn(20) == 0 {
+			amt := int64(r.Intn(10000) + 1)
+			posts = append(posts,
+				benchPosting{date, payee, "Asset:Broker", "USD", amt},
+				benchPosting{date, payee, "Equity:Exchange", "USD", -amt},
+				benchPosting{date, payee, "Equity:Exchange", "GBP", amt * 8 / 10},
+				benchPosting{date, payee, bank, "GBP", -amt * 8 / 10})
+			continue
+		}
+		a1, a2 := int64(r.Intn(10000)+1), int64(r.Intn(10000)+1)
+		posts = append(posts,
+			benchPosting{date, payee, expenses[r.Intn(len(expenses))], "GBP", a1},
+			benchPosting{date, payee, expenses[r.Intn(len(expenses))], "GBP", a2},
+			benchPosting{date, payee, bank, "GBP", -a1 - a2})
+	}
+	return posts
+}
+
+// Build the book from the postings, as the loader does
+func buildBenchBook(posts []benchPosting) *Book {
+	b := NewBookBuilder()
+	for i, p := range posts {
+		if i == 0 || p.date != posts[i-1].date || p.payee != posts[i-1].payee {
+			b.NewTransaction(p.date, p.payee, "")
+		}
+		b.AddPosting(p.acct, p.ccy, big.NewRat(p.amt, 100), "")
+	}
+	b.AddPrice(GetDate(2010, 1, 1), "USD", "GBP", big.NewRat(8, 10))
+	b.AddPrice(GetDate(2020, 1, 1), "USD", "GBP", big.NewRat(7, 10))
+	return b.Build()
+}
+
+// Ten operations, as a macro of a report would apply
+func applyBenchOps(b *Book) {
+	for i := 0; i < 8; i++ {
+		b.RegexAccounts(fmt.Sprintf("^Expense:Category%d:", i), fmt.Sprintf("Expense:Group%d:", i%3), "")
+	}
+	b.RegexAccounts("^Liability:Card$", "Liability:CreditCard", "")
+	b.FilterByDateSince(GetDate(2012, 1, 1))
+}
+
+func BenchmarkLoad(b *testing.B) {
+	for _, n := range benchSizes {
+		posts := getBenchPostings(n)
+		b.Run(fmt.Sprintf("%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				buildBenchBook(posts)
+			}
+		})
+	}
+}
+
+func BenchmarkReport(b *testing.B) {
+	for _, n := range benchSizes {
+		bk := buildBenchBook(getBenchPostings(n))
+		b.Run(fmt.Sprintf("%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				d := bk.Duplicate()
+				applyBenchOps(d)
+				d.SplitBy("yearly")
+				d.MapAmount(func(date Date, ccy string) (*big.Rat, string) {
+					rate, _ := d.GetPrice(date, ccy, "GBP")
+					return rate, "GBP"
+				})
+				d.Accumulate("GBP", ":", nil, "")
+			}
+		})
+	}
+}
+
+func BenchmarkRegister(b *testing.B) {
+	re := regexp.MustCompile("^Asset:Bank:Current$")
+	for _, n := range benchSizes {
+		bk := buildBenchBook(getBenchPostings(n))
+		b.Run(fmt.Sprintf("%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				d := bk.Duplicate()
+				applyBenchOps(d)
+				d.ExtractRegister("GBP", re, false, false)
+			}
+		})
+	}
+}
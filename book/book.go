@@ -5,19 +5,33 @@ import (
 	"math/big"
 	"regexp"
 	"sort"
+	"sync"
 )
 
 /* Book of dated transactions (set of postings), currencies (units), and conversions
+
+Amounts and balances of postings, and prices, are never modified once in a book:
+transformations replace them with new values. A book that is only read (eg with
+Transactions, GetPrice, Accumulate or ExtractRegister) can be shared by goroutines,
+and each goroutine can transform its own Duplicate of it. Transformations are
+applied lazily, so the first read compacts the book under a lock and later reads
+don't modify it.
  */
 type Book struct {
-	post   []Posting
-	trans  []Transaction
-	prices *priceBook
-	ccy    map[string]int
+	post      []Posting
+	trans     []Transaction
+	prices    *priceBook
+	ccy       map[string]int
+	names     *nameTable // interned accounts and currencies of the postings
+	compacted bool       // postings are sorted, combined and indexed into trans
+	indexed   int        // postings before any appended since compacted
+	balanced  bool       // balances of the compacted postings are calculated
+	sources   bool       // postings of different sources or cleared status aren't combined
+	mu        sync.Mutex // guards compacting and balances when read concurrently
 }
 
 func (b *Book) Transactions() []Transaction {
-	b.compact()
+	b.ensureBalances()
 	return b.trans
 }
 
@@ -36,6 +50,13 @@ func (b *Book) GetPricePairs() []PricePair {
 		pairs = append(pairs, pair)
 	}
 
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].Unit != pairs[j].Unit {
+			return pairs[i].Unit < pairs[j].Unit
+		}
+		return pairs[i].CCY < pairs[j].CCY
+	})
+
 	return pairs
 }
 
@@ -43,12 +64,35 @@ func (b *Book) GetPriceList(unit string, ccy string) PriceList {
 	return b.prices.getPrices(unit, ccy)
 }
 
+// Return a copy of the book that can be transformed independently.
+//
+// The postings and names are copied and re-indexed, and the amounts and prices
+// are shared as they are never modified. The book itself is only read.
 func (b *Book) Duplicate() *Book {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
 	newp := make([]Posting, len(b.post), len(b.post))
 	copy(newp, b.post)
-	newt := make([]Transaction, len(b.trans), len(b.trans))
-	copy(newt, b.trans)
-	return &Book{newp, newt, b.prices, b.ccy}
+	newt := make([]Transaction, 0, len(b.trans))
+	if b.compacted {
+		idx := 0
+		for _, t := range b.trans {
+			newt = append(newt, newp[idx:idx+len(t)])
+			idx += len(t)
+		}
+	}
+	return &Book{
+		post:      newp,
+		trans:     newt,
+		prices:    b.prices,
+		ccy:       b.ccy,
+		names:     b.names.copy(),
+		compacted: b.compacted,
+		indexed:   b.indexed,
+		balanced:  b.balanced,
+		sources:   b.sources,
+	}
 }
 
 func (b *Book) GetCCYDecimals() map[string]int {
@@ -75,6 +119,7 @@ func (b *Book) AdjustBy(by string) {
 	if by == "none" {
 		return
 	}
+	b.ensureCompact()
 
 	var minDate, maxDate Date
 	isFirst := true
@@ -113,7 +158,7 @@ func (b *Book) AdjustBy(by string) {
 	}
 
 	b.post = newposts
-	b.compact()
+	b.compacted = false
 }
 
 // Depreciation Postings
@@ -124,48 +169,17 @@ func (b *Book) AdjustBy(by string) {
 // into replace_acct (asset holding account) and, over periods into the future, move back
 // into the search_acct.
 //
-// Period is day, month, quarter, or year.
-//
-// NOTE: The amounts are not properly rounded for the currency in question and left as a
-// fully rational number. So amounts will add correctly, but may look off.
-//
-// For example:
-// 1 divided over three periods would be 0.33333 by three, so 0.34 (displayed) by three, adding
-// up to 1.
+// Method is straight, declining, or sumofyears and period is monthly, quarterly, or yearly
+// (see Schedule).
 //
-func (b *Book) Depreciate(search_acct string, replace_acct string, period string, periods int64) {
-	re := regexp.MustCompile(search_acct)
-
-	newposts := b.post
-	for _, p := range b.post {
-
-		// Skip it if not applicable
-		if !re.MatchString(p.GetAccount()) {
-			continue
-		}
-
-		// Depreciating asset account
-		nacct := re.ReplaceAllString(p.GetAccount(), replace_acct)
-
-		// Move all of the balance into the depreciating asset for today.
-		newposts = append(newposts, p.byFactor(big.NewRat(-1, 1)))
-		newposts = append(newposts, p.byAcctFactor(nacct, big.NewRat(1, 1)))
-
-		// Roll it forward.
-		d := p.date
-		for j := int64(0); j < periods; j++ {
-
-			// Move part balance back again
-			newposts = append(newposts, p.byAcctDateFactor(nacct, d, big.NewRat(-1, periods)))
-			newposts = append(newposts, p.byAcctDateFactor(p.GetAccount(), d, big.NewRat(1, periods)))
-
-			// Move forward by the period
-			d = d.FloorDiff(period, 1)
-		}
+// The amounts are rounded for the currency, with the remainder in the last period.
+func (b *Book) Depreciate(search_acct string, replace_acct string, method string, period string, periods int) error {
+	s := Schedule{Method: method, Period: period, Periods: periods}
+	if err := s.Validate(); err != nil {
+		return err
 	}
-
-	b.post = newposts
-	b.compact()
+	b.ApplySchedule(search_acct, replace_acct, s)
+	return nil
 }
 
 // Create Adjust Postings
@@ -191,7 +205,91 @@ func (b *Book) AdjustPost(search_acct string, replace_acct string, factor float6
 	}
 
 	b.post = newposts
-	b.compact()
+	b.compacted = false
+}
+
+// Create Split Postings
+//
+// For all postings that match search_acct regular expression, create pairs of postings
+// to transfer a share (by fraction) from the matching account into each of the accounts.
+//
+// The shares are rounded to the currency decimals. If the fractions sum to one the last
+// account takes any rounding remainder, otherwise the remainder stays in the matching
+// account.
+//
+// search_acct - posting accounts to match
+// accts - accounts to transfer shares into (may use captured groups)
+// fractions - share for each account
+func (b *Book) SplitPost(search_acct string, accts []string, fractions []*big.Rat) {
+	re := regexp.MustCompile(search_acct)
+	b.ensureCompact()
+
+	// Remaining fraction for the matching account
+	rest := big.NewRat(1, 1)
+	for _, f := range fractions {
+		rest.Sub(rest, f)
+	}
+	splits := fractions
+	if rest.Sign() != 0 {
+		splits = append(append(make([]*big.Rat, 0, len(fractions)+1), fractions...), rest)
+	}
+
+	newposts := b.post
+	for _, p := range b.post {
+		if !re.MatchString(p.GetAccount()) {
+			continue
+		}
+		dec, ok := b.ccy[p.ccy]
+		if !ok {
+			dec = 2
+		}
+		parts := SplitAmount(p.val, splits, dec)
+		for i, acct := range accts {
+			if parts[i].Sign() == 0 {
+				continue
+			}
+			nacct := re.ReplaceAllString(p.GetAccount(), acct)
+			newposts = append(newposts, p.dup(p.acct, p.date, new(big.Rat).Neg(parts[i])))
+			newposts = append(newposts, p.dup(nacct, p.date, parts[i]))
+		}
+	}
+
+	b.post = newposts
+	b.compacted = false
+}
+
+// Trading Account Postings
+//
+// For all multi-currency transactions, move the postings in accounts that match
+// search_acct regular expression (eg an exchange account) into trading accounts
+// <prefix>:<CCY> for their currency.
+//
+// The trading accounts then balance each currency of the exchange, and their
+// balances converted to the base currency are the gains and losses from holding
+// the currencies.
+func (b *Book) TradingAccounts(search_acct string, prefix string) {
+	re := regexp.MustCompile(search_acct)
+	b.ensureCompact()
+	for _, trans := range b.trans {
+		multi := false
+		for i := 1; i < len(trans); i++ {
+			if trans[i].ccy != trans[0].ccy {
+				multi = true
+				break
+			}
+		}
+		if !multi {
+			continue
+		}
+		for i := range trans {
+			if re.MatchString(trans[i].acct) {
+				trans[i].acct, trans[i].acctID = b.names.intern(prefix + ":" + trans[i].ccy)
+				trans[i].acctlevel = 0
+				trans[i].acctterm = trans[i].acct
+			}
+		}
+	}
+	b.compacted = false
 }
 
 // Find all the accounts matching regular expression reg that have non-zero balances
@@ -199,6 +297,7 @@ func (b *Book) AdjustPost(search_acct string, replace_acct string, factor float6
 func (b *Book) Accounts(reg string, onlyWithBalance bool) []string {
 	re := regexp.MustCompile(reg)
 	lbal := make(map[string]*big.Rat)
+	b.ensureBalances()
 	accts := make([]string, 0, 10)
 	for _, p := range b.post {
 		if re.MatchString(p.GetAccount()) {
@@ -217,6 +316,7 @@ func (b *Book) Accounts(reg string, onlyWithBalance bool) []string {
 }
 
 func (b *Book) FilterTransactionReverse(filter func(date Date, payee string, posts Transaction) bool) {
+	b.ensureCompact()
 
 	// Target
 	newp := make(Transaction, 0, len(b.post))
@@ -232,9 +332,12 @@ func (b *Book) FilterTransactionReverse(filter func(date Date, payee string, pos
 	}
 
 	b.post = newp
+	b.indexed = len(newp)
+	b.compacted = false
 }
 
 func (b *Book) FilterTransaction(filter func(date Date, payee string, posts Transaction) bool) {
+	b.ensureCompact()
 
 	// Target
 	newp := make([]Posting, 0, len(b.post))
@@ -250,6 +353,8 @@ func (b *Book) FilterTransaction(filter func(date Date, payee string, posts Tran
 
 	b.post = newp
 	b.trans = newt
+	b.indexed = len(newp)
+	b.compacted = false
 }
 
 func (b *Book) MapTransaction(mapper func(date Date, payee string) (Date, string)) {
@@ -257,30 +362,37 @@ func (b *Book) MapTransaction(mapper func(date Date, payee string) (Date, string
 	for i := range p {
 		p[i].date, p[i].payee = mapper(p[i].date, p[i].payee)
 	}
-	b.compact()
+	b.compacted = false
 }
 
 func (b *Book) MapAmount(mapper func(date Date, ccy string) (*big.Rat, string)) {
 	p := b.post
 	for i := range p {
 		v, ccy := mapper(p[i].date, p[i].ccy)
-		p[i].ccy = ccy
-		p[i].val.Mul(p[i].val, v)
+		if ccy != p[i].ccy {
+			p[i].ccy, p[i].ccyID = b.names.intern(ccy)
+		}
+		p[i].val = new(big.Rat).Mul(p[i].val, v)
 	}
+	b.compacted = false
 }
 
 func (b *Book) MapAccount(mapper func(acct string) string) {
-	amap := make(map[string]string)
+	type name struct {
+		acct string
+		id   int32
+	}
+	amap := make(map[string]name)
 	p := b.post
 	for i := range p {
 		n, ok := amap[p[i].acct]
 		if !ok {
-			n = mapper(p[i].acct)
+			n.acct, n.id = b.names.intern(mapper(p[i].acct))
 			amap[p[i].acct] = n
 		}
-		p[i].acct = n
+		p[i].acct, p[i].acctID = n.acct, n.id
 		p[i].acctlevel = 0
-		p[i].acctterm = n
+		p[i].acctterm = n.acct
 	}
-	b.compact()
+	b.compacted = false
 }
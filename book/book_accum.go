@@ -3,33 +3,11 @@ package book
 import (
 	"fmt"
 	"math/big"
-	"os"
 	"regexp"
 	"sort"
 	"strings"
 )
 
-func findMaxCommonPrefix(left string, right string, divider string) string {
-	prefix := 0
-	i := 0
-	for ; i < len(left) && i < len(right); i++ {
-		if left[i] != right[i] {
-			break
-		}
-		if strings.HasPrefix(left[i:], divider) {
-			prefix = i
-		}
-	}
-
-	if (i < len(left) && strings.HasPrefix(left[i:], divider)) ||
-		(i < len(right) && strings.HasPrefix(right[i:], divider)) ||
-		(i == len(left) && i == len(right)) {
-		prefix = i
-	}
-
-	return left[0:prefix]
-}
-
 func getSortOrder(a *big.Rat) string {
 	f, _ := a.Float64()
 	g := int64(f * 1000_000)
@@ -50,36 +28,11 @@ func getSortOrderString(val map[string]*big.Rat, acct string, divider string) st
 	return strings.Join(o, divider)
 }
 
-func splitByStrings(prefixes map[string]bool, acct string, includeSelf bool, divider string) (int, string) {
-	last := 0
-	o := make([]string, 0, 5)
-	i := 0
-	for {
-		if i == len(acct) {
-			if includeSelf {
-				if _, ok := prefixes[acct]; ok {
-					o = append(o, acct[last:])
-					last = i
-				}
-			}
-			break
-		}
-
-		if strings.HasPrefix(acct[i:], divider) {
-			if _, ok := prefixes[acct[:i]]; ok {
-				o = append(o, acct[last:i])
-				last = i + len(divider)
-			}
-		}
-
-		i++
-	}
-	o = append(o, acct[last:])
-	return len(o) - 1, acct[last:]
-}
-
 func addEmptyAccounts(posts []Posting) []Posting {
 
+	// Work on a copy, the postings belong to the book
+	posts = append(make([]Posting, 0, len(posts)), posts...)
+
 	type AccountType struct {
 		acct string
 		ccy  string
@@ -167,8 +120,8 @@ func (b *Book) Accumulate(toCCY string, divider string, credit *regexp.Regexp, h
 	// TODO: This needs simplifying, with some of the functionality brought out into separate layers.
 	//       There is too much in one function.
 
-	// Only need some of compact functionality
-	b.compact()
+	// Only read, so may be shared
+	b.ensureBalances()
 
 	posts := b.post
 
@@ -192,23 +145,13 @@ func (b *Book) Accumulate(toCCY string, divider string, credit *regexp.Regexp, h
 
 	// Find accounts that need to be accumulated
 	// -- need to be the same across all transactions
+	tree := b.AccountTree(divider, "")
 	accum := make(map[string]bool)
-	for i, p := range posts {
-		if p.ccy != toCCY {
-			accum[p.GetAccount()] = true
-		}
-
-		if i == 0 {
-			continue
-		}
-
-		if key := findMaxCommonPrefix(posts[i-1].GetAccount(), p.GetAccount(), divider); key != "" {
-			if posts[i-1].GetCCY() == p.GetCCY() &&
-				(posts[i-1].GetAccount() == key || p.GetAccount() == key) {
-				fmt.Fprintf(os.Stderr, "WARNING: account '%s' (%s) and '%s' (%s) both have balances\n", p.GetAccount(), p.GetCCY(), posts[i-1].GetAccount(), posts[i-1].GetCCY())
-			}
-			accum[key] = true
+	for _, n := range tree.Nodes() {
+		if n.isSummary(toCCY) {
+			accum[n.Account] = true
 		}
+		warnBothBalances(n)
 	}
 
 	transIdx := 0
@@ -241,7 +184,7 @@ func (b *Book) Accumulate(toCCY string, divider string, credit *regexp.Regexp, h
 
 			// Assign level to normal postings
 			for j := transIdx; j < i; j++ {
-				eposts[j].acctlevel, eposts[j].acctterm = splitByStrings(accum, eposts[j].acct, true, divider)
+				eposts[j].acctlevel, eposts[j].acctterm = tree.summaryLevel(eposts[j].acct, accum, true)
 				eposts[j].acctsort = getSortOrderString(acctval, eposts[j].acct, divider)
 			}
 
@@ -249,7 +192,7 @@ func (b *Book) Accumulate(toCCY string, divider string, credit *regexp.Regexp, h
 			for k, _ := range accum {
 
 				// Find the level
-				newlevels, newterm := splitByStrings(accum, k, false, divider)
+				newlevels, newterm := tree.summaryLevel(k, accum, false)
 				newsort := getSortOrderString(acctval, k, divider)
 
 				// Add new posting
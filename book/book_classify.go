@@ -0,0 +1,240 @@
+package book
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Naive Bayes classifier of counter-accounts for two-posting transactions.
+//
+// Each transaction is described by features: the tokens of the payee, the
+// bucket of the amount and the day of the week. The classifier is trained on
+// the counter-accounts of an account in a reference book.
+type Classifier struct {
+	acct     string
+	total    int                       // Number of training transactions
+	accounts map[string]int            // Training transactions per counter-account
+	counts   map[string]map[string]int // Feature counts per counter-account
+	features map[string]int            // Feature counts per counter-account (total)
+	seen     map[string]int            // Training transactions per feature
+}
+
+// Candidate counter-account for a transaction
+type Candidate struct {
+	Account     string
+	Probability float64
+}
+
+// Evidence for a counter-account from a single feature
+type Evidence struct {
+	Feature string // Feature, eg payee:tesco, amount:-30..-10 or day:Sat
+	Count   int    // Training transactions with the feature and counter-account
+	Total   int    // Training transactions with the feature
+}
+
+// Classification of a single transaction
+type Classification struct {
+	Transaction Transaction
+	Account     string      // Best counter-account
+	Confidence  float64     // Probability (0.0 to 1.0) of the best counter-account
+	Candidates  []Candidate // Top candidates, most probable first
+	Evidence    []Evidence  // Features supporting the best counter-account
+	Applied     bool        // True if the counter-account was reclassified
+}
+
+// Number of candidates reported in a classification
+const classifyCandidates = 3
+
+// Return the counter-account posting index of a two-posting transaction
+// with acct, or -1 if the transaction is not such a transaction.
+func counterPosting(trans Transaction, acct string) int {
+	if len(trans) != 2 {
+		return -1
+	}
+	if trans[0].acct == acct {
+		return 1
+	}
+	if trans[1].acct == acct {
+		return 0
+	}
+	return -1
+}
+
+// Bucket an amount by sign and (approximate half) order of magnitude
+func amountBucket(amt *big.Rat) string {
+	f, _ := amt.Float64()
+	sign := ""
+	if f < 0 {
+		sign = "-"
+		f = -f
+	}
+	// Buckets are 0..1, 1..3, 3..10, 10..30, ...
+	low, high := 0.0, 1.0
+	for f >= high {
+		low = high
+		if high == math.Pow(10, math.Round(math.Log10(high))) {
+			high = high * 3
+		} else {
+			high = high / 3 * 10
+		}
+	}
+	return fmt.Sprintf("amount:%s%g..%g", sign, low, high)
+}
+
+// Return the features of a transaction, posting p being the account posting
+func transactionFeatures(trans Transaction, p int) []string {
+	features := make([]string, 0, 8)
+	uniq := make(map[string]bool)
+	for _, tok := range strings.FieldsFunc(strings.ToLower(trans.GetPayee()), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		// Skip short tokens and numbers (usually references)
+		if len(tok) < 2 || strings.IndexFunc(tok, unicode.IsLetter) == -1 {
+			continue
+		}
+		if uniq[tok] {
+			continue
+		}
+		uniq[tok] = true
+		features = append(features, "payee:"+tok)
+	}
+	features = append(features, amountBucket(trans[p].val))
+	features = append(features, "day:"+trans.GetDate().GetTime().Weekday().String()[:3])
+	return features
+}
+
+// Create a new classifier trained on the counter-accounts of acct in the
+// two-posting transactions of the reference book.
+func NewClassifier(ref *Book, acct string) *Classifier {
+	c := &Classifier{
+		acct:     acct,
+		accounts: make(map[string]int),
+		counts:   make(map[string]map[string]int),
+		features: make(map[string]int),
+		seen:     make(map[string]int),
+	}
+
+	for _, trans := range ref.Transactions() {
+		cp := counterPosting(trans, acct)
+		if cp == -1 {
+			continue
+		}
+		cacct := trans[cp].acct
+
+		c.total++
+		c.accounts[cacct]++
+		fc, ok := c.counts[cacct]
+		if !ok {
+			fc = make(map[string]int)
+			c.counts[cacct] = fc
+		}
+		for _, f := range transactionFeatures(trans, 1-cp) {
+			fc[f]++
+			c.features[cacct]++
+			c.seen[f]++
+		}
+	}
+
+	return c
+}
+
+// Classify a two-posting transaction with the classifier's account.
+//
+// Returns nil if the transaction can't be classified.
+func (c *Classifier) Classify(trans Transaction) *Classification {
+	cp := counterPosting(trans, c.acct)
+	if cp == -1 || c.total == 0 {
+		return nil
+	}
+	features := transactionFeatures(trans, 1-cp)
+
+	// Log-probabilities with Laplace smoothing, ignoring unknown features
+	vocab := float64(len(c.seen))
+	cands := make([]Candidate, 0, len(c.accounts))
+	maxlp := math.Inf(-1)
+	for acct, n := range c.accounts {
+		lp := math.Log(float64(n) / float64(c.total))
+		for _, f := range features {
+			if _, ok := c.seen[f]; !ok {
+				continue
+			}
+			lp += math.Log((float64(c.counts[acct][f]) + 1) / (float64(c.features[acct]) + vocab))
+		}
+		if lp > maxlp {
+			maxlp = lp
+		}
+		cands = append(cands, Candidate{acct, lp})
+	}
+
+	// Normalise to probabilities
+	sum := 0.0
+	for i := range cands {
+		cands[i].Probability = math.Exp(cands[i].Probability - maxlp)
+		sum += cands[i].Probability
+	}
+	for i := range cands {
+		cands[i].Probability /= sum
+	}
+	sort.Slice(cands, func(i, j int) bool {
+		if cands[i].Probability != cands[j].Probability {
+			return cands[i].Probability > cands[j].Probability
+		}
+		return cands[i].Account < cands[j].Account
+	})
+	if len(cands) > classifyCandidates {
+		cands = cands[:classifyCandidates]
+	}
+
+	// Evidence for the best account
+	evidence := make([]Evidence, 0, len(features))
+	for _, f := range features {
+		if cnt := c.counts[cands[0].Account][f]; cnt > 0 {
+			evidence = append(evidence, Evidence{f, cnt, c.seen[f]})
+		}
+	}
+
+	return &Classification{
+		Transaction: trans,
+		Account:     cands[0].Account,
+		Confidence:  cands[0].Probability,
+		Candidates:  cands,
+		Evidence:    evidence,
+	}
+}
+
+// Reclassify counter-accounts in two-posting transactions in the book.
+//
+// Transactions with the classifier's account and the default counter-account
+// dfltacct are classified. Where the confidence is at least threshold the
+// counter-account is replaced with the best candidate.
+//
+// The classifications are returned.
+func (b *Book) ReclassifyByClassifier(c *Classifier, dfltacct string, threshold float64) []*Classification {
+	results := make([]*Classification, 0)
+	b.ensureCompact()
+	for _, trans := range b.trans {
+		cp := counterPosting(trans, c.acct)
+		if cp == -1 || trans[cp].acct != dfltacct {
+			continue
+		}
+
+		cl := c.Classify(trans)
+		if cl == nil {
+			continue
+		}
+
+		if cl.Confidence >= threshold && cl.Account != dfltacct {
+			trans[cp].acct, trans[cp].acctID = b.names.intern(cl.Account)
+			trans[cp].acctlevel = 0
+			trans[cp].acctterm = trans[cp].acct
+			cl.Applied = true
+		}
+		results = append(results, cl)
+	}
+	b.compacted = false
+	return results
+}
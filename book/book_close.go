@@ -0,0 +1,73 @@
+package book
+
+import (
+	"math/big"
+	"regexp"
+	"sort"
+)
+
+// Year-end closing and opening transactions
+//
+// This creates a new book with the transactions to close the books for the
+// period ending at end (exclusive):
+//
+// The closing transaction, dated the day before end, transfers the balances
+// of all income and expense accounts (matching income) into the retained account.
+//
+// The opening transaction, dated end, carries forward the balances of all the
+// balance sheet accounts (matching balance). The rest of the balance for each
+// currency is put into the opening account.
+//
+// Both transactions balance for each currency, and the opening balances are the
+// same as the balances of the book as of end.
+func (b *Book) CloseBooks(end Date, income *regexp.Regexp, balance *regexp.Regexp, retained string, opening string) *Book {
+	bb := NewBookBuilder()
+
+	// Closing transaction: zero all the income and expense accounts
+	closing := b.AccountBalances(income, end)
+	if len(closing) > 0 {
+		bb.NewTransaction(end.AddDays(-1), "Closing balances", "")
+		total := make(map[string]*big.Rat)
+		for _, bal := range closing {
+			bb.AddPosting(bal.Account, bal.CCY, big.NewRat(0, 1).Neg(bal.Balance), "")
+			addTotal(total, bal.CCY, bal.Balance)
+		}
+		for _, ccy := range sortedCCYs(total) {
+			bb.AddPosting(retained, ccy, total[ccy], "")
+		}
+	}
+
+	// Opening transaction: carry forward all the balance sheet accounts
+	openingBals := b.AccountBalances(balance, end)
+	if len(openingBals) > 0 {
+		bb.NewTransaction(end, "Opening balances", "")
+		total := make(map[string]*big.Rat)
+		for _, bal := range openingBals {
+			bb.AddPosting(bal.Account, bal.CCY, bal.Balance, "")
+			addTotal(total, bal.CCY, bal.Balance)
+		}
+		for _, ccy := range sortedCCYs(total) {
+			bb.AddPosting(opening, ccy, big.NewRat(0, 1).Neg(total[ccy]), "")
+		}
+	}
+
+	return bb.Build()
+}
+
+func addTotal(total map[string]*big.Rat, ccy string, amt *big.Rat) {
+	v, ok := total[ccy]
+	if !ok {
+		v = big.NewRat(0, 1)
+		total[ccy] = v
+	}
+	v.Add(v, amt)
+}
+
+func sortedCCYs(total map[string]*big.Rat) []string {
+	ccys := make([]string, 0, len(total))
+	for ccy := range total {
+		ccys = append(ccys, ccy)
+	}
+	sort.Strings(ccys)
+	return ccys
+}
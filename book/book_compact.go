@@ -5,17 +5,201 @@ import (
 	"sort"
 )
 
+// Compact the book if it has changed since it was last compacted.
+//
+// Transformations only mark the book as changed, so a series of them compacts
+// once when the book is next read. This is for transformations, which own the
+// book: reads use ensureBalances.
+func (b *Book) ensureCompact() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.compacted {
+		b.compact()
+	}
+}
+
+// Compact the book and calculate the balances of the postings, if needed.
+//
+// Every read of the postings calls this first, so a book shared by goroutines
+// is only modified by the first read (under the lock) and then only read.
+// Transformations skip calculating the balances.
+func (b *Book) ensureBalances() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.compacted {
+		b.compact()
+	}
+	if !b.balanced {
+		b.calculateBalances()
+	}
+}
+
+// Sort key of a posting by transaction (date and payee), and ranks of the account
+// and currency IDs, and its index before sorting (to keep the order of equal keys)
+type postingKey struct {
+	date  Date
+	payee string
+	acct  int32
+	ccy   int32
+	idx   int32
+}
+
+func (k *postingKey) isLess(r *postingKey) bool {
+	if k.date != r.date {
+		return k.date < r.date
+	}
+	if k.payee != r.payee {
+		return k.payee < r.payee
+	}
+	if k.acct != r.acct {
+		return k.acct < r.acct
+	}
+	if k.ccy != r.ccy {
+		return k.ccy < r.ccy
+	}
+	return k.idx < r.idx
+}
+
+// Check if the postings of a transaction are in order of account and currency
+func isTransactionSorted(p []Posting, rank []int32) bool {
+	for i := 1; i < len(p); i++ {
+		a, c := rank[p[i].acctID], rank[p[i-1].acctID]
+		if a < c || (a == c && rank[p[i].ccyID] < rank[p[i-1].ccyID]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Check if the postings are in order of transaction (date and payee)
+func isByTransaction(p []Posting) bool {
+	for i := 1; i < len(p); i++ {
+		if p[i].date < p[i-1].date || (p[i].date == p[i-1].date && p[i].payee < p[i-1].payee) {
+			return false
+		}
+	}
+	return true
+}
+
+// Return the length of the transaction at the start of the postings
+func transactionLen(p []Posting) int {
+	n := 1
+	for n < len(p) && p[n].date == p[0].date && p[n].payee == p[0].payee {
+		n++
+	}
+	return n
+}
+
+// Sort the postings by date, payee, account and currency.
+//
+// Postings of a book have no accumulated levels or sort order, so this is the
+// order of isLess. The keys compare the ranks of the interned IDs rather than
+// the names, and the postings are moved once into their sorted places.
+func sortPostings(p []Posting, rank []int32) {
+	keys := make([]postingKey, len(p))
+	for i := range p {
+		keys[i] = postingKey{p[i].date, p[i].payee, rank[p[i].acctID], rank[p[i].ccyID], int32(i)}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].isLess(&keys[j])
+	})
+
+	// Permute in place, following each cycle of the sorted indexes
+	for i := range keys {
+		if keys[i].idx < 0 {
+			continue
+		}
+		tmp := p[i]
+		j := i
+		for {
+			k := int(keys[j].idx)
+			keys[j].idx = -1
+			if k == i {
+				p[j] = tmp
+				break
+			}
+			p[j] = p[k]
+			j = k
+		}
+	}
+}
+
+// Merge postings in order of transaction, with those of a in a transaction first
+func mergePostings(a []Posting, b []Posting) []Posting {
+	p := make([]Posting, 0, len(a)+len(b))
+	for len(a) > 0 && len(b) > 0 {
+		if b[0].date < a[0].date || (b[0].date == a[0].date && b[0].payee < a[0].payee) {
+			p = append(p, b[0])
+			b = b[1:]
+		} else {
+			p = append(p, a[0])
+			a = a[1:]
+		}
+	}
+	return append(append(p, a...), b...)
+}
+
+// Assign IDs to the postings without them (eg created by transformations)
+func (b *Book) internPostings() {
+	p := b.post
+	for i := range p {
+		if p[i].acctID == 0 {
+			p[i].acct, p[i].acctID = b.names.intern(p[i].acct)
+		}
+		if p[i].ccyID == 0 {
+			p[i].ccy, p[i].ccyID = b.names.intern(p[i].ccy)
+		}
+	}
+}
+
+// Order the postings by transaction, and by account and currency within each
+// transaction.
+//
+// The transactions are kept in order by transformations other than those mapping
+// dates or payees, which need all the postings sorted. Otherwise only postings
+// appended since the book was compacted are sorted and merged in, and then
+// transactions with postings out of order (eg with accounts mapped) are sorted.
+func (b *Book) orderPostings() {
+	rank := b.names.ranks()
+
+	indexed := b.indexed
+	if indexed > len(b.post) {
+		indexed = len(b.post)
+	}
+	if !isByTransaction(b.post[:indexed]) {
+		sortPostings(b.post, rank)
+		return
+	}
+	if indexed < len(b.post) {
+		tail := b.post[indexed:]
+		sortPostings(tail, rank)
+		b.post = mergePostings(b.post[:indexed], tail)
+	}
+
+	for p := b.post; len(p) > 0; {
+		n := transactionLen(p)
+		if !isTransactionSorted(p[:n], rank) {
+			sortPostings(p[:n], rank)
+		}
+		p = p[n:]
+	}
+}
+
+// Order the postings, combine those of the same transaction, account and currency,
+// remove zero postings, and re-index the transactions.
 func (b *Book) compact() {
+	b.compacted = true
+	b.balanced = false
+	b.trans = b.trans[:0]
 
 	// Skip empty
 	if len(b.post) == 0 {
+		b.indexed = 0
 		return
 	}
 
-	// Sort
-	sort.Slice(b.post, func(i, j int) bool {
-		return b.post[i].isLess(&b.post[j])
-	})
+	b.internPostings()
+	b.orderPostings()
 
 	// Initialize new array
 	targetIdx := 0
@@ -26,11 +210,12 @@ func (b *Book) compact() {
 
 		if p[i].date == p[targetIdx].date &&
 			p[i].payee == p[targetIdx].payee &&
-			p[i].acct == p[targetIdx].acct &&
-			p[i].ccy == p[targetIdx].ccy {
+			p[i].acctID == p[targetIdx].acctID &&
+			p[i].ccyID == p[targetIdx].ccyID &&
+			(!b.sources || p[i].isSameSource(&p[targetIdx])) {
 
-			// Add in the numbers
-			p[targetIdx].val.Add(p[targetIdx].val, p[i].val)
+			// Add in the numbers (as a new value, it may be shared)
+			p[targetIdx].val = new(big.Rat).Add(p[targetIdx].val, p[i].val)
 
 		} else {
 
@@ -66,35 +251,30 @@ func (b *Book) compact() {
 
 	// Shrink again, except exclude targetIdx this time
 	b.post = p[:targetIdx]
-
-	// Reset transactions
-	b.trans = b.trans[:0]
-
-	// If there are no posts, then exit early
-	if len(b.post) == 0 {
-		return
-	}
+	b.indexed = len(b.post)
 
 	// Transaction re-index
-	p = b.post
-	lastIdx := 0
-	i := 1
-	for {
-		if i == len(p) || p[i].date != p[lastIdx].date || p[i].payee != p[lastIdx].payee {
-			b.trans = append(b.trans, p[lastIdx:i])
-			if i == len(p) {
-				break
-			}
-			lastIdx = i
-		}
-		i++
+	for p = b.post; len(p) > 0; {
+		n := transactionLen(p)
+		b.trans = append(b.trans, p[:n])
+		p = p[n:]
 	}
+}
 
-	// Calculate balance
-	amts := make(map[[2]string]*big.Rat)
-	p = b.post
+// Check if the postings are from the same source and have the same cleared status
+func (p *Posting) isSameSource(r *Posting) bool {
+	return p.file == r.file && p.line == r.line && p.cleared == r.cleared
+}
+
+// Calculate the balances of the postings, as new values as they may be shared
+func (b *Book) calculateBalances() {
+	b.balanced = true
+
+	amts := make(map[[2]int32]*big.Rat)
+	bals := make([]big.Rat, len(b.post))
+	p := b.post
 	for i := range p {
-		key := [2]string{p[i].acct, p[i].ccy}
+		key := [2]int32{p[i].acctID, p[i].ccyID}
 		v, ok := amts[key]
 		if !ok {
 			v = big.NewRat(0, 1)
@@ -103,6 +283,6 @@ func (b *Book) compact() {
 		} else {
 			v.Add(v, p[i].val)
 		}
-		p[i].bal.Set(v)
+		p[i].bal = bals[i].Set(v)
 	}
 }
@@ -0,0 +1,76 @@
+package book
+
+import (
+	"math/big"
+	"testing"
+)
+
+// Check the book is compacted: postings in order of date, payee, account and
+// currency, one for each account and currency of a transaction and none zero,
+// with the IDs of their names and balances of the running totals
+func checkCompacted(t *testing.T, name string, b *Book) {
+	bals := make(map[[2]string]*big.Rat)
+	var prev *Posting
+	for _, trans := range b.Transactions() {
+		if prev != nil && trans[0].date == prev.date && trans[0].payee == prev.payee {
+			t.Errorf("%s: transaction %s %s split", name, prev.date, prev.payee)
+		}
+		for i := range trans {
+			p := &trans[i]
+			if p.date != trans[0].date || p.payee != trans[0].payee {
+				t.Errorf("%s: posting %s in transaction %s %s", name, p, trans[0].date, trans[0].payee)
+			}
+			if prev != nil && !prev.isLess(p) {
+				t.Errorf("%s: posting %s not after %s", name, p, prev)
+			}
+			if p.val.Sign() == 0 {
+				t.Errorf("%s: zero posting %s", name, p)
+			}
+			if b.names.names[p.acctID] != p.acct || b.names.names[p.ccyID] != p.ccy {
+				t.Errorf("%s: posting %s has IDs of %s %s", name, p, b.names.names[p.acctID], b.names.names[p.ccyID])
+			}
+
+			key := [2]string{p.acct, p.ccy}
+			if _, ok := bals[key]; !ok {
+				bals[key] = new(big.Rat)
+			}
+			bals[key].Add(bals[key], p.val)
+			if bals[key].Cmp(p.bal) != 0 {
+				t.Errorf("%s: posting %s expected balance %s", name, p, bals[key].RatString())
+			}
+			prev = p
+		}
+	}
+}
+
+func TestCompact(t *testing.T) {
+	for name, op := range concurrentOps {
+		b := getSharedBook()
+		op(b)
+		checkCompacted(t, name, b)
+	}
+
+	b := buildBenchBook(getBenchPostings(5000))
+	checkCompacted(t, "load", b)
+
+	// Mapping accounts and filtering keeps transactions in order
+	applyBenchOps(b)
+	checkCompacted(t, "ops", b)
+
+	// Appending postings merges them in
+	b.SplitPost("^Expense:Group1:", []string{"Expense:Shared"}, []*big.Rat{big.NewRat(1, 3)})
+	b.AdjustPost("^Liability:CreditCard$", "Asset:Bank:Current", 0.5)
+	checkCompacted(t, "appended", b)
+
+	// Mapping dates and payees, and reversing, sorts them all
+	b.FilterTransactionReverse(func(date Date, payee string, posts Transaction) bool {
+		return true
+	})
+	checkCompacted(t, "reversed", b)
+	b.MapTransaction(func(date Date, payee string) (Date, string) {
+		return GetDate(2030, 1, 1).AddDays(-date.AsDays() % 1000), payee
+	})
+	checkCompacted(t, "dates", b)
+	b.SplitBy("yearly")
+	checkCompacted(t, "yearly", b)
+}
@@ -1,10 +1,24 @@
 package book
 
+import (
+	"sort"
+)
+
+// Match of an imported transaction with an existing transaction
+type DuplicateMatch struct {
+	Import   Transaction // Transaction removed from the book
+	Existing Transaction // Transaction in the main book it matched
+	Score    float64     // Similarity of the payees (1.0 for exact)
+}
+
 // Remove transactions from book that already exists in the main book.
 //
 // This matches only (date, payee) unique combinations and doesn't inspect
 // the actual postings within the transaction.
-func (b *Book) RemoveDuplicatesOf(main *Book) {
+//
+// The matches for the removed transactions are returned.
+func (b *Book) RemoveDuplicatesOf(main *Book) []DuplicateMatch {
+	matches := make([]DuplicateMatch, 0)
 	reftrans := main.Transactions()
 	refidx := 0
 	b.FilterTransaction(func(date Date, payee string, posts Transaction) bool {
@@ -29,10 +43,90 @@ func (b *Book) RemoveDuplicatesOf(main *Book) {
 
 		// If the same, then we have a match. Remove this one.
 		if reftrans[refidx][0].payee == payee && reftrans[refidx][0].date == date {
+			matches = append(matches, DuplicateMatch{posts, reftrans[refidx], 1.0})
 			return false
 		}
 
 		// Otherwise keep it.
 		return true
 	})
+
+	return matches
+}
+
+// Remove transactions from book that look like they already exist in the main book.
+//
+// A transaction matches an existing transaction if both have a posting in acct with
+// the same amount and currency, they are dated within days of each other, and the
+// similarity of the payees (from similar(), 0.0 to 1.0) is at least minScore.
+//
+// The most similar existing transaction is chosen (the nearest date if equally similar)
+// and each existing transaction is matched at most once. So repeated genuine purchases
+// from the same payee on the same day are kept unless they already exist as often.
+//
+// The matches for the removed transactions are returned.
+func (b *Book) RemoveSimilarDuplicatesOf(main *Book, acct string, days int, similar func(a, b string) float64, minScore float64) []DuplicateMatch {
+	matches := make([]DuplicateMatch, 0)
+	reftrans := main.Transactions()
+	used := make(map[int]bool)
+	b.FilterTransaction(func(date Date, payee string, posts Transaction) bool {
+		p := findPosting(posts, acct)
+		if p == nil {
+			return true
+		}
+
+		// First existing transaction within the window
+		start := sort.Search(len(reftrans), func(i int) bool {
+			return reftrans[i].GetDate() >= date.AddDays(-days)
+		})
+		last := date.AddDays(days)
+
+		best := -1
+		bestScore := 0.0
+		bestDays := 0
+		for i := start; i < len(reftrans) && reftrans[i].GetDate() <= last; i++ {
+			if used[i] {
+				continue
+			}
+			ref := findPosting(reftrans[i], acct)
+			if ref == nil || ref.ccy != p.ccy || ref.val.Cmp(p.val) != 0 {
+				continue
+			}
+
+			score := similar(payee, reftrans[i].GetPayee())
+			if score < minScore {
+				continue
+			}
+
+			diff := date.DaysSince(reftrans[i].GetDate())
+			if diff < 0 {
+				diff = -diff
+			}
+			if best == -1 || score > bestScore || (score == bestScore && diff < bestDays) {
+				best = i
+				bestScore = score
+				bestDays = diff
+			}
+		}
+
+		if best == -1 {
+			return true
+		}
+
+		used[best] = true
+		matches = append(matches, DuplicateMatch{posts, reftrans[best], bestScore})
+		return false
+	})
+
+	return matches
+}
+
+// Find the first posting for acct in the transaction, or nil if there is none
+func findPosting(trans Transaction, acct string) *Posting {
+	for i := range trans {
+		if trans[i].acct == acct {
+			return &trans[i]
+		}
+	}
+	return nil
 }
@@ -0,0 +1,87 @@
+package book
+
+import (
+	"math/big"
+	"regexp"
+	"sort"
+)
+
+// Payee of revaluation transactions
+const RevaluePayee = "Revaluation"
+
+// Revaluation Postings
+//
+// For all accounts that match accts with balances in currencies other than base on
+// the date (inclusive), compare the market value in base at the date's price with
+// the cost in base (each posting at the price of its own date). The difference is
+// posted in base to the account on the date, balanced by the account revalue.
+//
+// After converting the book to base (each posting at its own date's price) the
+// balances of the accounts are then their market value on the date.
+//
+// The adjustments are rounded to the base currency decimals.
+func (b *Book) Revalue(date Date, base string, accts *regexp.Regexp, revalue string) {
+	type holding struct {
+		acct string
+		ccy  string
+	}
+	bal := make(map[holding]*big.Rat)
+	cost := make(map[holding]*big.Rat)
+	for _, p := range b.post {
+		if p.date > date || p.ccy == base || !accts.MatchString(p.acct) {
+			continue
+		}
+		h := holding{p.acct, p.ccy}
+		if _, ok := bal[h]; !ok {
+			bal[h] = new(big.Rat)
+			cost[h] = new(big.Rat)
+		}
+		rate, _ := b.GetPrice(p.date, p.ccy, base)
+		bal[h].Add(bal[h], p.val)
+		cost[h].Add(cost[h], new(big.Rat).Mul(p.val, rate))
+	}
+
+	holdings := make([]holding, 0, len(bal))
+	for h := range bal {
+		holdings = append(holdings, h)
+	}
+	sort.Slice(holdings, func(i, j int) bool {
+		if holdings[i].acct != holdings[j].acct {
+			return holdings[i].acct < holdings[j].acct
+		}
+		return holdings[i].ccy < holdings[j].ccy
+	})
+
+	dec, ok := b.ccy[base]
+	if !ok {
+		dec = 2
+	}
+	newposts := b.post
+	for _, h := range holdings {
+		rate, _ := b.GetPrice(date, h.ccy, base)
+		diff := new(big.Rat).Mul(bal[h], rate)
+		diff = RoundAmount(diff.Sub(diff, cost[h]), dec)
+		if diff.Sign() == 0 {
+			continue
+		}
+		newposts = append(newposts, Posting{
+			date:  date,
+			payee: RevaluePayee,
+			acct:  h.acct,
+			ccy:   base,
+			val:   diff,
+			bal:   big.NewRat(0, 1),
+		})
+		newposts = append(newposts, Posting{
+			date:  date,
+			payee: RevaluePayee,
+			acct:  revalue,
+			ccy:   base,
+			val:   new(big.Rat).Neg(diff),
+			bal:   big.NewRat(0, 1),
+		})
+	}
+
+	b.post = newposts
+	b.compacted = false
+}
@@ -3,6 +3,7 @@ package book
 import (
 	"math/big"
 	"regexp"
+	"sort"
 )
 
 // Rename accounts
@@ -39,7 +40,8 @@ func (b *Book) RegexCCY(search string, replace string) {
 		}
 	})
 
-	// Map the prices
+	// Map the prices into a new price book, it may be shared
+	data := make(map[PricePair]PriceList, len(b.prices.data))
 	for ccypair, pl := range b.prices.data {
 		newpair := ccypair
 		if re.MatchString(ccypair.Unit) {
@@ -48,12 +50,9 @@ func (b *Book) RegexCCY(search string, replace string) {
 		if re.MatchString(ccypair.CCY) {
 			newpair.CCY = re.ReplaceAllString(ccypair.CCY, replace)
 		}
-		if newpair.Unit == ccypair.Unit && newpair.CCY == ccypair.CCY {
-			continue
-		}
-		b.prices.data[newpair] = pl
-		delete(b.prices.data, ccypair)
+		data[newpair] = pl
 	}
+	b.prices = &priceBook{data}
 }
 
 func (b *Book) FilterByDateSince(minDate Date) {
@@ -85,3 +84,53 @@ func (b *Book) FilterByAccount(acct string) {
 		return false
 	})
 }
+
+// Balance of an account in a currency
+type AccountBalance struct {
+	Account string
+	CCY     string
+	Balance *big.Rat
+}
+
+// Find the balances of all accounts matching the regular expression as of a date
+// (excluding the date itself, or all transactions if 0). Zero balances are left out.
+//
+// The balances are sorted by account and currency.
+func (b *Book) AccountBalances(re *regexp.Regexp, asof Date) []AccountBalance {
+	type key struct {
+		acct string
+		ccy  string
+	}
+	bals := make(map[key]*big.Rat)
+	for _, trans := range b.Transactions() {
+		if asof != 0 && trans.GetDate() >= asof {
+			break
+		}
+		for _, p := range trans {
+			if re.MatchString(p.acct) {
+				bals[key{p.acct, p.ccy}] = p.bal
+			}
+		}
+	}
+
+	balances := make([]AccountBalance, 0, len(bals))
+	for k, v := range bals {
+		if v.Sign() == 0 {
+			continue
+		}
+		balances = append(balances, AccountBalance{
+			Account: k.acct,
+			CCY:     k.ccy,
+			Balance: big.NewRat(0, 1).Set(v),
+		})
+	}
+
+	sort.Slice(balances, func(i, j int) bool {
+		if balances[i].Account != balances[j].Account {
+			return balances[i].Account < balances[j].Account
+		}
+		return balances[i].CCY < balances[j].CCY
+	})
+
+	return balances
+}
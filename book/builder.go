@@ -3,16 +3,30 @@ package book
 import (
 	"fmt"
 	"math/big"
+	"sort"
 )
 
+// Date and payee of a transaction
+type transKey struct {
+	date  Date
+	payee string
+}
+
 type Builder struct {
-	post      []Posting
-	prevTrans map[string]bool
-	currAmts  map[string]*big.Rat
-	currDate  Date
-	currPayee string
-	currNote  string
-	prices    *priceBookBuilder
+	post        []Posting
+	prevTrans   map[transKey]bool
+	names       map[string]string // interned payees
+	ids         *nameTable        // interned accounts and currencies
+	currAmts    map[string]*big.Rat
+	currDate    Date
+	currPayee   string
+	currNote    string
+	currFile    string
+	currLine    int
+	currCleared bool
+	trading     string
+	sources     bool
+	prices      *priceBookBuilder
 }
 
 func (b *Builder) Build() *Book {
@@ -42,14 +56,16 @@ func (b *Builder) Build() *Book {
 	}
 
 	nbook := &Book{
-		post:   b.post,
-		trans:  make([]Transaction, len(b.post), len(b.post)),
-		prices: b.prices.build(),
-		ccy:    rmap,
+		post:    b.post,
+		trans:   make([]Transaction, len(b.post), len(b.post)),
+		prices:  b.prices.build(),
+		ccy:     rmap,
+		names:   b.ids,
+		sources: b.sources,
 	}
 
-	// Compact the book
-	nbook.compact()
+	// Compact the book, with balances so that it is complete for reading
+	nbook.ensureBalances()
 
 	return nbook
 }
@@ -57,7 +73,9 @@ func (b *Builder) Build() *Book {
 func NewBookBuilder() *Builder {
 	return &Builder{
 		post:      make([]Posting, 0, 200),
-		prevTrans: make(map[string]bool),
+		prevTrans: make(map[transKey]bool),
+		names:     make(map[string]string),
+		ids:       newNameTable(),
 		currAmts:  make(map[string]*big.Rat),
 		currDate:  Date(-1),
 		currPayee: "",
@@ -67,6 +85,8 @@ func NewBookBuilder() *Builder {
 }
 
 func (b *Builder) checkAndClearTransaction() {
+	b.addTradingPostings()
+
 	var Zero big.Int
 	for ccy, amt := range b.currAmts {
 		if amt.Num().Cmp(&Zero) != 0 {
@@ -86,10 +106,11 @@ func (b *Builder) NewTransaction(date Date, payee string, note string) {
 	b.currDate = date
 	b.currPayee = payee
 	b.currNote = note
+	b.currCleared = false
 
 	// Adjust payee if needed
 	idx := 1
-	key := fmt.Sprintf("%d %s", date, b.currPayee)
+	key := transKey{date, b.currPayee}
 	for {
 		_, ok := b.prevTrans[key]
 		if !ok {
@@ -99,21 +120,39 @@ func (b *Builder) NewTransaction(date Date, payee string, note string) {
 
 		idx += 1
 		b.currPayee = fmt.Sprintf("%s (%d)", payee, idx)
-		key = fmt.Sprintf("%d %s", date, b.currPayee)
+		key = transKey{date, b.currPayee}
 	}
+	b.currPayee = b.intern(b.currPayee)
 
 }
 
+// Return the interned payee, so that postings share one copy of each payee
+func (b *Builder) intern(s string) string {
+	if n, ok := b.names[s]; ok {
+		return n
+	}
+	b.names[s] = s
+	return s
+}
+
 func (b *Builder) AddPosting(acct string, ccy string, amt *big.Rat, note string) {
+	acct, acctID := b.ids.intern(acct)
+	ccy, ccyID := b.ids.intern(ccy)
 	b.post = append(b.post, Posting{
-		date:  b.currDate,
-		payee: b.currPayee,
-		tnote: b.currNote,
-		acct:  acct,
-		ccy:   ccy,
-		val:   amt,
-		note:  note,
-		bal:   big.NewRat(0, 1),
+		date:   b.currDate,
+		payee:  b.currPayee,
+		tnote:  b.currNote,
+		acct:   acct,
+		ccy:    ccy,
+		val:    amt,
+		note:   note,
+		bal:    big.NewRat(0, 1),
+		acctID: acctID,
+		ccyID:  ccyID,
+
+		file:    b.currFile,
+		line:    b.currLine,
+		cleared: b.currCleared,
 	})
 
 	v, ok := b.currAmts[ccy]
@@ -126,6 +165,59 @@ func (b *Builder) AddPosting(acct string, ccy string, amt *big.Rat, note string)
 	}
 }
 
+// Set the prefix of trading accounts for multi-currency transactions.
+//
+// Transactions with imbalances in more than one currency are balanced with a
+// posting for each currency into <prefix>:<CCY> (the trading accounts method)
+// rather than rejected. If the prefix is empty (default) they are rejected.
+func (b *Builder) SetTrading(prefix string) {
+	b.trading = prefix
+}
+
+// Balance multi-currency imbalances of the current transaction with trading accounts
+func (b *Builder) addTradingPostings() {
+	if b.trading == "" {
+		return
+	}
+
+	ccys := make([]string, 0, len(b.currAmts))
+	for ccy, amt := range b.currAmts {
+		if amt.Sign() != 0 {
+			ccys = append(ccys, ccy)
+		}
+	}
+	if len(ccys) < 2 {
+		return
+	}
+	sort.Strings(ccys)
+
+	// Trading postings have no source in the ledger
+	file, line := b.currFile, b.currLine
+	b.currFile, b.currLine = "", 0
+	for _, ccy := range ccys {
+		b.AddPosting(b.trading+":"+ccy, ccy, new(big.Rat).Neg(b.currAmts[ccy]), "")
+	}
+	b.currFile, b.currLine = file, line
+}
+
+// Keep postings of a transaction from different sources (file and line), or
+// with different cleared status, separate rather than combining them by account
+// and currency, so that they can be matched to their source (eg reconciling).
+func (b *Builder) SetKeepSources(keep bool) {
+	b.sources = keep
+}
+
+// Set the source file and line for subsequent postings
+func (b *Builder) SetSource(file string, line int) {
+	b.currFile = file
+	b.currLine = line
+}
+
+// Set the cleared status for subsequent postings in the current transaction
+func (b *Builder) SetCleared(cleared bool) {
+	b.currCleared = cleared
+}
+
 func (b *Builder) GetLastCCYBals() map[string]*big.Rat {
 	return b.currAmts
 }
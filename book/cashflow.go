@@ -0,0 +1,277 @@
+package book
+
+import (
+	"encoding/json"
+	"math/big"
+	"regexp"
+	"sort"
+)
+
+// Activity classes of a cash-flow statement, in order
+var CashFlowClasses = []string{"Operating", "Investing", "Financing"}
+
+// Account for cash flows between cash accounts in different currencies
+const CashFlowExchange = "Exchange"
+
+// Cash flow for a class and counter-account
+type CashFlowLine struct {
+	Class   string
+	Account string
+	Amount  *big.Rat
+}
+
+// Cash-flow statement for a period and currency
+//
+// The closing cash is the opening cash plus the sum of the lines.
+type CashFlowPeriod struct {
+	Date    Date // Start of the period
+	CCY     string
+	Opening *big.Rat
+	Closing *big.Rat
+	Lines   []CashFlowLine
+}
+
+// Total of the lines for the class
+func (p CashFlowPeriod) ClassTotal(class string) *big.Rat {
+	total := new(big.Rat)
+	for _, l := range p.Lines {
+		if l.Class == class {
+			total.Add(total, l.Amount)
+		}
+	}
+	return total
+}
+
+// Net change in cash over the period
+func (p CashFlowPeriod) Net() *big.Rat {
+	return new(big.Rat).Sub(p.Closing, p.Opening)
+}
+
+func (p CashFlowPeriod) MarshalJSON() ([]byte, error) {
+
+	type JsonCashFlowLine struct {
+		Class   string  `json:"class"`
+		Account string  `json:"account"`
+		Amount  float64 `json:"amount"`
+	}
+	type JsonCashFlowPeriod struct {
+		Date    Date               `json:"date"`
+		CCY     string             `json:"ccy"`
+		Opening float64            `json:"opening"`
+		Closing float64            `json:"closing"`
+		Lines   []JsonCashFlowLine `json:"lines"`
+	}
+
+	jp := &JsonCashFlowPeriod{
+		Date:  p.Date,
+		CCY:   p.CCY,
+		Lines: make([]JsonCashFlowLine, 0, len(p.Lines)),
+	}
+	jp.Opening, _ = p.Opening.Float64()
+	jp.Closing, _ = p.Closing.Float64()
+	for _, l := range p.Lines {
+		amt, _ := l.Amount.Float64()
+		jp.Lines = append(jp.Lines, JsonCashFlowLine{l.Class, l.Account, amt})
+	}
+
+	return json.Marshal(jp)
+}
+
+// Return the cash-flow statements for each period (see Floor) and currency,
+// from the first period with cash postings in the currency to the last period.
+// Periods without cash postings have no lines, and open and close with the
+// closing cash of the period before.
+//
+// The change in cash of each transaction is allocated to its non-cash postings
+// of the same currency in proportion to their amounts, and each is classified as
+// investing or financing by the account regexes, or operating otherwise. If there
+// are no such postings the change is allocated to the first non-cash posting, or
+// to Exchange (operating) for an exchange between cash accounts.
+//
+// The allocation is exact, so the lines reconcile to the change in cash.
+func (b *Book) CashFlow(cash, investing, financing *regexp.Regexp, by string) []CashFlowPeriod {
+	b.ensureBalances()
+
+	classify := func(acct string) string {
+		if investing != nil && investing.MatchString(acct) {
+			return "Investing"
+		} else if financing != nil && financing.MatchString(acct) {
+			return "Financing"
+		}
+		return "Operating"
+	}
+
+	type lineKey struct {
+		class, acct string
+	}
+
+	type periodKey struct {
+		date Date
+		ccy  string
+	}
+
+	balances := make(map[string]*big.Rat)
+	flows := make(map[periodKey]map[lineKey]*big.Rat)
+	periods := make([]CashFlowPeriod, 0)
+	pidx := make(map[periodKey]int)
+
+	getPeriod := func(date Date, ccy string) *CashFlowPeriod {
+		k := periodKey{date, ccy}
+		if i, ok := pidx[k]; ok {
+			return &periods[i]
+		}
+		bal, ok := balances[ccy]
+		if !ok {
+			bal = new(big.Rat)
+			balances[ccy] = bal
+		}
+		pidx[k] = len(periods)
+		flows[k] = make(map[lineKey]*big.Rat)
+		periods = append(periods, CashFlowPeriod{
+			Date:    date,
+			CCY:     ccy,
+			Opening: new(big.Rat).Set(bal),
+		})
+		return &periods[len(periods)-1]
+	}
+
+	for _, trans := range b.trans {
+		date := trans.GetDate().Floor(by)
+
+		// Change in cash by currency
+		change := make(map[string]*big.Rat)
+		ccys := make([]string, 0, 1)
+		for _, p := range trans {
+			if !cash.MatchString(p.acct) {
+				continue
+			}
+			c, ok := change[p.ccy]
+			if !ok {
+				c = new(big.Rat)
+				change[p.ccy] = c
+				ccys = append(ccys, p.ccy)
+			}
+			c.Add(c, p.val)
+		}
+
+		for _, ccy := range ccys {
+			c := change[ccy]
+			getPeriod(date, ccy)
+			k := periodKey{date, ccy}
+			balances[ccy].Add(balances[ccy], c)
+			if c.Sign() == 0 {
+				continue
+			}
+
+			// Counter postings of the same currency
+			counter := make([]Posting, 0, len(trans))
+			sum := new(big.Rat)
+			for _, p := range trans {
+				if p.ccy == ccy && !cash.MatchString(p.acct) {
+					counter = append(counter, p)
+					sum.Add(sum, p.val)
+				}
+			}
+
+			add := func(acct string, amt *big.Rat) {
+				lk := lineKey{classify(acct), acct}
+				if acct == CashFlowExchange {
+					lk.class = "Operating"
+				}
+				v, ok := flows[k][lk]
+				if !ok {
+					v = new(big.Rat)
+					flows[k][lk] = v
+				}
+				v.Add(v, amt)
+			}
+
+			if sum.Sign() != 0 {
+				// Each counter posting takes c * amount / sum
+				factor := new(big.Rat).Quo(c, sum)
+				for _, p := range counter {
+					add(p.acct, new(big.Rat).Mul(p.val, factor))
+				}
+				continue
+			}
+
+			acct := CashFlowExchange
+			for _, p := range trans {
+				if !cash.MatchString(p.acct) {
+					acct = p.acct
+					break
+				}
+			}
+			add(acct, c)
+		}
+	}
+
+	// Closing balances and lines in order of class then account
+	order := make(map[string]int)
+	for i, c := range CashFlowClasses {
+		order[c] = i
+	}
+	for i := range periods {
+		p := &periods[i]
+		k := periodKey{p.Date, p.CCY}
+		p.Closing = new(big.Rat).Set(p.Opening)
+		p.Lines = make([]CashFlowLine, 0, len(flows[k]))
+		for lk, v := range flows[k] {
+			if v.Sign() == 0 {
+				continue
+			}
+			p.Closing.Add(p.Closing, v)
+			p.Lines = append(p.Lines, CashFlowLine{lk.class, lk.acct, v})
+		}
+		sort.Slice(p.Lines, func(i, j int) bool {
+			if p.Lines[i].Class != p.Lines[j].Class {
+				return order[p.Lines[i].Class] < order[p.Lines[j].Class]
+			}
+			return p.Lines[i].Account < p.Lines[j].Account
+		})
+	}
+
+	// Add the periods without cash postings, up to the next period of the
+	// currency or the last period
+	sort.Slice(periods, func(i, j int) bool {
+		if periods[i].CCY != periods[j].CCY {
+			return periods[i].CCY < periods[j].CCY
+		}
+		return periods[i].Date < periods[j].Date
+	})
+	last := Date(0)
+	for _, p := range periods {
+		if p.Date > last {
+			last = p.Date
+		}
+	}
+	all := make([]CashFlowPeriod, 0, len(periods))
+	for i, p := range periods {
+		all = append(all, p)
+		end := last.AddDays(1)
+		if i+1 < len(periods) && periods[i+1].CCY == p.CCY {
+			end = periods[i+1].Date
+		}
+
+		// Stop if the period type doesn't advance (eg none)
+		for prev, d := p.Date, p.Date.FloorDiff(by, 1); d > prev && d < end; prev, d = d, d.FloorDiff(by, 1) {
+			all = append(all, CashFlowPeriod{
+				Date:    d,
+				CCY:     p.CCY,
+				Opening: new(big.Rat).Set(p.Closing),
+				Closing: new(big.Rat).Set(p.Closing),
+				Lines:   make([]CashFlowLine, 0),
+			})
+		}
+	}
+	periods = all
+
+	sort.SliceStable(periods, func(i, j int) bool {
+		if periods[i].Date != periods[j].Date {
+			return periods[i].Date < periods[j].Date
+		}
+		return periods[i].CCY < periods[j].CCY
+	})
+
+	return periods
+}
@@ -0,0 +1,127 @@
+package book
+
+import (
+	"math/big"
+	"regexp"
+	"testing"
+)
+
+func TestCashFlow(t *testing.T) {
+	book := GetBook([]QuickBook{
+		{"2020-01-01", "Opening", []QuickPosting{
+			{"Asset:Bank", "GBP", 1000},
+			{"Equity:Opening", "GBP", -1000},
+		}},
+		{"2020-01-15", "Salary", []QuickPosting{
+			{"Asset:Bank", "GBP", 800},
+			{"Expense:Tax", "GBP", 200},
+			{"Income:Salary", "GBP", -1000},
+		}},
+		{"2020-01-20", "Savings", []QuickPosting{
+			{"Asset:Savings", "GBP", 300},
+			{"Asset:Bank", "GBP", -300},
+		}},
+		{"2020-02-01", "Shares", []QuickPosting{
+			{"Asset:Shares", "GBP", 500},
+			{"Asset:Bank", "GBP", -500},
+		}},
+		{"2020-02-10", "Mortgage", []QuickPosting{
+			{"Liability:Mortgage", "GBP", 250},
+			{"Expense:Interest", "GBP", 50},
+			{"Asset:Bank", "GBP", -300},
+		}},
+	}, nil)
+
+	periods := book.CashFlow(
+		regexp.MustCompile("^Asset:(Bank|Savings)$"),
+		regexp.MustCompile("^Asset:Shares$"),
+		regexp.MustCompile("^(Liability|Equity)(:|$)"),
+		"monthly")
+	if len(periods) != 2 {
+		t.Fatalf("expected 2 periods, got %d", len(periods))
+	}
+
+	jan, feb := periods[0], periods[1]
+	if jan.Opening.Sign() != 0 || jan.Closing.Cmp(big.NewRat(1800, 1)) != 0 {
+		t.Errorf("expected January 0 to 1800, got %s to %s", jan.Opening.FloatString(2), jan.Closing.FloatString(2))
+	}
+	if feb.Opening.Cmp(jan.Closing) != 0 || feb.Closing.Cmp(big.NewRat(1000, 1)) != 0 {
+		t.Errorf("expected February 1800 to 1000, got %s to %s", feb.Opening.FloatString(2), feb.Closing.FloatString(2))
+	}
+
+	for _, tc := range []struct {
+		period CashFlowPeriod
+		class  string
+		amount int64
+	}{
+		{jan, "Operating", 800},
+		{jan, "Financing", 1000},
+		{feb, "Operating", -50},
+		{feb, "Investing", -500},
+		{feb, "Financing", -250},
+	} {
+		if got := tc.period.ClassTotal(tc.class); got.Cmp(big.NewRat(tc.amount, 1)) != 0 {
+			t.Errorf("%s %s: expected %d, got %s", tc.period.Date, tc.class, tc.amount, got.FloatString(2))
+		}
+	}
+
+	// Salary is allocated to its counter-accounts
+	amts := make(map[string]*big.Rat)
+	for _, l := range jan.Lines {
+		amts[l.Account] = l.Amount
+	}
+	if amts["Income:Salary"].Cmp(big.NewRat(1000, 1)) != 0 || amts["Expense:Tax"].Cmp(big.NewRat(-200, 1)) != 0 {
+		t.Errorf("unexpected salary allocation %v", amts)
+	}
+}
+
+func TestCashFlowEmptyPeriods(t *testing.T) {
+	book := GetBook([]QuickBook{
+		{"2020-01-01", "Opening", []QuickPosting{
+			{"Asset:Bank", "GBP", 1000},
+			{"Asset:USD", "USD", 100},
+			{"Equity:Opening", "GBP", -1000},
+			{"Equity:Opening", "USD", -100},
+		}},
+		{"2020-02-10", "Accrual", []QuickPosting{
+			{"Expense:Rent", "GBP", 500},
+			{"Liability:Rent", "GBP", -500},
+		}},
+		{"2020-03-05", "Rent", []QuickPosting{
+			{"Liability:Rent", "GBP", 500},
+			{"Asset:Bank", "GBP", -500},
+		}},
+	}, nil)
+
+	periods := book.CashFlow(regexp.MustCompile("^Asset:"), nil, nil, "monthly")
+	exp := []struct {
+		date             Date
+		ccy              string
+		opening, closing int64
+		lines            int
+	}{
+		{20200101, "GBP", 0, 1000, 1},
+		{20200101, "USD", 0, 100, 1},
+		{20200201, "GBP", 1000, 1000, 0},
+		{20200201, "USD", 100, 100, 0},
+		{20200301, "GBP", 1000, 500, 1},
+		{20200301, "USD", 100, 100, 0},
+	}
+	if len(periods) != len(exp) {
+		t.Fatalf("expected %d periods, got %d", len(exp), len(periods))
+	}
+	for i, e := range exp {
+		p := periods[i]
+		if p.Date != e.date || p.CCY != e.ccy || p.Opening.Cmp(big.NewRat(e.opening, 1)) != 0 ||
+			p.Closing.Cmp(big.NewRat(e.closing, 1)) != 0 || len(p.Lines) != e.lines {
+			t.Errorf("expected %s %s %d to %d with %d lines, got %s %s %s to %s with %d lines",
+				e.date, e.ccy, e.opening, e.closing, e.lines,
+				p.Date, p.CCY, p.Opening.FloatString(2), p.Closing.FloatString(2), len(p.Lines))
+		}
+	}
+
+	// Each transaction is its own period without a period type
+	if periods := book.CashFlow(regexp.MustCompile("^Asset:"), nil, nil, "none"); len(periods) != 3 {
+		t.Errorf("expected 3 periods for none, got %d", len(periods))
+	}
+}
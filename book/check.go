@@ -0,0 +1,248 @@
+package book
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Names of the checks of a book
+const (
+	CheckSingleUse  = "single"     // Accounts with a single posting
+	CheckTypos      = "typos"      // Accounts with names similar to other accounts
+	CheckPricePath  = "pricepath"  // Currencies without prices to the base currency
+	CheckFuture     = "future"     // Transactions dated in the future
+	CheckDuplicates = "duplicates" // Transactions that look like duplicates
+	CheckInverse    = "inverse"    // Prices inconsistent with the inverse prices
+	CheckRenumbered = "renumbered" // Payees renumbered as repeated on a date
+)
+
+// Problem found by a check of a book
+type CheckFinding struct {
+	Check   string `json:"check"`
+	Date    Date   `json:"date,omitempty"` // Date of the transaction or price (0 if none)
+	Subject string `json:"subject"`        // Account, currency, payee or price pair
+	Message string `json:"message"`
+	File    string `json:"file,omitempty"` // Source of the transaction (empty if none)
+	Line    int    `json:"line,omitempty"`
+}
+
+func newCheckFinding(check string, p *Posting, subject string, format string, a ...interface{}) CheckFinding {
+	f := CheckFinding{
+		Check:   check,
+		Subject: subject,
+		Message: fmt.Sprintf(format, a...),
+	}
+	if p != nil {
+		f.Date = p.date
+		f.File, f.Line = p.GetSource()
+	}
+	return f
+}
+
+// Find accounts with a single posting, which are often mistakes
+func (b *Book) CheckSingleUse() []CheckFinding {
+	count := make(map[string]int)
+	first := make(map[string]*Posting)
+	for _, trans := range b.Transactions() {
+		for i := range trans {
+			if count[trans[i].acct]++; count[trans[i].acct] == 1 {
+				first[trans[i].acct] = &trans[i]
+			}
+		}
+	}
+
+	findings := make([]CheckFinding, 0)
+	for _, acct := range b.Accounts(".*", false) {
+		if count[acct] == 1 {
+			findings = append(findings, newCheckFinding(CheckSingleUse, first[acct], acct, "account used once"))
+		}
+	}
+	return findings
+}
+
+// Find accounts with names that are likely typos of other accounts: they differ
+// in one component (split by divider) by at most maxDistance (from distance()),
+// and by at most a third of the component.
+func (b *Book) CheckTypos(divider string, distance func(a, b string) int, maxDistance int) []CheckFinding {
+	accts := b.Accounts(".*", false)
+	parts := make([][]string, len(accts))
+	for i, acct := range accts {
+		parts[i] = strings.Split(acct, divider)
+	}
+
+	findings := make([]CheckFinding, 0)
+	for i := range accts {
+		for j := i + 1; j < len(accts); j++ {
+			if len(parts[i]) != len(parts[j]) {
+				continue
+			}
+
+			// Find the only differing component
+			diff := -1
+			for k := range parts[i] {
+				if parts[i][k] == parts[j][k] {
+					continue
+				}
+				if diff != -1 {
+					diff = -1
+					break
+				}
+				diff = k
+			}
+			if diff == -1 {
+				continue
+			}
+
+			a, b := parts[i][diff], parts[j][diff]
+			shortest := len(a)
+			if len(b) < shortest {
+				shortest = len(b)
+			}
+			if d := distance(a, b); d <= maxDistance && d*3 <= shortest {
+				findings = append(findings, newCheckFinding(CheckTypos, nil, accts[i], "similar to account '%s'", accts[j]))
+			}
+		}
+	}
+	return findings
+}
+
+// Find currencies of postings without prices to convert them to baseccy
+func (b *Book) CheckPricePath(baseccy string) []CheckFinding {
+	first := make(map[string]*Posting)
+	count := make(map[string]int)
+	for _, trans := range b.Transactions() {
+		for i := range trans {
+			ccy := trans[i].ccy
+			if ccy == baseccy {
+				continue
+			}
+			if _, ok := first[ccy]; !ok {
+				if _, pt := b.GetPrice(trans[i].date, ccy, baseccy); pt != PriceTypeNone {
+					first[ccy] = nil
+					continue
+				}
+				first[ccy] = &trans[i]
+			}
+			if first[ccy] != nil {
+				count[ccy]++
+			}
+		}
+	}
+
+	ccys := make([]string, 0, len(count))
+	for ccy := range count {
+		ccys = append(ccys, ccy)
+	}
+	sort.Strings(ccys)
+
+	findings := make([]CheckFinding, 0)
+	for _, ccy := range ccys {
+		findings = append(findings, newCheckFinding(CheckPricePath, first[ccy], ccy, "no prices to %s for %d postings", baseccy, count[ccy]))
+	}
+	return findings
+}
+
+// Find transactions dated after today
+func (b *Book) CheckFuture(today Date) []CheckFinding {
+	findings := make([]CheckFinding, 0)
+	for _, trans := range b.Transactions() {
+		if trans.GetDate() > today {
+			findings = append(findings, newCheckFinding(CheckFuture, &trans[0], trans.GetPayee(), "dated %d days in the future", trans.GetDate().DaysSince(today)))
+		}
+	}
+	return findings
+}
+
+// Find transactions on the same date with a posting of the same account and amount
+// and similar payees (from similar(), 0.0 to 1.0, at least minScore)
+func (b *Book) CheckDuplicates(similar func(a, b string) float64, minScore float64) []CheckFinding {
+	findings := make([]CheckFinding, 0)
+	trans := b.Transactions()
+	for start := 0; start < len(trans); {
+		end := start + 1
+		for end < len(trans) && trans[end].GetDate() == trans[start].GetDate() {
+			end++
+		}
+
+		for i := start; i < end; i++ {
+			for j := i + 1; j < end; j++ {
+				if !sharePosting(trans[i], trans[j]) {
+					continue
+				}
+				if score := similar(trans[i].GetPayee(), trans[j].GetPayee()); score >= minScore {
+					findings = append(findings, newCheckFinding(CheckDuplicates, &trans[j][0], trans[j].GetPayee(),
+						"looks like a duplicate of '%s' (%.2f similar)", trans[i].GetPayee(), score))
+				}
+			}
+		}
+
+		start = end
+	}
+	return findings
+}
+
+// Check if the transactions have a posting with the same account, currency and amount
+func sharePosting(a Transaction, b Transaction) bool {
+	for i := range a {
+		if p := findPosting(b, a[i].acct); p != nil && p.ccy == a[i].ccy && p.val.Cmp(a[i].val) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Find prices of pairs that also have inverse prices, where the price times the
+// inverse price (on the date, or between the inverse prices) differs from 1 by
+// more than tolerance (relative, 0.01 is 1%)
+func (b *Book) CheckInverse(tolerance float64) []CheckFinding {
+	findings := make([]CheckFinding, 0)
+	for _, pair := range b.GetPricePairs() {
+		if pair.Unit > pair.CCY {
+			continue
+		}
+		inverse := b.GetPriceList(pair.CCY, pair.Unit)
+		if len(inverse) == 0 {
+			continue
+		}
+
+		for _, p := range b.GetPriceList(pair.Unit, pair.CCY) {
+			inv, pt := inverse.GetPrice(p.date)
+			if pt != PriceTypeExact && pt != PriceTypeInferred {
+				continue
+			}
+			price, _ := p.val.Float64()
+			invPrice, _ := inv.Float64()
+			if math.Abs(price*invPrice-1) > tolerance {
+				f := newCheckFinding(CheckInverse, nil, fmt.Sprintf("%s/%s", pair.Unit, pair.CCY),
+					"price %s inconsistent with inverse %s/%s price %s", p.val.FloatString(4), pair.CCY, pair.Unit, inv.FloatString(4))
+				f.Date = p.date
+				findings = append(findings, f)
+			}
+		}
+	}
+	return findings
+}
+
+var renumberedPayee = regexp.MustCompile(`^(.*) \(([0-9]+)\)$`)
+
+// Find transactions with payees renumbered (eg "Shop (2)") by the builder as there
+// is already a transaction with the payee on the date
+func (b *Book) CheckRenumbered() []CheckFinding {
+	payees := make(map[transKey]bool)
+	for _, trans := range b.Transactions() {
+		payees[transKey{trans.GetDate(), trans.GetPayee()}] = true
+	}
+
+	findings := make([]CheckFinding, 0)
+	for _, trans := range b.Transactions() {
+		m := renumberedPayee.FindStringSubmatch(trans.GetPayee())
+		if m == nil || !payees[transKey{trans.GetDate(), m[1]}] {
+			continue
+		}
+		findings = append(findings, newCheckFinding(CheckRenumbered, &trans[0], trans.GetPayee(), "payee '%s' repeated on the date", m[1]))
+	}
+	return findings
+}
@@ -0,0 +1,91 @@
+package book
+
+import (
+	"strings"
+	"testing"
+)
+
+// Distance by the number of differing characters of strings of the same length
+func hammingDistance(a, b string) int {
+	if len(a) != len(b) {
+		return len(a) + len(b)
+	}
+	d := 0
+	for i := range a {
+		if a[i] != b[i] {
+			d++
+		}
+	}
+	return d
+}
+
+// Similarity 1 for the same first word, otherwise 0
+func firstWordSimilarity(a, b string) float64 {
+	if strings.Fields(a)[0] == strings.Fields(b)[0] {
+		return 1.0
+	}
+	return 0.0
+}
+
+func TestCheck(t *testing.T) {
+	book := GetBook([]QuickBook{
+		{"2020-01-01", "Opening", []QuickPosting{
+			{"Asset:Bank", "GBP", 1000},
+			{"Equity:Opening", "GBP", -1000},
+		}},
+		{"2020-01-05", "Shop", []QuickPosting{
+			{"Expense:Food", "GBP", 25},
+			{"Asset:Bank", "GBP", -25},
+		}},
+		{"2020-01-05", "Shop", []QuickPosting{
+			{"Expense:Food", "GBP", 25},
+			{"Asset:Bank", "GBP", -25},
+		}},
+		{"2020-01-06", "Shop Ltd", []QuickPosting{
+			{"Expense:Fool", "GBP", 10},
+			{"Asset:Bank", "GBP", -10},
+		}},
+		{"2020-01-07", "Shop", []QuickPosting{
+			{"Expense:Food", "USD", 10},
+			{"Expense:Food", "EUR", 10},
+			{"Equity:Exchange", "USD", -10},
+			{"Equity:Exchange", "EUR", -10},
+		}},
+		{"2099-01-01", "Later", []QuickPosting{
+			{"Expense:Food", "GBP", 5},
+			{"Asset:Bank", "GBP", -5},
+		}},
+	}, []QuickPrice{{"USD", "GBP", 2}, {"GBP", "USD", 3}})
+
+	subjects := func(findings []CheckFinding) string {
+		s := make([]string, 0, len(findings))
+		for _, f := range findings {
+			s = append(s, f.Subject)
+		}
+		return strings.Join(s, ",")
+	}
+
+	tests := []struct {
+		check    string
+		findings []CheckFinding
+		exp      string
+	}{
+		{CheckSingleUse, book.CheckSingleUse(), "Equity:Opening,Expense:Fool"},
+		{CheckTypos, book.CheckTypos(":", hammingDistance, 1), "Expense:Food"},
+		{CheckPricePath, book.CheckPricePath("GBP"), "EUR"},
+		{CheckFuture, book.CheckFuture(GetDate(2021, 1, 1)), "Later"},
+		{CheckDuplicates, book.CheckDuplicates(firstWordSimilarity, 0.9), "Shop (2)"},
+		{CheckInverse, book.CheckInverse(0.01), "GBP/USD"},
+		{CheckRenumbered, book.CheckRenumbered(), "Shop (2)"},
+	}
+	for _, test := range tests {
+		if got := subjects(test.findings); got != test.exp {
+			t.Errorf("check %s: expected %s, got %s", test.check, test.exp, got)
+		}
+		for _, f := range test.findings {
+			if f.Check != test.check {
+				t.Errorf("check %s: unexpected check %s", test.check, f.Check)
+			}
+		}
+	}
+}
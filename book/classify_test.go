@@ -0,0 +1,91 @@
+package book
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestClassifier(t *testing.T) {
+	ref := GetBook([]QuickBook{
+		{"2020-01-02", "TESCO STORES 1234", []QuickPosting{
+			{"Asset:Bank", "GBP", -25},
+			{"Expense:Groceries", "GBP", 25},
+		}},
+		{"2020-01-09", "TESCO STORES 5678", []QuickPosting{
+			{"Asset:Bank", "GBP", -40},
+			{"Expense:Groceries", "GBP", 40},
+		}},
+		{"2020-01-10", "TESCO PETROL", []QuickPosting{
+			{"Asset:Bank", "GBP", -50},
+			{"Expense:Fuel", "GBP", 50},
+		}},
+		{"2020-01-12", "SHELL PETROL", []QuickPosting{
+			{"Asset:Bank", "GBP", -45},
+			{"Expense:Fuel", "GBP", 45},
+		}},
+		{"2020-01-15", "COFFEE", []QuickPosting{
+			{"Asset:Bank", "GBP", -3},
+			{"Expense:Coffee", "GBP", 3},
+		}},
+	}, []QuickPrice{})
+
+	imported := GetBook([]QuickBook{
+		{"2020-02-02", "TESCO STORES 9999", []QuickPosting{
+			{"Asset:Bank", "GBP", -30},
+			{"Expense:Unknown", "GBP", 30},
+		}},
+		{"2020-02-03", "BP PETROL", []QuickPosting{
+			{"Asset:Bank", "GBP", -55},
+			{"Expense:Unknown", "GBP", 55},
+		}},
+		{"2020-02-04", "SOMETHING ELSE", []QuickPosting{
+			{"Asset:Bank", "GBP", -1000},
+			{"Expense:Unknown", "GBP", 1000},
+		}},
+		{"2020-02-05", "TESCO STORES", []QuickPosting{
+			{"Asset:Bank", "GBP", -30},
+			{"Expense:Other", "GBP", 30},
+		}},
+	}, []QuickPrice{})
+
+	c := NewClassifier(ref, "Asset:Bank")
+	results := imported.ReclassifyByClassifier(c, "Expense:Unknown", 0.5)
+	if len(results) != 3 {
+		t.Fatalf("expected 3 classifications, got %d", len(results))
+	}
+
+	expected := []struct {
+		acct    string
+		applied bool
+	}{
+		{"Expense:Groceries", true},
+		{"Expense:Fuel", true},
+		{"Expense:Unknown", false},
+	}
+	for i, e := range expected {
+		r := results[i]
+		if r.Applied != e.applied {
+			t.Errorf("%s: expected applied %v, got %v (confidence %.2f)", r.Transaction.GetPayee(), e.applied, r.Applied, r.Confidence)
+		}
+		if len(r.Candidates) != 3 {
+			t.Errorf("%s: expected 3 candidates, got %d", r.Transaction.GetPayee(), len(r.Candidates))
+		}
+		acct := ""
+		for _, p := range r.Transaction {
+			if p.GetAccount() != "Asset:Bank" {
+				acct = p.GetAccount()
+			}
+		}
+		if acct != e.acct {
+			t.Errorf("%s: expected account %s, got %s", r.Transaction.GetPayee(), e.acct, acct)
+		}
+	}
+
+	if len(results[0].Evidence) == 0 || results[0].Evidence[0].Feature != "payee:tesco" {
+		t.Errorf("expected payee:tesco evidence, got %v", results[0].Evidence)
+	}
+
+	if b := amountBucket(big.NewRat(-45, 1)); b != "amount:-30..100" {
+		t.Errorf("expected amount:-30..100 bucket, got %s", b)
+	}
+}
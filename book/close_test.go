@@ -0,0 +1,75 @@
+package book
+
+import (
+	"math/big"
+	"regexp"
+	"testing"
+)
+
+func TestCloseBooks(t *testing.T) {
+	b := GetBook([]QuickBook{
+		{"2025-01-01", "Opening", []QuickPosting{
+			{"Asset:Bank", "GBP", 1000},
+			{"Equity:Opening", "GBP", -1000},
+		}},
+		{"2025-02-01", "Salary", []QuickPosting{
+			{"Asset:Bank", "GBP", 3000},
+			{"Income:Salary", "GBP", -3000},
+		}},
+		{"2025-03-01", "Trip", []QuickPosting{
+			{"Expense:Travel", "USD", 200},
+			{"Liability:Card", "USD", -200},
+		}},
+		{"2025-04-01", "Rent", []QuickPosting{
+			{"Expense:Rent", "GBP", 1200},
+			{"Asset:Bank", "GBP", -1200},
+		}},
+		{"2026-01-05", "Next year", []QuickPosting{
+			{"Expense:Rent", "GBP", 1200},
+			{"Asset:Bank", "GBP", -1200},
+		}},
+	}, []QuickPrice{})
+
+	income := regexp.MustCompile("^(Income|Expense)(:.*)?$")
+	balance := regexp.MustCompile("^(Asset|Liability)(:.*)?$")
+	closed := b.CloseBooks(20260101, income, balance, "Equity:RetainedEarnings", "Equity:OpeningBalances")
+
+	trans := closed.Transactions()
+	if len(trans) != 2 {
+		t.Fatalf("expected closing and opening transactions, got %d", len(trans))
+	}
+	if trans[0].GetDate() != 20251231 || trans[1].GetDate() != 20260101 {
+		t.Fatalf("expected closing on 2025/12/31 and opening on 2026/01/01, got %s and %s", trans[0].GetDate(), trans[1].GetDate())
+	}
+
+	// Closing zeroes income and expenses, and retains the profit
+	for _, bal := range closed.AccountBalances(income, 20260101) {
+		expected := big.NewRat(0, 1)
+		for _, orig := range b.AccountBalances(income, 20260101) {
+			if orig.Account == bal.Account && orig.CCY == bal.CCY {
+				expected.Neg(orig.Balance)
+			}
+		}
+		if bal.Balance.Cmp(expected) != 0 {
+			t.Fatalf("closing %s %s: expected %s, got %s", bal.Account, bal.CCY, expected.FloatString(2), bal.Balance.FloatString(2))
+		}
+	}
+	retained := closed.AccountBalances(regexp.MustCompile("^Equity:RetainedEarnings$"), 20260101)
+	if len(retained) != 2 || retained[0].CCY != "GBP" || retained[0].Balance.Cmp(big.NewRat(-1800, 1)) != 0 ||
+		retained[1].CCY != "USD" || retained[1].Balance.Cmp(big.NewRat(200, 1)) != 0 {
+		t.Fatalf("unexpected retained earnings %v", retained)
+	}
+
+	// Opening reconciles with the balances as of the year-end
+	opening := closed.AccountBalances(balance, 0)
+	expected := b.AccountBalances(balance, 20260101)
+	if len(opening) != len(expected) {
+		t.Fatalf("expected %d opening balances, got %d", len(expected), len(opening))
+	}
+	for i := range opening {
+		if opening[i].Account != expected[i].Account || opening[i].CCY != expected[i].CCY ||
+			opening[i].Balance.Cmp(expected[i].Balance) != 0 {
+			t.Fatalf("opening balance %v does not match %v", opening[i], expected[i])
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package book
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+)
+
+// Row of a columnar report, an account (and currency) with an amount per period
+type ColumnarRow struct {
+	Account string
+	Term    string
+	Level   int
+	CCY     string
+	Amounts []*big.Rat
+}
+
+// Columnar report with accounts as rows and periods as columns
+type ColumnarReport struct {
+	Periods []Date        `json:"periods"`
+	Rows    []ColumnarRow `json:"rows"`
+}
+
+// Build a columnar report from accumulated transactions (see Accumulate), one
+// transaction per period.
+//
+// The rows follow the order of the last transaction. Accounts missing from a
+// period have a zero amount.
+func Columnar(trans []Transaction) (*ColumnarReport, error) {
+	r := &ColumnarReport{
+		Periods: make([]Date, 0, len(trans)),
+		Rows:    make([]ColumnarRow, 0),
+	}
+	if len(trans) == 0 {
+		return r, nil
+	}
+
+	type rowKey struct {
+		acct, term, ccy string
+	}
+
+	idx := make(map[rowKey]int)
+	for _, p := range trans[len(trans)-1] {
+		idx[rowKey{p.acct, p.acctterm, p.ccy}] = len(r.Rows)
+		amts := make([]*big.Rat, len(trans))
+		for i := range amts {
+			amts[i] = new(big.Rat)
+		}
+		r.Rows = append(r.Rows, ColumnarRow{
+			Account: p.acct,
+			Term:    p.acctterm,
+			Level:   p.acctlevel,
+			CCY:     p.ccy,
+			Amounts: amts,
+		})
+	}
+
+	for i, t := range trans {
+		r.Periods = append(r.Periods, t.GetDate())
+		for _, p := range t {
+			j, ok := idx[rowKey{p.acct, p.acctterm, p.ccy}]
+			if !ok {
+				return nil, fmt.Errorf("account %s (term %s) currency %s not on all transactions; must summarise!",
+					p.acct, p.acctterm, p.ccy)
+			}
+			r.Rows[j].Amounts[i].Add(r.Rows[j].Amounts[i], p.val)
+		}
+	}
+
+	return r, nil
+}
+
+// Total of the amounts across all periods
+func (r ColumnarRow) Total() *big.Rat {
+	total := new(big.Rat)
+	for _, a := range r.Amounts {
+		total.Add(total, a)
+	}
+	return total
+}
+
+// Average of the amounts across all periods (or zero if none)
+func (r ColumnarRow) Average() *big.Rat {
+	if len(r.Amounts) == 0 {
+		return new(big.Rat)
+	}
+	return new(big.Rat).Quo(r.Total(), big.NewRat(int64(len(r.Amounts)), 1))
+}
+
+// Change of the last period from the previous (or nil if less than two periods)
+func (r ColumnarRow) Change() *big.Rat {
+	if len(r.Amounts) < 2 {
+		return nil
+	}
+	return new(big.Rat).Sub(r.Amounts[len(r.Amounts)-1], r.Amounts[len(r.Amounts)-2])
+}
+
+func (r ColumnarRow) MarshalJSON() ([]byte, error) {
+
+	type JsonColumnarRow struct {
+		Account string    `json:"account"`
+		Level   int       `json:"level"`
+		CCY     string    `json:"ccy"`
+		Amounts []float64 `json:"amounts"`
+		Total   float64   `json:"total"`
+		Average float64   `json:"average"`
+		Change  *float64  `json:"change,omitempty"`
+	}
+
+	jr := &JsonColumnarRow{
+		Account: r.Account,
+		Level:   r.Level,
+		CCY:     r.CCY,
+		Amounts: make([]float64, len(r.Amounts)),
+	}
+	for i, a := range r.Amounts {
+		jr.Amounts[i], _ = a.Float64()
+	}
+	jr.Total, _ = r.Total().Float64()
+	jr.Average, _ = r.Average().Float64()
+	if c := r.Change(); c != nil {
+		f, _ := c.Float64()
+		jr.Change = &f
+	}
+
+	return json.Marshal(jr)
+}
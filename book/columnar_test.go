@@ -0,0 +1,59 @@
+package book
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestColumnar(t *testing.T) {
+	book := GetBook([]QuickBook{
+		{"2020-01-05", "Rent", []QuickPosting{
+			{"Expense:Rent", "GBP", 800},
+			{"Asset:Bank", "GBP", -800},
+		}},
+		{"2020-01-10", "Shop", []QuickPosting{
+			{"Expense:Food", "GBP", 30},
+			{"Asset:Bank", "GBP", -30},
+		}},
+		{"2020-02-10", "Shop", []QuickPosting{
+			{"Expense:Food", "GBP", 45},
+			{"Asset:Bank", "GBP", -45},
+		}},
+	}, nil)
+	book.SplitBy("monthly")
+
+	r, err := Columnar(book.Accumulate("GBP", ":", nil, ""))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(r.Periods) != 2 || r.Periods[0] != 20200101 || r.Periods[1] != 20200201 {
+		t.Fatalf("unexpected periods %v", r.Periods)
+	}
+
+	rows := make(map[string]ColumnarRow)
+	for _, row := range r.Rows {
+		rows[row.Account] = row
+	}
+
+	rent, ok := rows["Expense:Rent"]
+	if !ok {
+		t.Fatalf("missing Expense:Rent row in %v", r.Rows)
+	}
+	if rent.Amounts[1].Sign() != 0 {
+		t.Errorf("expected zero rent in February, got %s", rent.Amounts[1].FloatString(2))
+	}
+
+	expense := rows["Expense"]
+	if expense.Level != 0 || rent.Level != 1 {
+		t.Errorf("unexpected levels %d and %d", expense.Level, rent.Level)
+	}
+	if expense.Total().Cmp(big.NewRat(875, 1)) != 0 {
+		t.Errorf("expected total 875, got %s", expense.Total().FloatString(2))
+	}
+	if expense.Average().Cmp(big.NewRat(875, 2)) != 0 {
+		t.Errorf("expected average 437.50, got %s", expense.Average().FloatString(2))
+	}
+	if expense.Change().Cmp(big.NewRat(-785, 1)) != 0 {
+		t.Errorf("expected change -785, got %s", expense.Change().FloatString(2))
+	}
+}
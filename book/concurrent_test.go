@@ -0,0 +1,197 @@
+package book
+
+import (
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func getSharedBook() *Book {
+	b := NewBookBuilder()
+	b.NewTransaction(20200101, "Opening", "")
+	b.AddPosting("Asset:Bank", "GBP", big.NewRat(1000, 1), "")
+	b.AddPosting("Equity:Opening", "GBP", big.NewRat(-1000, 1), "")
+	b.NewTransaction(20200110, "Buy USD", "")
+	b.AddPosting("Asset:USD", "USD", big.NewRat(100, 1), "")
+	b.AddPosting("Asset:Bank", "GBP", big.NewRat(-80, 1), "")
+	b.AddPosting("Equity:Exchange", "USD", big.NewRat(-100, 1), "")
+	b.AddPosting("Equity:Exchange", "GBP", big.NewRat(80, 1), "")
+	b.NewTransaction(20200115, "Shop", "")
+	b.AddPosting("Expense:Food", "GBP", big.NewRat(30, 1), "")
+	b.AddPosting("Expense:Food", "GBP", big.NewRat(20, 1), "")
+	b.AddPosting("Asset:Bank", "GBP", big.NewRat(-50, 1), "")
+	b.NewTransaction(20200203, "Travel", "")
+	b.AddPosting("Expense:Travel", "USD", big.NewRat(40, 1), "")
+	b.AddPosting("Asset:USD", "USD", big.NewRat(-40, 1), "")
+	b.NewTransaction(20200301, "Rent", "")
+	b.AddPosting("Expense:Rent", "GBP", big.NewRat(500, 1), "")
+	b.AddPosting("Asset:Bank", "GBP", big.NewRat(-500, 1), "")
+	b.AddPrice(20200101, "USD", "GBP", big.NewRat(8, 10))
+	b.AddPrice(20200301, "USD", "GBP", big.NewRat(9, 10))
+	return b.Build()
+}
+
+func dumpTransactions(trans []Transaction) string {
+	var sb strings.Builder
+	for _, t := range trans {
+		for _, p := range t {
+			fmt.Fprintf(&sb, "%s %s %s %s %s %s\n", p.date, p.payee, p.acct, p.ccy, p.val.RatString(), p.bal.RatString())
+		}
+	}
+	return sb.String()
+}
+
+// Operations on a book returning a dump of the result
+var concurrentOps = map[string]func(b *Book) string{
+	"convert": func(b *Book) string {
+		b.MapAmount(func(date Date, ccy string) (*big.Rat, string) {
+			rate, _ := b.GetPrice(date, ccy, "GBP")
+			return rate, "GBP"
+		})
+		return dumpTransactions(b.Transactions())
+	},
+	"accounts": func(b *Book) string {
+		b.RegexAccounts("^Expense:(.*)$", "Spending:$1", "")
+		return dumpTransactions(b.Transactions())
+	},
+	"ccy": func(b *Book) string {
+		b.RegexCCY("^USD$", "US$")
+		r, _ := b.GetPrice(20200201, "US$", "GBP")
+		return dumpTransactions(b.Transactions()) + r.RatString()
+	},
+	"split": func(b *Book) string {
+		b.SplitBy("monthly")
+		b.SplitPost("^Expense:Rent$", []string{"Expense:Rent:Share"}, []*big.Rat{big.NewRat(1, 3)})
+		return dumpTransactions(b.Transactions())
+	},
+	"trading": func(b *Book) string {
+		b.TradingAccounts("^Equity:Exchange$", "Trading")
+		return dumpTransactions(b.Transactions())
+	},
+	"revalue": func(b *Book) string {
+		b.Revalue(20200301, "GBP", regexp.MustCompile("^Asset:"), "Equity:Unrealized")
+		return dumpTransactions(b.Transactions())
+	},
+	"accumulate": func(b *Book) string {
+		b.FilterByDateSince(20200110)
+		return dumpTransactions(b.Accumulate("GBP", ":", regexp.MustCompile("^Equity"), ""))
+	},
+	"register": func(b *Book) string {
+		var sb strings.Builder
+		for _, e := range b.ExtractRegister("GBP", regexp.MustCompile("^Asset:"), false, false) {
+			fmt.Fprintf(&sb, "%s %s %s %s\n", e.Date, e.Account, e.Amount.RatString(), e.BaseBalance.RatString())
+		}
+		return sb.String()
+	},
+}
+
+func TestDuplicateIsIndependent(t *testing.T) {
+	for name, op := range concurrentOps {
+		b := getSharedBook()
+		orig := dumpTransactions(b.Transactions())
+		exp := op(getSharedBook())
+
+		if got := op(b.Duplicate()); got != exp {
+			t.Errorf("%s: duplicate got\n%s\nexpected\n%s", name, got, exp)
+		}
+		if got := dumpTransactions(b.Transactions()); got != orig {
+			t.Errorf("%s: original changed to\n%s\nexpected\n%s", name, got, orig)
+		}
+	}
+}
+
+// Run with -race to check that a shared book is not modified
+func TestConcurrentOps(t *testing.T) {
+	b := getSharedBook()
+	orig := dumpTransactions(b.Transactions())
+
+	exp := make(map[string]string)
+	for name, op := range concurrentOps {
+		exp[name] = op(getSharedBook())
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	errs := make([]string, 0)
+	for i := 0; i < 4; i++ {
+		for name, op := range concurrentOps {
+			wg.Add(1)
+			go func(name string, op func(b *Book) string) {
+				defer wg.Done()
+
+				// Reading the shared book and transforming a duplicate
+				dumpTransactions(b.Transactions())
+				b.GetPrice(20200201, "USD", "GBP")
+				if got := op(b.Duplicate()); got != exp[name] {
+					mu.Lock()
+					errs = append(errs, fmt.Sprintf("%s: got\n%s\nexpected\n%s", name, got, exp[name]))
+					mu.Unlock()
+				}
+			}(name, op)
+		}
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		t.Error(err)
+	}
+	if got := dumpTransactions(b.Transactions()); got != orig {
+		t.Errorf("original changed to\n%s\nexpected\n%s", got, orig)
+	}
+}
+
+// Reads of a shared book after transforming it
+var concurrentReads = map[string]func(b *Book) string{
+	"transactions": func(b *Book) string {
+		return dumpTransactions(b.Transactions())
+	},
+	"accumulate": func(b *Book) string {
+		return dumpTransactions(b.Accumulate("GBP", ":", regexp.MustCompile("^Equity"), ""))
+	},
+	"register": concurrentOps["register"],
+	"cashflow": func(b *Book) string {
+		return fmt.Sprintf("%v", b.CashFlow(regexp.MustCompile("^Asset:"), nil, nil, "monthly"))
+	},
+}
+
+// Run with -race to check that reading a transformed book (compacting it lazily)
+// from several goroutines is safe
+func TestConcurrentReadsTransformed(t *testing.T) {
+	transform := func() *Book {
+		b := getSharedBook()
+		b.RegexAccounts("^Expense:(.*)$", "Spending:$1", "")
+		b.Revalue(20200301, "GBP", regexp.MustCompile("^Asset:"), "Equity:Unrealized")
+		return b
+	}
+
+	exp := make(map[string]string)
+	for name, read := range concurrentReads {
+		exp[name] = read(transform())
+	}
+
+	b := transform()
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	errs := make([]string, 0)
+	for i := 0; i < 4; i++ {
+		for name, read := range concurrentReads {
+			wg.Add(1)
+			go func(name string, read func(b *Book) string) {
+				defer wg.Done()
+				if got := read(b); got != exp[name] {
+					mu.Lock()
+					errs = append(errs, fmt.Sprintf("%s: got\n%s\nexpected\n%s", name, got, exp[name]))
+					mu.Unlock()
+				}
+			}(name, read)
+		}
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		t.Error(err)
+	}
+}
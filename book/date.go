@@ -16,7 +16,40 @@ func (date Date) GetYear() string {
 	return fmt.Sprintf("%04d", int(date/10000))
 }
 
-var FloorTypes = []string{"yearly", "quarterly", "monthly", "today", "none"}
+var FloorTypes = []string{"yearly", "fiscal", "halfyearly", "quarterly", "monthly", "weekly", "today", "none"}
+
+// Return the period type for fiscal years starting on the month and day, eg
+// fiscal:04-06 for the UK tax year. The period type fiscal is for fiscal years
+// starting on January 1.
+func FiscalPeriod(month int, day int) (string, error) {
+	if month < 1 || month > 12 {
+		return "", fmt.Errorf("fiscal year start month %d must be between 1 and 12", month)
+	}
+	if day < 1 || day > monthDays[month-1] {
+		return "", fmt.Errorf("fiscal year start day %d must be between 1 and %d", day, monthDays[month-1])
+	}
+	if month == 1 && day == 1 {
+		return "fiscal", nil
+	}
+	return fmt.Sprintf("fiscal:%02d-%02d", month, day), nil
+}
+
+var fiscalPeriodRe = regexp.MustCompile("^fiscal(?::([0-9]{2})-([0-9]{2}))?$")
+
+// Return the start month and day of the fiscal years of a period type, and
+// whether it is a fiscal period type (see FiscalPeriod)
+func fiscalStart(by string) (int, int, bool) {
+	mat := fiscalPeriodRe.FindStringSubmatch(by)
+	if mat == nil {
+		return 0, 0, false
+	}
+	if mat[1] == "" {
+		return 1, 1, true
+	}
+	month, _ := strconv.Atoi(mat[1])
+	day, _ := strconv.Atoi(mat[2])
+	return month, day, true
+}
 
 func (date Date) Floor(by string) Date {
 	return date.FloorDiff(by, 0)
@@ -25,6 +58,12 @@ func (date Date) Floor(by string) Date {
 func (date Date) FloorDiff(by string, diff int) Date {
 	if by == "yearly" {
 		return date.FloorYear(diff)
+	} else if month, day, ok := fiscalStart(by); ok {
+		return date.FloorFiscalYear(month, day, diff)
+	} else if by == "halfyearly" {
+		return date.FloorHalfYear(diff)
+	} else if by == "weekly" {
+		return date.FloorWeek(diff)
 	} else if by == "quarterly" {
 		return date.FloorQuarter(diff)
 	} else if by == "monthly" {
@@ -40,6 +79,53 @@ func (date Date) FloorYear(diff int) Date {
 	return Date(((int(date/10000) + diff) * 10000) + 101)
 }
 
+// Start of the fiscal year, starting on the month and day, containing the date
+func (date Date) FloorFiscalYear(month int, day int, diff int) Date {
+	year := int(date / 10000)
+	if int(date%10000) < month*100+day {
+		year--
+	}
+	return GetDate(year+diff, month, day)
+}
+
+// Start of the half year (January or July)
+func (date Date) FloorHalfYear(diff int) Date {
+	return date.FloorMonth(-((int(date/100)%100 - 1) % 6)).FloorMonth(diff * 6)
+}
+
+// Start of the ISO week (Monday)
+func (date Date) FloorWeek(diff int) Date {
+	weekday := (int(date.GetTime().Weekday()) + 6) % 7
+	return date.AddDays(diff*7 - weekday)
+}
+
+// Label for the period starting at the date, eg 2025 Q1 for quarterly or FY2025/26
+// for a fiscal year not starting on January 1.
+func (date Date) Label(by string) string {
+	if month, day, ok := fiscalStart(by); ok {
+		start := date.FloorFiscalYear(month, day, 0)
+		if month == 1 && day == 1 {
+			return "FY" + start.GetYear()
+		}
+		return fmt.Sprintf("FY%s/%02d", start.GetYear(), (int(start/10000)+1)%100)
+	}
+
+	switch by {
+	case "yearly":
+		return date.GetYear()
+	case "halfyearly":
+		return fmt.Sprintf("%04d H%d", int(date/10000), (int(date/100)%100-1)/6+1)
+	case "quarterly":
+		return date.GetYearQuarter()
+	case "monthly":
+		return date.GetYearMonth()
+	case "weekly":
+		year, week := date.GetTime().ISOWeek()
+		return fmt.Sprintf("%04d-W%02d", year, week)
+	}
+	return date.String()
+}
+
 func (date Date) GetYearMonth() string {
 	return fmt.Sprintf("%04d/%02d", int(date/10000), int(date/100)%100)
 }
@@ -78,10 +164,14 @@ func (date Date) FloorQuarter(diff int) Date {
 
 var dateYYYYMMDD = regexp.MustCompile("^([0-9][0-9][0-9][0-9])[-/\\.]?([0-9]?[0-9])?[-/\\.]?([0-9]?[0-9])?$")
 var dateDDMMYYYY = regexp.MustCompile("^([0-9]?[0-9])[-/\\.]?([0-9]?[0-9])[-/\\.]?([0-9][0-9][0-9][0-9])$")
-var date_desc_re = regexp.MustCompile("^(this|last|next)[\\._ \t]+(month|year|quarter)$")
+var date_desc_re = regexp.MustCompile("^(this|last|next)[\\._ \t]+(month|year|quarter|week|half[\\._ \t]*year|fiscal[\\._ \t]*year)$")
 
 func (date *Date) Set(value string) error {
-	*date = DateFromString(value)
+	d, err := ParseDate(value)
+	if err != nil {
+		return err
+	}
+	*date = d
 	return nil
 }
 
@@ -89,56 +179,13 @@ func (date *Date) Type() string {
 	return "date"
 }
 
+// Parse a date expression (see ParseDate), returning 0 if it is not valid
 func DateFromString(date string) Date {
-	mat := dateYYYYMMDD.FindStringSubmatch(date)
-	if mat != nil {
-		year, _ := strconv.Atoi(mat[1])
-		month, _ := strconv.Atoi(mat[2])
-		if month == 0 {
-			month = 1
-		}
-		day, _ := strconv.Atoi(mat[3])
-		if day == 0 {
-			day = 1
-		}
-		return Date(year*10000 + month*100 + day)
+	d, err := ParseDate(date)
+	if err != nil {
+		return 0
 	}
-
-	mat = dateDDMMYYYY.FindStringSubmatch(date)
-	if mat != nil {
-		day, _ := strconv.Atoi(mat[1])
-		month, _ := strconv.Atoi(mat[2])
-		year, _ := strconv.Atoi(mat[3])
-		return Date(year*10000 + month*100 + day)
-	}
-
-	mat = date_desc_re.FindStringSubmatch(date)
-	if mat != nil {
-		d := GetToday()
-		diff := 0
-		if mat[1] == "last" {
-			diff = -1
-		} else if mat[1] == "next" {
-			diff = 1
-		}
-
-		if mat[2] == "year" {
-			d = d.FloorYear(diff)
-		} else if mat[2] == "quarter" {
-			d = d.FloorQuarter(diff)
-		} else if mat[2] == "month" {
-			d = d.FloorMonth(diff)
-		}
-
-		return d
-	}
-
-	i, err := strconv.Atoi(date)
-	if err == nil {
-		return Date(i)
-	}
-
-	return 0
+	return d
 }
 
 func GetToday() Date {
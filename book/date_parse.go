@@ -0,0 +1,245 @@
+package book
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const dateExpressions = "YYYY-MM-DD, DD-MM-YYYY, YYYY-MM, YYYY, YYYYQn, YYYYHn, FYYYYY, today, " +
+	"today-30d, 3 months ago, last 90 days, ytd, or (this|last|next) (year|fiscal year|half year|quarter|month|week)"
+
+var dateQuarter = regexp.MustCompile("^([0-9]{4})[-/ ]?q([1-4])$")
+var dateHalf = regexp.MustCompile("^([0-9]{4})[-/ ]?h([12])$")
+var dateFiscal = regexp.MustCompile("^fy[-/ ]?([0-9]{4})$")
+var dateRelative = regexp.MustCompile("^(today|yesterday|tomorrow)(?:[ \t]*([+-])[ \t]*([0-9]+)[ \t]*([dwmqy]))?$")
+var dateAgo = regexp.MustCompile("^([0-9]+)[\\._ \t]*(day|week|month|quarter|year)s?[\\._ \t]+ago$")
+var dateLastN = regexp.MustCompile("^(last|next)[\\._ \t]+([0-9]+)[\\._ \t]+(day|week|month|quarter|year)s?$")
+var dateToDate = regexp.MustCompile("^(ytd|htd|qtd|mtd|wtd)$")
+var dateSeparators = regexp.MustCompile("[\\._ \t]+")
+
+// Floor types by unit of date expressions
+var dateUnitFloors = map[string]string{
+	"year":       "yearly",
+	"fiscalyear": "fiscal",
+	"halfyear":   "halfyearly",
+	"quarter":    "quarterly",
+	"month":      "monthly",
+	"week":       "weekly",
+	"y":          "yearly",
+	"h":          "halfyearly",
+	"q":          "quarterly",
+	"m":          "monthly",
+	"w":          "weekly",
+}
+
+// Return the number of days in the month of the year
+func daysInMonth(year int, month int) int {
+	if month == 2 && YearIsLeapYear(year-1) {
+		return 29
+	}
+	return monthDays[month-1]
+}
+
+// Return the date, checking the month and day are valid
+func validDate(year, month, day int) (Date, error) {
+	if month < 1 || month > 12 {
+		return 0, fmt.Errorf("month %d must be between 1 and 12", month)
+	}
+	if day < 1 || day > daysInMonth(year, month) {
+		return 0, fmt.Errorf("day %d must be between 1 and %d", day, daysInMonth(year, month))
+	}
+	return GetDate(year, month, day), nil
+}
+
+// Add n units (d, w, m, q or y, or the unit name) to a date
+func addUnits(date Date, n int, unit string) Date {
+	switch unit {
+	case "d", "day":
+		return date.AddDays(n)
+	case "w", "week":
+		return date.AddDays(n * 7)
+	case "m", "month":
+		return addMonths(date, n)
+	case "q", "quarter":
+		return addMonths(date, n*3)
+	}
+	return addMonths(date, n*12)
+}
+
+// Parse a date expression into the date range it covers, from start up to but
+// excluding end. A date covers one day, a month or quarter covers all of it, and
+// relative ranges such as ytd or last 90 days end after today. Fiscal years are
+// those of the fiscal period type (see FiscalPeriod).
+func parseDateRange(expr string, fiscal string) (Date, Date, error) {
+	s := strings.ToLower(strings.TrimSpace(expr))
+	invalid := func(err error) (Date, Date, error) {
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid date '%s': %w", expr, err)
+		}
+		return 0, 0, fmt.Errorf("invalid date '%s': must be %s", expr, dateExpressions)
+	}
+	day := func(d Date) (Date, Date, error) {
+		return d, d.AddDays(1), nil
+	}
+	today := GetToday()
+
+	// Numeric dates, trying DD-MM-YYYY if not a valid YYYY-MM-DD
+	var numErr error
+	if mat := dateYYYYMMDD.FindStringSubmatch(s); mat != nil {
+		year, _ := strconv.Atoi(mat[1])
+		month, _ := strconv.Atoi(mat[2])
+		dd, _ := strconv.Atoi(mat[3])
+		if mat[2] == "" {
+			return GetDate(year, 1, 1), GetDate(year+1, 1, 1), nil
+		} else if mat[3] == "" {
+			if d, err := validDate(year, month, 1); err == nil {
+				return d, d.FloorMonth(1), nil
+			} else {
+				numErr = err
+			}
+		} else if d, err := validDate(year, month, dd); err == nil {
+			return day(d)
+		} else {
+			numErr = err
+		}
+	}
+	if mat := dateDDMMYYYY.FindStringSubmatch(s); mat != nil {
+		dd, _ := strconv.Atoi(mat[1])
+		month, _ := strconv.Atoi(mat[2])
+		year, _ := strconv.Atoi(mat[3])
+		d, err := validDate(year, month, dd)
+		if err != nil {
+			return invalid(err)
+		}
+		return day(d)
+	}
+	if numErr != nil {
+		return invalid(numErr)
+	}
+
+	if mat := dateQuarter.FindStringSubmatch(s); mat != nil {
+		year, _ := strconv.Atoi(mat[1])
+		q, _ := strconv.Atoi(mat[2])
+		d := GetDate(year, (q-1)*3+1, 1)
+		return d, d.FloorQuarter(1), nil
+	}
+
+	if mat := dateHalf.FindStringSubmatch(s); mat != nil {
+		year, _ := strconv.Atoi(mat[1])
+		h, _ := strconv.Atoi(mat[2])
+		d := GetDate(year, (h-1)*6+1, 1)
+		return d, d.FloorHalfYear(1), nil
+	}
+
+	if mat := dateFiscal.FindStringSubmatch(s); mat != nil {
+		year, _ := strconv.Atoi(mat[1])
+		month, dd, _ := fiscalStart(fiscal)
+		d := GetDate(year, month, dd)
+		return d, d.FloorFiscalYear(month, dd, 1), nil
+	}
+
+	if mat := date_desc_re.FindStringSubmatch(s); mat != nil {
+		diff := map[string]int{"this": 0, "last": -1, "next": 1}[mat[1]]
+		by := dateUnitFloors[dateSeparators.ReplaceAllString(mat[2], "")]
+		if by == "fiscal" {
+			by = fiscal
+		}
+		return today.FloorDiff(by, diff), today.FloorDiff(by, diff+1), nil
+	}
+
+	if mat := dateRelative.FindStringSubmatch(s); mat != nil {
+		d := today.AddDays(map[string]int{"today": 0, "yesterday": -1, "tomorrow": 1}[mat[1]])
+		if mat[2] != "" {
+			n, _ := strconv.Atoi(mat[3])
+			if mat[2] == "-" {
+				n = -n
+			}
+			d = addUnits(d, n, mat[4])
+		}
+		return day(d)
+	}
+
+	if mat := dateAgo.FindStringSubmatch(s); mat != nil {
+		n, _ := strconv.Atoi(mat[1])
+		return day(addUnits(today, -n, mat[2]))
+	}
+
+	if mat := dateLastN.FindStringSubmatch(s); mat != nil {
+		n, _ := strconv.Atoi(mat[2])
+		if mat[1] == "last" {
+			return addUnits(today, -n, mat[3]).AddDays(1), today.AddDays(1), nil
+		}
+		return today, addUnits(today, n, mat[3]), nil
+	}
+
+	if mat := dateToDate.FindStringSubmatch(s); mat != nil {
+		by := dateUnitFloors[mat[1][:1]]
+		return today.Floor(by), today.AddDays(1), nil
+	}
+
+	return invalid(nil)
+}
+
+// Parse a date expression, returning an error if it is not valid.
+//
+// Expressions covering more than a day (eg 2024Q2, last month or ytd) return
+// their first day. Fiscal years start on January 1 (see ParseFiscalDate).
+func ParseDate(expr string) (Date, error) {
+	return ParseFiscalDate(expr, "fiscal")
+}
+
+// Parse a date expression (see ParseDate) with fiscal years (eg FY2025 or this
+// fiscal year) of the fiscal period type (see FiscalPeriod)
+func ParseFiscalDate(expr string, fiscal string) (Date, error) {
+	start, _, err := parseDateRange(expr, fiscal)
+	return start, err
+}
+
+// Parse an absolute date (YYYY-MM-DD or DD-MM-YYYY), returning an error for
+// other date expressions, so that dates in data files don't depend on today.
+func ParseAbsoluteDate(expr string) (Date, error) {
+	s := strings.TrimSpace(expr)
+	if mat := dateYYYYMMDD.FindStringSubmatch(s); (mat == nil || mat[3] == "") && !dateDDMMYYYY.MatchString(s) {
+		return 0, fmt.Errorf("invalid date '%s': must be YYYY-MM-DD or DD-MM-YYYY", expr)
+	}
+	return ParseDate(s)
+}
+
+// Parse a period expression into since (inclusive) and asof (exclusive) dates.
+//
+// The period is a date expression covering the range (eg 2024Q2, 2024-03 or
+// last 90 days), or a range "from..to" of date expressions from the start of
+// from up to the end of to (eg 2024Q1..2024Q3). Either side of a range may be
+// empty, returning 0 for no limit. Fiscal years start on January 1 (see
+// ParseFiscalPeriod).
+func ParsePeriod(expr string) (Date, Date, error) {
+	return ParseFiscalPeriod(expr, "fiscal")
+}
+
+// Parse a period expression (see ParsePeriod) with fiscal years of the fiscal
+// period type (see FiscalPeriod)
+func ParseFiscalPeriod(expr string, fiscal string) (Date, Date, error) {
+	idx := strings.Index(expr, "..")
+	if idx < 0 {
+		return parseDateRange(expr, fiscal)
+	}
+
+	var since, asof Date
+	var err error
+	if from := strings.TrimSpace(expr[:idx]); from != "" {
+		if since, _, err = parseDateRange(from, fiscal); err != nil {
+			return 0, 0, err
+		}
+	}
+	if to := strings.TrimSpace(expr[idx+2:]); to != "" {
+		if _, asof, err = parseDateRange(to, fiscal); err != nil {
+			return 0, 0, err
+		}
+	}
+	if since != 0 && asof != 0 && asof <= since {
+		return 0, 0, fmt.Errorf("invalid period '%s': ends before it starts", expr)
+	}
+	return since, asof, nil
+}
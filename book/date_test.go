@@ -45,3 +45,156 @@ func CheckDate(t *testing.T, date Date) {
 		t.Fatalf("Integrity check of %s => days %d => date %s doesn't match", date, date.AsDays(), ndate)
 	}
 }
+
+func TestFloorPeriods(t *testing.T) {
+	for _, tc := range []struct {
+		date  Date
+		by    string
+		diff  int
+		floor Date
+		label string
+	}{
+		{20250115, "weekly", 0, 20250113, "2025-W03"},
+		{20250101, "weekly", 0, 20241230, "2025-W01"},
+		{20250115, "weekly", 1, 20250120, "2025-W04"},
+		{20250815, "halfyearly", 0, 20250701, "2025 H2"},
+		{20250815, "halfyearly", -1, 20250101, "2025 H1"},
+		{20250215, "halfyearly", -1, 20240701, "2024 H2"},
+		{20250815, "quarterly", 0, 20250701, "2025 Q3"},
+	} {
+		got := tc.date.FloorDiff(tc.by, tc.diff)
+		if got != tc.floor {
+			t.Errorf("%s %s %d: expected %s, got %s", tc.date, tc.by, tc.diff, tc.floor, got)
+		}
+		if label := got.Label(tc.by); label != tc.label {
+			t.Errorf("%s %s: expected label %s, got %s", got, tc.by, tc.label, label)
+		}
+	}
+}
+
+func TestFiscalYear(t *testing.T) {
+	fiscal, err := FiscalPeriod(4, 6)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, tc := range []struct {
+		date  Date
+		diff  int
+		floor Date
+		label string
+	}{
+		{20250405, 0, 20240406, "FY2024/25"},
+		{20250406, 0, 20250406, "FY2025/26"},
+		{20250101, 1, 20250406, "FY2025/26"},
+		{20000101, 0, 19990406, "FY1999/00"},
+	} {
+		got := tc.date.FloorDiff(fiscal, tc.diff)
+		if got != tc.floor {
+			t.Errorf("%s %d: expected %s, got %s", tc.date, tc.diff, tc.floor, got)
+		}
+		if label := got.Label(fiscal); label != tc.label {
+			t.Errorf("%s: expected label %s, got %s", got, tc.label, label)
+		}
+	}
+
+	// Fiscal years starting on January 1 are calendar years
+	if got := Date(20250815).Floor("fiscal"); got != 20250101 {
+		t.Errorf("fiscal: expected 2025-01-01, got %s", got)
+	}
+	if label := Date(20250101).Label("fiscal"); label != "FY2025" {
+		t.Errorf("fiscal: expected label FY2025, got %s", label)
+	}
+
+	if d, err := ParseFiscalDate("this fiscal year", fiscal); err != nil || d != GetToday().FloorFiscalYear(4, 6, 0) {
+		t.Errorf("this fiscal year: got %s (%v)", d, err)
+	}
+	if since, asof, err := ParseFiscalPeriod("FY2024", fiscal); err != nil || since != 20240406 || asof != 20250406 {
+		t.Errorf("FY2024: got %s..%s (%v)", since, asof, err)
+	}
+	if d, err := ParseDate("FY2024"); err != nil || d != 20240101 {
+		t.Errorf("FY2024: got %s (%v)", d, err)
+	}
+	if _, err := FiscalPeriod(2, 30); err == nil {
+		t.Errorf("expected error for February 30")
+	}
+}
+
+func TestParseDate(t *testing.T) {
+	today := GetToday()
+	for _, tc := range []struct {
+		expr string
+		date Date
+	}{
+		{"2024-03-15", 20240315},
+		{"15/03/2024", 20240315},
+		{"20240315", 20240315},
+		{"2024-03", 20240301},
+		{"2024", 20240101},
+		{"2024Q2", 20240401},
+		{"2024h2", 20240701},
+		{"2024-02-29", 20240229},
+		{"today", today},
+		{"today-30d", today.AddDays(-30)},
+		{"today + 2w", today.AddDays(14)},
+		{"3 months ago", addMonths(today, -3)},
+		{"ytd", today.FloorYear(0)},
+		{"last 90 days", today.AddDays(-89)},
+		{"last month", today.FloorMonth(-1)},
+		{"next quarter", today.FloorQuarter(1)},
+	} {
+		d, err := ParseDate(tc.expr)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.expr, err)
+		} else if d != tc.date {
+			t.Errorf("%s: expected %s, got %s", tc.expr, tc.date, d)
+		}
+	}
+
+	for _, expr := range []string{"", "2023-02-29", "2024-13-01", "2024Q5", "yesterweek", "12", "last 3 fortnights"} {
+		if d, err := ParseDate(expr); err == nil {
+			t.Errorf("%s: expected error, got %s", expr, d)
+		}
+	}
+}
+
+func TestParseAbsoluteDate(t *testing.T) {
+	for _, expr := range []string{"2024-03-15", "15/03/2024", "20240315", " 2024.03.15 "} {
+		if d, err := ParseAbsoluteDate(expr); err != nil || d != 20240315 {
+			t.Errorf("%s: expected 2024-03-15, got %s (%v)", expr, d, err)
+		}
+	}
+
+	for _, expr := range []string{"", "date", "2024-03", "2024", "2024Q2", "today", "ytd", "3 months ago", "2024-02-30"} {
+		if d, err := ParseAbsoluteDate(expr); err == nil {
+			t.Errorf("%s: expected error, got %s", expr, d)
+		}
+	}
+}
+
+func TestParsePeriod(t *testing.T) {
+	for _, tc := range []struct {
+		expr        string
+		since, asof Date
+	}{
+		{"2024Q1..2024Q3", 20240101, 20241001},
+		{"2024-03", 20240301, 20240401},
+		{"2024", 20240101, 20250101},
+		{"2024-03-15", 20240315, 20240316},
+		{"2024-04..", 20240401, 0},
+		{"..2023", 0, 20240101},
+	} {
+		since, asof, err := ParsePeriod(tc.expr)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.expr, err)
+		} else if since != tc.since || asof != tc.asof {
+			t.Errorf("%s: expected %d..%d, got %d..%d", tc.expr, tc.since, tc.asof, since, asof)
+		}
+	}
+
+	for _, expr := range []string{"2024Q3..2024Q1", "2024Q1..bad"} {
+		if _, _, err := ParsePeriod(expr); err == nil {
+			t.Errorf("%s: expected error", expr)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package book
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRemoveSimilarDuplicates(t *testing.T) {
+	main := GetBook([]QuickBook{
+		{"2020-01-02", "TESCO STORES 1234", []QuickPosting{
+			{"Asset:Bank", "GBP", -25},
+			{"Expense:Groceries", "GBP", 25},
+		}},
+		{"2020-01-05", "COFFEE", []QuickPosting{
+			{"Asset:Bank", "GBP", -3},
+			{"Expense:Coffee", "GBP", 3},
+		}},
+	}, []QuickPrice{})
+
+	imported := GetBook([]QuickBook{
+		// Pending description, settled a day later
+		{"2020-01-01", "TESCO STORES", []QuickPosting{
+			{"Asset:Bank", "GBP", -25},
+			{"Expense:Default", "GBP", 25},
+		}},
+		// Two coffees on the same day -- only one already exists
+		{"2020-01-05", "COFFEE", []QuickPosting{
+			{"Asset:Bank", "GBP", -3},
+			{"Expense:Default", "GBP", 3},
+		}},
+		{"2020-01-05", "COFFEE", []QuickPosting{
+			{"Asset:Bank", "GBP", -3},
+			{"Expense:Default", "GBP", 3},
+		}},
+		// Different amount
+		{"2020-01-05", "TESCO STORES", []QuickPosting{
+			{"Asset:Bank", "GBP", -26},
+			{"Expense:Default", "GBP", 26},
+		}},
+	}, []QuickPrice{})
+
+	similar := func(a, b string) float64 {
+		if strings.HasPrefix(b, a) || strings.HasPrefix(a, b) {
+			return 1.0
+		}
+		return 0.0
+	}
+
+	matches := imported.RemoveSimilarDuplicatesOf(main, "Asset:Bank", 2, similar, 0.5)
+	if len(matches) != 2 {
+		t.Fatalf("expected 2 duplicates, got %d", len(matches))
+	}
+	if matches[0].Import.GetPayee() != "TESCO STORES" || matches[0].Existing.GetPayee() != "TESCO STORES 1234" {
+		t.Fatalf("unexpected first match %v to %v", matches[0].Import.GetPayee(), matches[0].Existing.GetPayee())
+	}
+	if matches[1].Existing.GetPayee() != "COFFEE" {
+		t.Fatalf("unexpected second match to %v", matches[1].Existing.GetPayee())
+	}
+
+	trans := imported.Transactions()
+	if len(trans) != 2 {
+		t.Fatalf("expected 2 remaining transactions, got %d", len(trans))
+	}
+	if trans[0].GetPayee() != "COFFEE (2)" || trans[1].GetPayee() != "TESCO STORES" {
+		t.Fatalf("unexpected remaining transactions %s and %s", trans[0].GetPayee(), trans[1].GetPayee())
+	}
+}
@@ -0,0 +1,158 @@
+package book
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+)
+
+// Change of the annual interest rate of a loan, for payments on or after the date
+type LoanRate struct {
+	Date Date
+	Rate *big.Rat
+}
+
+// Overpayment of a loan, made with the first payment on or after the date
+type LoanOverpayment struct {
+	Date   Date
+	Amount *big.Rat
+}
+
+// Repayment (annuity) loan with monthly payments
+//
+// Interest is charged monthly at the annual rate divided by twelve on the balance
+// before each payment. The payment is recalculated to repay the loan over the
+// remaining term when the rate changes or after an overpayment.
+type Loan struct {
+	Principal    *big.Rat
+	Rate         *big.Rat // Annual rate (eg 0.045 for 4.5%)
+	Term         int      // Months
+	Start        Date     // Date of the loan, payments start the following month
+	PaymentDay   int      // Day of the month for payments (default day of start)
+	Overpayments []LoanOverpayment
+	RateChanges  []LoanRate
+}
+
+// Period of a loan schedule
+type LoanPeriod struct {
+	Date        Date
+	Rate        *big.Rat // Annual rate
+	Payment     *big.Rat // Regular payment (interest and principal)
+	Interest    *big.Rat
+	Principal   *big.Rat // Principal of the regular payment
+	Overpayment *big.Rat
+	Balance     *big.Rat // Balance after the payment
+}
+
+// Check the loan is valid
+func (l *Loan) Validate() error {
+	if l.Principal == nil || l.Principal.Sign() <= 0 {
+		return fmt.Errorf("principal must be positive")
+	}
+	if l.Rate == nil || l.Rate.Sign() < 0 {
+		return fmt.Errorf("rate must not be negative")
+	}
+	if l.Term <= 0 {
+		return fmt.Errorf("term %d must be positive", l.Term)
+	}
+	if l.Start == 0 {
+		return fmt.Errorf("start date must be specified")
+	}
+	if l.PaymentDay < 0 || l.PaymentDay > 31 {
+		return fmt.Errorf("payment day %d must be between 1 and 31, or 0 for the start day", l.PaymentDay)
+	}
+	return nil
+}
+
+// Return the payment to repay balance over months at the monthly rate
+func annuityPayment(balance *big.Rat, rate *big.Rat, months int) *big.Rat {
+	if rate.Sign() == 0 {
+		return new(big.Rat).Quo(balance, big.NewRat(int64(months), 1))
+	}
+
+	// balance * rate / (1 - (1 + rate)^-months)
+	pow := big.NewRat(1, 1)
+	base := new(big.Rat).Add(big.NewRat(1, 1), rate)
+	for n := months; n > 0; n >>= 1 {
+		if n&1 == 1 {
+			pow.Mul(pow, base)
+		}
+		base.Mul(base, base)
+	}
+	denom := new(big.Rat).Sub(big.NewRat(1, 1), new(big.Rat).Inv(pow))
+	return new(big.Rat).Quo(new(big.Rat).Mul(balance, rate), denom)
+}
+
+// Return the monthly payment date months after the start
+func (l *Loan) paymentDate(months int) Date {
+	day := l.PaymentDay
+	if day == 0 {
+		day = int(l.Start % 100)
+	}
+	first := l.Start.FloorMonth(months)
+	if last := int(first.FloorMonth(1).AddDays(-1) % 100); day > last {
+		day = last
+	}
+	return first + Date(day-1)
+}
+
+// Calculate the schedule of the loan, with amounts rounded to decimals.
+//
+// The last payment repays the remaining balance, and the schedule ends early
+// if the loan is repaid by overpayments.
+func (l *Loan) Schedule(decimals int) []LoanPeriod {
+	rates := append(make([]LoanRate, 0, len(l.RateChanges)), l.RateChanges...)
+	sort.SliceStable(rates, func(i, j int) bool { return rates[i].Date < rates[j].Date })
+	overs := append(make([]LoanOverpayment, 0, len(l.Overpayments)), l.Overpayments...)
+	sort.SliceStable(overs, func(i, j int) bool { return overs[i].Date < overs[j].Date })
+
+	periods := make([]LoanPeriod, 0, l.Term)
+	balance := new(big.Rat).Set(l.Principal)
+	rate := l.Rate
+	var payment *big.Rat
+	for m := 1; m <= l.Term && balance.Sign() > 0; m++ {
+		date := l.paymentDate(m)
+
+		// Rate changes recalculate the payment
+		for len(rates) > 0 && rates[0].Date <= date {
+			rate = rates[0].Rate
+			rates = rates[1:]
+			payment = nil
+		}
+		monthly := new(big.Rat).Quo(rate, big.NewRat(12, 1))
+		if payment == nil {
+			payment = RoundAmount(annuityPayment(balance, monthly, l.Term-m+1), decimals)
+		}
+
+		interest := RoundAmount(new(big.Rat).Mul(balance, monthly), decimals)
+		principal := new(big.Rat).Sub(payment, interest)
+		if m == l.Term || principal.Cmp(balance) > 0 {
+			principal.Set(balance)
+		}
+		balance.Sub(balance, principal)
+
+		// Overpayments, limited to the balance, recalculate the payment
+		over := new(big.Rat)
+		for len(overs) > 0 && overs[0].Date <= date {
+			over.Add(over, overs[0].Amount)
+			overs = overs[1:]
+			payment = nil
+		}
+		if over.Cmp(balance) > 0 {
+			over.Set(balance)
+		}
+		balance.Sub(balance, over)
+
+		periods = append(periods, LoanPeriod{
+			Date:        date,
+			Rate:        rate,
+			Payment:     new(big.Rat).Add(interest, principal),
+			Interest:    interest,
+			Principal:   principal,
+			Overpayment: over,
+			Balance:     new(big.Rat).Set(balance),
+		})
+	}
+
+	return periods
+}
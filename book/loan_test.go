@@ -0,0 +1,57 @@
+package book
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestLoanSchedule(t *testing.T) {
+	loan := &Loan{
+		Principal:  big.NewRat(100000, 1),
+		Rate:       big.NewRat(6, 100),
+		Term:       360,
+		Start:      20200115,
+		PaymentDay: 31,
+	}
+	if err := loan.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	periods := loan.Schedule(2)
+	if len(periods) != 360 {
+		t.Fatalf("expected 360 periods, got %d", len(periods))
+	}
+	p := periods[0]
+	if p.Date != 20200229 || p.Payment.FloatString(2) != "599.55" || p.Interest.FloatString(2) != "500.00" || p.Principal.FloatString(2) != "99.55" {
+		t.Errorf("unexpected first period %s %s %s %s", p.Date, p.Payment.FloatString(2), p.Interest.FloatString(2), p.Principal.FloatString(2))
+	}
+	if periods[359].Balance.Sign() != 0 {
+		t.Errorf("expected loan repaid, got balance %s", periods[359].Balance.FloatString(2))
+	}
+
+	// Principal repaid sums to the loan
+	total := new(big.Rat)
+	for _, p := range periods {
+		total.Add(total, p.Principal)
+	}
+	if total.Cmp(loan.Principal) != 0 {
+		t.Errorf("expected principal repaid %s, got %s", loan.Principal.FloatString(2), total.FloatString(2))
+	}
+
+	// Overpayment reduces the payment, rate change increases it
+	loan.Overpayments = []LoanOverpayment{{20200301, big.NewRat(10000, 1)}}
+	loan.RateChanges = []LoanRate{{20210101, big.NewRat(7, 100)}}
+	periods = loan.Schedule(2)
+	if o := periods[1].Overpayment.FloatString(2); o != "10000.00" {
+		t.Errorf("expected overpayment in second period, got %s", o)
+	}
+	if p := periods[2].Payment; p.Cmp(big.NewRat(59955, 100)) >= 0 {
+		t.Errorf("expected lower payment after overpayment, got %s", p.FloatString(2))
+	}
+	if r := periods[11].Rate; r.Cmp(big.NewRat(7, 100)) != 0 {
+		t.Errorf("expected new rate for 2021 payments, got %s", r.FloatString(3))
+	}
+	if periods[len(periods)-1].Balance.Sign() != 0 {
+		t.Errorf("expected loan repaid after changes")
+	}
+}
@@ -0,0 +1,66 @@
+package book
+
+import (
+	"sort"
+)
+
+// Interned names (accounts and currencies) of a book, by ID.
+//
+// IDs are assigned as names are added, starting from 1 as 0 is for postings
+// without an ID yet. The ranks of the IDs are the string order of the names,
+// to sort postings by ID as they would be by name.
+type nameTable struct {
+	ids   map[string]int32
+	names []string
+	rank  []int32 // by ID, or nil if names were added since ranking
+}
+
+func newNameTable() *nameTable {
+	return &nameTable{
+		ids:   make(map[string]int32),
+		names: []string{""},
+	}
+}
+
+// Return a copy of the table, with the same IDs, that can be added to
+// independently. The ranks are shared as they are never modified.
+func (t *nameTable) copy() *nameTable {
+	ids := make(map[string]int32, len(t.ids))
+	for s, id := range t.ids {
+		ids[s] = id
+	}
+	names := make([]string, len(t.names))
+	copy(names, t.names)
+	return &nameTable{ids: ids, names: names, rank: t.rank}
+}
+
+// Return the interned name and its ID, adding it if needed
+func (t *nameTable) intern(s string) (string, int32) {
+	if id, ok := t.ids[s]; ok {
+		return t.names[id], id
+	}
+	id := int32(len(t.names))
+	t.ids[s] = id
+	t.names = append(t.names, s)
+	t.rank = nil
+	return s, id
+}
+
+// Return the ranks of the IDs, ranking the names if any were added
+func (t *nameTable) ranks() []int32 {
+	if t.rank != nil {
+		return t.rank
+	}
+	ids := make([]int32, len(t.names))
+	for i := range ids {
+		ids[i] = int32(i)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return t.names[ids[i]] < t.names[ids[j]]
+	})
+	t.rank = make([]int32, len(t.names))
+	for r, id := range ids {
+		t.rank[id] = int32(r)
+	}
+	return t.rank
+}
@@ -16,6 +16,15 @@ type Posting struct {
 	note  string
 	bal   *big.Rat
 
+	// Interned IDs of acct and ccy in the book (0 if not assigned yet)
+	acctID int32
+	ccyID  int32
+
+	// Source of the posting
+	file    string // default "" - not loaded from a file
+	line    int    // line in file
+	cleared bool   // cleared (reconciled) with statement
+
 	// New account levels:
 	acctlevel int    // default 0 - no indentation
 	acctterm  string // default - same as acct, otherwise term part
@@ -32,6 +41,8 @@ func (p Posting) GetAmount() *big.Rat        { return p.val }
 func (p Posting) GetCCY() string             { return p.ccy }
 func (p Posting) GetPostNote() string        { return p.note }
 func (p Posting) GetBalance() *big.Rat       { return p.bal }
+func (p Posting) GetSource() (string, int)   { return p.file, p.line }
+func (p Posting) IsCleared() bool            { return p.cleared }
 
 func (p Posting) byFactor(factor *big.Rat) Posting {
 	return p.byAcctDateFactor(p.acct, p.date, factor)
@@ -50,7 +61,10 @@ func (p Posting) byAcctDateFactor(new_account string, new_date Date, factor *big
 func (p Posting) dup(new_account string, new_date Date, new_amount *big.Rat) Posting {
 	new_post := p
 	new_post.date = new_date
-	new_post.acct = new_account
+	if new_account != p.acct {
+		new_post.acct = new_account
+		new_post.acctID = 0
+	}
 	new_post.acctterm = new_account
 	new_post.acctlevel = 0
 	new_post.val = new_amount
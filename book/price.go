@@ -1,8 +1,11 @@
 package book
 
 import (
+	"encoding/json"
+	"math"
 	"math/big"
 	"sort"
+	"time"
 )
 
 type Price struct {
@@ -31,8 +34,98 @@ func (d PriceType) String() string {
 	return [5]string{"OutOfRange", "Inferred", "Exact", "Trade", "None"}[d]
 }
 
+func (p Price) MarshalJSON() ([]byte, error) {
+
+	type JsonPrice struct {
+		Date  string  `json:"date"`
+		Price float64 `json:"price"`
+	}
+
+	v, _ := p.val.Float64()
+	return json.Marshal(&JsonPrice{
+		Date:  p.date.GetTime().Format(time.RFC3339),
+		Price: v,
+	})
+}
+
 type PriceList []Price
 
+// Summary statistics for a price list
+type PriceStats struct {
+	First  Date // Date of the first price
+	Last   Date // Date of the last price
+	Count  int  // Number of prices
+	MaxGap int  // Largest number of days between two consecutive prices
+}
+
+// Calculate the summary statistics of the price list. The list
+// must be sorted by date (as it is in a built price book).
+func (p PriceList) GetStats() PriceStats {
+	if len(p) == 0 {
+		return PriceStats{}
+	}
+
+	stats := PriceStats{
+		First: p[0].date,
+		Last:  p[len(p)-1].date,
+		Count: len(p),
+	}
+	for i := 1; i < len(p); i++ {
+		if gap := p[i].date.DaysSince(p[i-1].date); gap > stats.MaxGap {
+			stats.MaxGap = gap
+		}
+	}
+
+	return stats
+}
+
+// Suspicious price in a price list
+type PriceOutlier struct {
+	Date     Date     // Date of the suspicious price
+	Prev     *big.Rat // Last preceding price that is not an outlier
+	Price    *big.Rat // Suspicious price
+	Change   float64  // Relative change from the preceding price (0.1 is 10%)
+	Inverted bool     // Price looks like the inverse of the preceding price
+}
+
+// Find prices that jump by more than threshold (relative, 0.1 is 10%)
+// from the last preceding price that is not an outlier.
+//
+// A jump is flagged as inverted if the inverse of the price is within the
+// threshold of the preceding price -- typically a quote entered as ccy/unit
+// instead of unit/ccy.
+func (p PriceList) FindOutliers(threshold float64) []PriceOutlier {
+	outliers := make([]PriceOutlier, 0)
+	if len(p) == 0 {
+		return outliers
+	}
+
+	ref := p[0].val
+	for i := 1; i < len(p); i++ {
+		prev, _ := ref.Float64()
+		curr, _ := p[i].val.Float64()
+		if prev == 0 || curr == 0 {
+			ref = p[i].val
+			continue
+		}
+
+		change := (curr - prev) / math.Abs(prev)
+		if math.Abs(change) <= threshold {
+			ref = p[i].val
+			continue
+		}
+
+		outliers = append(outliers, PriceOutlier{
+			Date:     p[i].date,
+			Prev:     ref,
+			Price:    p[i].val,
+			Change:   change,
+			Inverted: math.Abs(1/curr-prev)/math.Abs(prev) <= threshold,
+		})
+	}
+	return outliers
+}
+
 // Want a price iterator that
 type PriceIterator struct {
 	idx int
@@ -148,7 +241,10 @@ func (p *priceBookBuilder) addPrice(date Date, unit string, ccy string, val *big
 	}
 }
 
-// Convert into a PriceBook that can be used for conversions
+// Convert into a PriceBook that can be used for conversions.
+//
+// Where there is more than one price for a pair on the same date (eg from
+// both the ledger and a price database file) the last one added is used.
 func (p *priceBookBuilder) build() *priceBook {
 	pb := &priceBook{
 		data: make(map[PricePair]PriceList),
@@ -162,7 +258,16 @@ func (p *priceBookBuilder) build() *priceBook {
 			}
 			return false
 		})
-		pb.data[cmap] = pl
+
+		// Merge prices on the same date -- the last added wins
+		last := 0
+		for i := 1; i < len(pl); i++ {
+			if pl[i].date != pl[last].date {
+				last++
+			}
+			pl[last] = pl[i]
+		}
+		pb.data[cmap] = pl[:last+1]
 	}
 	return pb
 }
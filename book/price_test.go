@@ -53,3 +53,57 @@ func TestPriceMore(t *testing.T) {
 	// Try again
 	CheckPrice(t, pb, 20160824, "GBP", "USD", big.NewRat(40, 17), PriceTypeInferred)
 }
+
+func TestPriceStatsAndOutliers(t *testing.T) {
+	pbb := newPriceBookBuilder()
+	pbb.addPrice(20160101, "GBP", "USD", big.NewRat(125, 100))
+	pbb.addPrice(20160102, "GBP", "USD", big.NewRat(126, 100))
+	pbb.addPrice(20160110, "GBP", "USD", big.NewRat(80, 100))
+	pbb.addPrice(20160111, "GBP", "USD", big.NewRat(127, 100))
+	pbb.addPrice(20160115, "GBP", "USD", big.NewRat(190, 100))
+	pl := pbb.build().getPrices("GBP", "USD")
+
+	stats := pl.GetStats()
+	if stats.First != 20160101 || stats.Last != 20160115 || stats.Count != 5 || stats.MaxGap != 8 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+
+	// The price after the inverted price is not an outlier
+	outliers := pl.FindOutliers(0.2)
+	if len(outliers) != 2 {
+		t.Fatalf("expected 2 outliers, got %d: %+v", len(outliers), outliers)
+	}
+	if outliers[0].Date != 20160110 || !outliers[0].Inverted {
+		t.Fatalf("expected inverted price on 20160110, got %+v", outliers[0])
+	}
+	if outliers[1].Date != 20160115 || outliers[1].Inverted || outliers[1].Prev.Cmp(big.NewRat(127, 100)) != 0 {
+		t.Fatalf("expected non-inverted jump from 1.27 on 20160115, got %+v", outliers[1])
+	}
+
+	// Jump of a price near 1 that is not the inverse
+	pbb = newPriceBookBuilder()
+	pbb.addPrice(20160101, "EUR", "USD", big.NewRat(90, 100))
+	pbb.addPrice(20160102, "EUR", "USD", big.NewRat(120, 100))
+	pbb.addPrice(20160103, "EUR", "USD", big.NewRat(111, 100))
+	outliers = pbb.build().getPrices("EUR", "USD").FindOutliers(0.05)
+	if len(outliers) != 2 || outliers[0].Inverted || !outliers[1].Inverted {
+		t.Fatalf("expected jump then inverted price, got %+v", outliers)
+	}
+
+	if len(PriceList{}.FindOutliers(0.2)) != 0 || (PriceList{}).GetStats().Count != 0 {
+		t.Fatalf("expected no stats or outliers for empty price list")
+	}
+}
+
+func TestPriceSameDate(t *testing.T) {
+	pbb := newPriceBookBuilder()
+	pbb.addPrice(20160201, "GBP", "USD", big.NewRat(2, 1))
+	pbb.addPrice(20160101, "GBP", "USD", big.NewRat(1, 1))
+	pbb.addPrice(20160201, "GBP", "USD", big.NewRat(3, 1))
+	pb := pbb.build()
+
+	if l := len(pb.getPrices("GBP", "USD")); l != 2 {
+		t.Fatalf("expected 2 prices after merging same date, got %d", l)
+	}
+	CheckPrice(t, pb, 20160201, "GBP", "USD", big.NewRat(3, 1), PriceTypeExact)
+}
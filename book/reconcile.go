@@ -0,0 +1,207 @@
+package book
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+)
+
+// Reconciliation of an account against a statement
+type Reconciliation struct {
+	Account    string
+	CCY        string
+	Date       Date      // Statement date (inclusive)
+	Cleared    *big.Rat  // Balance of cleared postings as of the statement date
+	Statement  *big.Rat  // Statement balance
+	Difference *big.Rat  // Statement balance less cleared balance
+	Uncleared  []Posting // Uncleared postings as of the statement date
+	Match      []Posting // Uncleared postings that close the difference (nil if none found)
+	Exhausted  bool      // Search for the match stopped at the limit (see MaxReconcileSearch)
+}
+
+// Largest window of uncleared postings to search for a match
+const MaxReconcileWindow = 100
+
+// Most subsets of uncleared postings tried in the search for a match
+const MaxReconcileSearch = 1000000
+
+// Reconcile an account in a currency against a statement balance as of
+// the statement date (inclusive).
+//
+// The cleared balance is the sum of the cleared postings. The difference
+// to the statement balance should be explained by uncleared postings that
+// have since cleared. The most recent window (1 to MaxReconcileWindow) uncleared
+// postings are searched for the smallest subset that sums exactly to the
+// difference, trying at most MaxReconcileSearch subsets.
+//
+// The book should keep the postings of each source separate (see
+// Builder.SetKeepSources), otherwise postings of a transaction to the account
+// are combined and matched as one.
+func (b *Book) Reconcile(acct string, ccy string, date Date, statement *big.Rat, window int) (*Reconciliation, error) {
+	if window <= 0 || window > MaxReconcileWindow {
+		return nil, fmt.Errorf("invalid window %d: must be 1 to %d", window, MaxReconcileWindow)
+	}
+
+	rec := &Reconciliation{
+		Account:   acct,
+		CCY:       ccy,
+		Date:      date,
+		Cleared:   big.NewRat(0, 1),
+		Statement: statement,
+		Uncleared: make([]Posting, 0, 10),
+	}
+
+	for _, trans := range b.Transactions() {
+		if trans.GetDate() > date {
+			break
+		}
+		for _, p := range trans {
+			if p.acct != acct || p.ccy != ccy {
+				continue
+			}
+			if p.cleared {
+				rec.Cleared.Add(rec.Cleared, p.val)
+			} else {
+				rec.Uncleared = append(rec.Uncleared, p)
+			}
+		}
+	}
+
+	rec.Difference = big.NewRat(0, 1).Sub(statement, rec.Cleared)
+
+	// Nothing to explain
+	if rec.Difference.Sign() == 0 {
+		rec.Match = make([]Posting, 0)
+		return rec, nil
+	}
+
+	// Most recent postings in the window
+	candidates := rec.Uncleared
+	if len(candidates) > window {
+		candidates = candidates[len(candidates)-window:]
+	}
+
+	amts := make([]*big.Rat, len(candidates))
+	for i, p := range candidates {
+		amts[i] = p.val
+	}
+	idx, exhausted, err := findSubsetSum(amts, rec.Difference, MaxReconcileSearch)
+	if err != nil {
+		return nil, err
+	}
+	rec.Exhausted = exhausted
+	if idx != nil {
+		rec.Match = make([]Posting, 0, len(idx))
+		for _, i := range idx {
+			rec.Match = append(rec.Match, candidates[i])
+		}
+	}
+
+	return rec, nil
+}
+
+// Find the smallest subset of amts that add up to exactly target, returning
+// the indexes of amts in the subset or nil if there is none.
+//
+// The search stops after trying budget subsets (as the search is exponential
+// in the number of amts), returning nil and true if it stopped.
+func findSubsetSum(amts []*big.Rat, target *big.Rat, budget int) ([]int, bool, error) {
+
+	// Scale all values to integers by a common denominator
+	denom := big.NewInt(1)
+	var gcd big.Int
+	for _, v := range append([]*big.Rat{target}, amts...) {
+		gcd.GCD(nil, nil, denom, v.Denom())
+		denom.Mul(denom, v.Denom())
+		denom.Quo(denom, &gcd)
+	}
+	toInt := func(v *big.Rat) (int64, error) {
+		var n big.Int
+		n.Mul(v.Num(), denom)
+		n.Quo(&n, v.Denom())
+		if !n.IsInt64() {
+			return 0, fmt.Errorf("amount %s too large for matching", v.FloatString(2))
+		}
+		return n.Int64(), nil
+	}
+
+	type value struct {
+		idx int
+		amt int64
+	}
+	values := make([]value, len(amts))
+	for i, v := range amts {
+		n, err := toInt(v)
+		if err != nil {
+			return nil, false, err
+		}
+		values[i] = value{i, n}
+	}
+	goal, err := toInt(target)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// Largest amounts first for better pruning
+	sort.SliceStable(values, func(i, j int) bool {
+		ai, aj := values[i].amt, values[j].amt
+		if ai < 0 {
+			ai = -ai
+		}
+		if aj < 0 {
+			aj = -aj
+		}
+		return ai > aj
+	})
+
+	// Remaining positive and negative totals (for pruning)
+	pos := make([]int64, len(values)+1)
+	neg := make([]int64, len(values)+1)
+	for i := len(values) - 1; i >= 0; i-- {
+		pos[i] = pos[i+1]
+		neg[i] = neg[i+1]
+		if values[i].amt > 0 {
+			pos[i] += values[i].amt
+		} else {
+			neg[i] += values[i].amt
+		}
+	}
+
+	// Depth-first search for exactly size items
+	chosen := make([]int, 0, len(values))
+	var search func(start int, size int, sum int64) bool
+	search = func(start int, size int, sum int64) bool {
+		if budget--; budget < 0 {
+			return false
+		}
+		if size == 0 {
+			return sum == goal
+		}
+		if len(values)-start < size {
+			return false
+		}
+		if goal-sum > pos[start] || goal-sum < neg[start] {
+			return false
+		}
+		for i := start; i < len(values); i++ {
+			chosen = append(chosen, values[i].idx)
+			if search(i+1, size-1, sum+values[i].amt) {
+				return true
+			}
+			chosen = chosen[:len(chosen)-1]
+		}
+		return false
+	}
+
+	for size := 1; size <= len(values); size++ {
+		if search(0, size, 0) {
+			sort.Ints(chosen)
+			return chosen, false, nil
+		}
+		if budget < 0 {
+			return nil, true, nil
+		}
+	}
+
+	return nil, false, nil
+}
@@ -0,0 +1,138 @@
+package book
+
+import (
+	"fmt"
+	"math/big"
+	"testing"
+)
+
+func TestReconcile(t *testing.T) {
+	b := NewBookBuilder()
+	add := func(date Date, payee string, amt int64, cleared bool) {
+		b.NewTransaction(date, payee, "")
+		b.SetCleared(cleared)
+		b.AddPosting("Asset:Bank", "GBP", big.NewRat(amt, 100), "")
+		b.AddPosting("Expense:Misc", "GBP", big.NewRat(-amt, 100), "")
+	}
+	add(20200101, "Opening", 100000, true)
+	add(20200105, "Shop A", -2550, false)
+	add(20200106, "Shop B", -1000, false)
+	add(20200107, "Shop C", -499, false)
+	add(20200110, "Refund", 1000, false)
+	add(20200201, "After", -5000, false)
+	book := b.Build()
+
+	// Statement shows Shop A and Shop C cleared
+	rec, err := book.Reconcile("Asset:Bank", "GBP", 20200131, big.NewRat(100000-2550-499, 100), 20)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if rec.Cleared.Cmp(big.NewRat(1000, 1)) != 0 {
+		t.Fatalf("expected cleared balance 1000, got %s", rec.Cleared.FloatString(2))
+	}
+	if len(rec.Uncleared) != 4 {
+		t.Fatalf("expected 4 uncleared postings, got %d", len(rec.Uncleared))
+	}
+	if len(rec.Match) != 2 || rec.Match[0].GetPayee() != "Shop A" || rec.Match[1].GetPayee() != "Shop C" {
+		t.Fatalf("expected match of Shop A and Shop C, got %v", rec.Match)
+	}
+
+	// Window too small to find it
+	rec, err = book.Reconcile("Asset:Bank", "GBP", 20200131, big.NewRat(100000-2550-499, 100), 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if rec.Match != nil {
+		t.Fatalf("expected no match in window, got %v", rec.Match)
+	}
+
+	// Nothing to reconcile
+	rec, _ = book.Reconcile("Asset:Bank", "GBP", 20200131, big.NewRat(1000, 1), 20)
+	if rec.Difference.Sign() != 0 || len(rec.Match) != 0 {
+		t.Fatalf("expected no difference, got %s", rec.Difference.FloatString(2))
+	}
+
+	// Window out of range
+	for _, window := range []int{0, -1, MaxReconcileWindow + 1} {
+		if _, err := book.Reconcile("Asset:Bank", "GBP", 20200131, big.NewRat(1000, 1), window); err == nil {
+			t.Fatalf("expected error for window %d", window)
+		}
+	}
+}
+
+func TestReconcileExhausted(t *testing.T) {
+	b := NewBookBuilder()
+	b.NewTransaction(20200101, "Opening", "")
+	b.SetCleared(true)
+	b.AddPosting("Asset:Bank", "GBP", big.NewRat(1000, 1), "")
+	b.AddPosting("Equity:Opening", "GBP", big.NewRat(-1000, 1), "")
+	for i := 0; i < 60; i++ {
+		b.NewTransaction(20200102, fmt.Sprintf("Shop %d", i), "")
+		b.SetCleared(false)
+		amt := int64(2 - 4*(i%2))
+		b.AddPosting("Asset:Bank", "GBP", big.NewRat(amt, 100), "")
+		b.AddPosting("Expense:Misc", "GBP", big.NewRat(-amt, 100), "")
+	}
+	book := b.Build()
+
+	// No set of even amounts adds up to an odd difference
+	rec, err := book.Reconcile("Asset:Bank", "GBP", 20200131, big.NewRat(100000-1, 100), 60)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if rec.Match != nil || !rec.Exhausted {
+		t.Fatalf("expected search to stop without a match, got %v", rec.Match)
+	}
+}
+
+func TestReconcileSources(t *testing.T) {
+	build := func(keep bool, cleared bool) *Book {
+		b := NewBookBuilder()
+		b.SetKeepSources(keep)
+		b.NewTransaction(20200101, "Opening", "")
+		b.SetCleared(true)
+		b.AddPosting("Asset:Bank", "GBP", big.NewRat(1000, 1), "")
+		b.AddPosting("Equity:Opening", "GBP", big.NewRat(-1000, 1), "")
+
+		// Two lines of the statement in one transaction
+		b.NewTransaction(20200105, "Deposit", "")
+		b.SetCleared(cleared)
+		b.SetSource("main.ledger", 10)
+		b.AddPosting("Asset:Bank", "GBP", big.NewRat(100, 1), "")
+		b.SetCleared(false)
+		b.SetSource("main.ledger", 11)
+		b.AddPosting("Asset:Bank", "GBP", big.NewRat(50, 1), "")
+		b.SetSource("main.ledger", 12)
+		b.AddPosting("Income:Misc", "GBP", big.NewRat(-150, 1), "")
+		return b.Build()
+	}
+
+	for _, tc := range []struct {
+		cleared   bool
+		statement int64
+		line      int
+	}{
+		{false, 1100, 10},
+		{true, 1150, 11},
+	} {
+		rec, err := build(true, tc.cleared).Reconcile("Asset:Bank", "GBP", 20200131, big.NewRat(tc.statement, 1), 20)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(rec.Match) != 1 {
+			t.Fatalf("statement %d: expected one posting to match, got %v", tc.statement, rec.Match)
+		}
+		if _, line := rec.Match[0].GetSource(); line != tc.line {
+			t.Errorf("statement %d: expected match of line %d, got %d", tc.statement, tc.line, line)
+		}
+	}
+
+	// Combined, the postings can't be matched one by one
+	rec, err := build(false, false).Reconcile("Asset:Bank", "GBP", 20200131, big.NewRat(1100, 1), 20)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if rec.Match != nil {
+		t.Errorf("expected no match of combined postings, got %v", rec.Match)
+	}
+}
@@ -2,6 +2,7 @@ package book
 
 import (
 	"encoding/json"
+	"fmt"
 	"math/big"
 	"regexp"
 	"strings"
@@ -17,9 +18,10 @@ type RegistryEntry struct {
 	CounterAccount string   `json:"counterAccount,omitempty"` // May be a single or semi-colon-delimited list
 	Amount         *big.Rat `json:"amount"`
 	CCY            string   `json:"ccy"`
-	Balance        *big.Rat `json:"balance"` // Balance for CCY across all extract accounts
-	Note           string   `json:"note"`    // Note for posting
-	TNote          string   `json:"tnote"`   // Transaction note
+	Balance        *big.Rat `json:"balance"`         // Balance for CCY across all extract accounts
+	Note           string   `json:"note"`            // Note for posting
+	TNote          string   `json:"tnote"`           // Transaction note
+	Count          int      `json:"count,omitempty"` // Number of entries aggregated (see ByPeriod)
 
 	// Conversions to base
 	BaseCCY     string    // BaseCCY (always the same)
@@ -28,9 +30,28 @@ type RegistryEntry struct {
 	BaseBalance *big.Rat  // Total balance to date across all CCYs converted to BaseCCY (recalculated each time)
 }
 
-func (rep *RegistryReport) FilterByDate(mindate, maxdate string) {
-	min := DateFromString(mindate)
-	max := DateFromString(maxdate)
+// Filter the report to entries since mindate and before maxdate, either of which
+// may be empty for no limit
+func (rep *RegistryReport) FilterByDate(mindate, maxdate string) error {
+	var min, max Date
+	var err error
+	if mindate != "" {
+		if min, err = ParseDate(mindate); err != nil {
+			return fmt.Errorf("begin date: %w", err)
+		}
+	}
+	if maxdate != "" {
+		if max, err = ParseDate(maxdate); err != nil {
+			return fmt.Errorf("asof date: %w", err)
+		}
+	}
+	rep.FilterByDates(min, max)
+	return nil
+}
+
+// Filter the report to entries since min and before max, either of which may be
+// 0 for no limit
+func (rep *RegistryReport) FilterByDates(min, max Date) {
 	if min == 0 && max == 0 {
 		return
 	}
@@ -54,13 +75,77 @@ func (rep *RegistryReport) FilterByDate(mindate, maxdate string) {
 	}
 }
 
-func (b *Book) ExtractRegister(baseccy string, re *regexp.Regexp, split bool) RegistryReport {
+// Aggregate the report into an entry for each period (see Date.Floor) and
+// currency, dated the start of the period with the period label as the payee.
+// Amounts are the totals for the period and balances the closing balances.
+func (rep RegistryReport) ByPeriod(by string) RegistryReport {
+	data := make([]*RegistryEntry, 0, 100)
+	period := Date(0)
+	ccys := make(map[string]*RegistryEntry)
+	accts := make(map[*RegistryEntry]map[string]bool)
+
+	for _, r := range rep {
+
+		// Start a new period
+		if d := r.Date.Floor(by); d != period {
+			period = d
+			ccys = make(map[string]*RegistryEntry)
+		}
+
+		e, ok := ccys[r.CCY]
+		if !ok {
+			e = &RegistryEntry{
+				Date:       period,
+				Payee:      period.Label(by),
+				Amount:     big.NewRat(0, 1),
+				CCY:        r.CCY,
+				BaseCCY:    r.BaseCCY,
+				BaseAmount: big.NewRat(0, 1),
+				BaseSource: PriceTypeExact,
+			}
+			ccys[r.CCY] = e
+			accts[e] = make(map[string]bool)
+			data = append(data, e)
+		}
+
+		e.Amount.Add(e.Amount, r.Amount)
+		e.BaseAmount.Add(e.BaseAmount, r.BaseAmount)
+		e.BaseSource = e.BaseSource.merge(r.BaseSource)
+		e.Balance = r.Balance
+		e.Count++
+
+		// The base balance is across all currencies
+		for _, pe := range ccys {
+			pe.BaseBalance = r.BaseBalance
+		}
+		if !accts[e][r.Account] {
+			accts[e][r.Account] = true
+			if e.Account == "" {
+				e.Account = r.Account
+			} else {
+				e.Account = e.Account + ";" + r.Account
+			}
+		}
+	}
+
+	return data
+}
+
+// Extract the register of the postings to accounts matching re, with the
+// counteraccounts of each posting, running balances by currency and the running
+// balance converted to baseccy.
+//
+// If split, postings with several counteraccounts are split into an entry for
+// each counteraccount. If related, the entries are for the counter-postings
+// instead (always split), with the matching account as the counteraccount and
+// running balances of the counter-postings.
+func (b *Book) ExtractRegister(baseccy string, re *regexp.Regexp, split bool, related bool) RegistryReport {
 	data := make([]*RegistryEntry, 0, 100)
 	bals := make(map[string]*big.Rat)
 	baseBal := big.NewRat(0, 1)
 
 	// Compact the book first
-	b.compact()
+	b.ensureBalances()
 
 	// Iterate each transaction
 	for _, trans := range b.trans {
@@ -75,6 +160,7 @@ func (b *Book) ExtractRegister(baseccy string, re *regexp.Regexp, split bool) Re
 			// Only counteraccounts of the same currency in the opposite direction are of interest!
 			caccts := make([]string, 0, 1)
 			camts := make([]*big.Rat, 0, 1)
+			cnotes := make([]string, 0, 1)
 			sumamt := big.NewRat(0, 1)
 			for _, tp := range trans {
 				if tp.GetAccount() == p.GetAccount() {
@@ -86,13 +172,25 @@ func (b *Book) ExtractRegister(baseccy string, re *regexp.Regexp, split bool) Re
 				if tp.GetCCY() != p.GetCCY() {
 					continue
 				}
+				if related && re.MatchString(tp.GetAccount()) {
+					continue
+				}
 				caccts = append(caccts, tp.GetAccount())
 				camts = append(camts, big.NewRat(0, 1).Set(tp.GetAmount()))
+				cnotes = append(cnotes, tp.GetPostNote())
 				sumamt.Add(sumamt, tp.GetAmount())
 			}
 
-			// If we need to re-combine, do so
-			if len(caccts) > 1 && !split {
+			// Without counter-postings the amount is unallocated
+			if len(caccts) == 0 {
+				if related {
+					continue
+				}
+				caccts = append(caccts, "")
+				camts = append(camts, big.NewRat(0, 1).Set(p.GetAmount()))
+			} else if len(caccts) > 1 && !split && !related {
+
+				// If we need to re-combine, do so
 				caccts[0] = strings.Join(caccts, ";")
 				caccts = caccts[0:1]
 				camts[0] = big.NewRat(0, 1).Set(p.GetAmount())
@@ -119,11 +217,17 @@ func (b *Book) ExtractRegister(baseccy string, re *regexp.Regexp, split bool) Re
 			// Allocate them
 			for i, v := range caccts {
 
+				// Swap to the counter-posting if related
+				acct, cacct, amt, note := p.GetAccount(), v, camts[i], p.GetPostNote()
+				if related {
+					acct, cacct, amt, note = v, p.GetAccount(), camts[i].Neg(camts[i]), cnotes[i]
+				}
+
 				// Add to balance
-				bal = bal.Add(bal, camts[i])
+				bal = bal.Add(bal, amt)
 
 				// Calculate baseAmt, baseSource, and baseBal
-				baseAmt := camts[i]
+				baseAmt := amt
 				var baseSource PriceType = PriceTypeExact
 				if p.GetCCY() != baseccy {
 					r := trans.InferRate(baseccy, p.GetCCY())
@@ -132,7 +236,7 @@ func (b *Book) ExtractRegister(baseccy string, re *regexp.Regexp, split bool) Re
 					} else {
 						r, baseSource = b.GetPrice(trans.GetDate(), baseccy, p.GetCCY())
 					}
-					baseAmt = big.NewRat(0, 1).Mul(r, camts[i])
+					baseAmt = big.NewRat(0, 1).Mul(r, amt)
 				}
 				baseBal = baseBal.Add(baseBal, baseAmt)
 
@@ -140,11 +244,11 @@ func (b *Book) ExtractRegister(baseccy string, re *regexp.Regexp, split bool) Re
 				data = append(data, &RegistryEntry{
 					Date:           trans.GetDate(),
 					Payee:          trans.GetPayee(),
-					Account:        p.GetAccount(),
-					CounterAccount: v,
-					Amount:         camts[i],
+					Account:        acct,
+					CounterAccount: cacct,
+					Amount:         amt,
 					CCY:            p.GetCCY(),
-					Note:           p.GetPostNote(),
+					Note:           note,
 					TNote:          p.GetTransactionNote(),
 					Balance:        big.NewRat(0, 1).Set(bal),
 					BaseCCY:        baseccy,
@@ -171,6 +275,7 @@ func (re RegistryEntry) MarshalJSON() ([]byte, error) {
 		Balance        float64 `json:"balance"` // Balance for CCY across all extract accounts
 		Note           string  `json:"note"`    // Note for posting
 		TNote          string  `json:"tnote"`   // Transaction note
+		Count          int     `json:"count,omitempty"`
 
 		// Conversions to base
 		BaseCCY     string  // BaseCCY (always the same)
@@ -194,6 +299,7 @@ func (re RegistryEntry) MarshalJSON() ([]byte, error) {
 		Balance:        bal,
 		Note:           re.Note,
 		TNote:          re.TNote,
+		Count:          re.Count,
 
 		BaseCCY:     re.BaseCCY,
 		BaseAmount:  baseAmt,
@@ -0,0 +1,83 @@
+package book
+
+import (
+	"math/big"
+	"regexp"
+	"testing"
+)
+
+func getRegistryBook() *Book {
+	return GetBook([]QuickBook{
+		{"2020-01-01", "Opening", []QuickPosting{
+			{"Asset:Bank", "GBP", 1000},
+			{"Equity:Opening", "GBP", -1000},
+		}},
+		{"2020-01-15", "Shop", []QuickPosting{
+			{"Expense:Food", "GBP", 30},
+			{"Expense:Travel", "GBP", 200},
+			{"Asset:Bank", "GBP", -230},
+		}},
+		{"2020-02-10", "Transfer", []QuickPosting{
+			{"Asset:Savings", "GBP", 100},
+			{"Asset:Bank", "GBP", -100},
+		}},
+		{"2020-04-01", "Shop", []QuickPosting{
+			{"Expense:Food", "GBP", 20},
+			{"Asset:Bank", "GBP", -20},
+		}},
+	}, nil)
+}
+
+func TestRegisterRelated(t *testing.T) {
+	rep := getRegistryBook().ExtractRegister("GBP", regexp.MustCompile("^Asset:"), false, true)
+
+	// Transfers between matching accounts are not related
+	exp := []struct {
+		acct  string
+		cacct string
+		amt   int64
+		bal   int64
+	}{
+		{"Equity:Opening", "Asset:Bank", -1000, -1000},
+		{"Expense:Food", "Asset:Bank", 30, -970},
+		{"Expense:Travel", "Asset:Bank", 200, -770},
+		{"Expense:Food", "Asset:Bank", 20, -750},
+	}
+	if len(rep) != len(exp) {
+		t.Fatalf("expected %d entries, got %d", len(exp), len(rep))
+	}
+	for i, e := range exp {
+		r := rep[i]
+		if r.Account != e.acct || r.CounterAccount != e.cacct || r.Amount.Cmp(big.NewRat(e.amt, 1)) != 0 || r.Balance.Cmp(big.NewRat(e.bal, 1)) != 0 {
+			t.Errorf("entry %d: expected %s %s %d %d, got %s %s %s %s", i, e.acct, e.cacct, e.amt, e.bal,
+				r.Account, r.CounterAccount, r.Amount.FloatString(0), r.Balance.FloatString(0))
+		}
+	}
+}
+
+func TestRegisterByPeriod(t *testing.T) {
+	rep := getRegistryBook().ExtractRegister("GBP", regexp.MustCompile("^Asset:Bank$"), false, false)
+	rep = rep.ByPeriod("quarterly")
+
+	exp := []struct {
+		date  Date
+		payee string
+		count int
+		amt   int64
+		bal   int64
+	}{
+		{20200101, "2020 Q1", 3, 670, 670},
+		{20200401, "2020 Q2", 1, -20, 650},
+	}
+	if len(rep) != len(exp) {
+		t.Fatalf("expected %d entries, got %d", len(exp), len(rep))
+	}
+	for i, e := range exp {
+		r := rep[i]
+		if r.Date != e.date || r.Payee != e.payee || r.Count != e.count || r.Account != "Asset:Bank" ||
+			r.Amount.Cmp(big.NewRat(e.amt, 1)) != 0 || r.Balance.Cmp(big.NewRat(e.bal, 1)) != 0 || r.BaseBalance.Cmp(big.NewRat(e.bal, 1)) != 0 {
+			t.Errorf("entry %d: expected %d %s %d %d %d, got %d %s %d %s %s", i, e.date, e.payee, e.count, e.amt, e.bal,
+				r.Date, r.Payee, r.Count, r.Amount.FloatString(0), r.Balance.FloatString(0))
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package book
+
+import (
+	"math/big"
+	"regexp"
+	"testing"
+)
+
+func TestRevalue(t *testing.T) {
+	b := NewBookBuilder()
+	b.NewTransaction(20200101, "Buy USD", "")
+	b.AddPosting("Asset:USD", "USD", big.NewRat(100, 1), "")
+	b.AddPosting("Asset:Bank", "GBP", big.NewRat(-80, 1), "")
+	b.AddPosting("Equity:Exchange", "USD", big.NewRat(-100, 1), "")
+	b.AddPosting("Equity:Exchange", "GBP", big.NewRat(80, 1), "")
+	b.NewTransaction(20200201, "Spend USD", "")
+	b.AddPosting("Asset:USD", "USD", big.NewRat(-50, 1), "")
+	b.AddPosting("Expense:Shopping", "USD", big.NewRat(50, 1), "")
+	b.AddPrice(20200101, "USD", "GBP", big.NewRat(8, 10))
+	b.AddPrice(20200201, "USD", "GBP", big.NewRat(9, 10))
+	b.AddPrice(20200301, "USD", "GBP", big.NewRat(7, 10))
+	book := b.Build()
+
+	book.Revalue(20200301, "GBP", regexp.MustCompile("^Asset:"), "Equity:Unrealized")
+
+	// Cost is 100 * 0.8 - 50 * 0.9 = 35, market value 50 * 0.7 = 35
+	trans := book.Transactions()
+	if len(trans) != 2 {
+		t.Fatalf("expected no revaluation, got %d transactions", len(trans))
+	}
+
+	book.Revalue(20200201, "GBP", regexp.MustCompile("^Asset:"), "Equity:Unrealized")
+
+	// Cost is 80 - 45 = 35, market value 50 * 0.9 = 45
+	trans = book.Transactions()
+	if len(trans) != 3 || trans[1].GetPayee() != RevaluePayee {
+		t.Fatalf("expected revaluation transaction, got %d transactions", len(trans))
+	}
+	for _, p := range trans[1] {
+		exp := big.NewRat(10, 1)
+		if p.GetAccount() == "Equity:Unrealized" {
+			exp.Neg(exp)
+		}
+		if p.GetCCY() != "GBP" || p.GetAmount().Cmp(exp) != 0 {
+			t.Errorf("unexpected revaluation posting %s %s %s", p.GetAccount(), p.GetCCY(), p.GetAmount().FloatString(2))
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package book
+
+import (
+	"math/big"
+)
+
+// Round an amount to the number of decimals (half away from zero)
+func RoundAmount(amt *big.Rat, decimals int) *big.Rat {
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil)
+
+	// Scaled numerator and denominator: amt * scale = num / den
+	num := new(big.Int).Mul(amt.Num(), scale)
+	den := amt.Denom()
+
+	// Round half away from zero: (|num| * 2 + den) / (den * 2)
+	neg := num.Sign() < 0
+	num.Abs(num)
+	num.Mul(num, big.NewInt(2))
+	num.Add(num, den)
+	q := new(big.Int).Quo(num, new(big.Int).Mul(den, big.NewInt(2)))
+	if neg {
+		q.Neg(q)
+	}
+
+	return new(big.Rat).SetFrac(q, scale)
+}
+
+// Split an amount into parts by fractions, rounded to the number of decimals.
+//
+// The fractions should sum to one. Each part except the last is rounded and
+// the last part is the remainder, so the parts always sum exactly to amt.
+func SplitAmount(amt *big.Rat, fractions []*big.Rat, decimals int) []*big.Rat {
+	parts := make([]*big.Rat, len(fractions))
+	rest := new(big.Rat).Set(amt)
+	for i, f := range fractions {
+		if i == len(fractions)-1 {
+			parts[i] = rest
+			break
+		}
+		parts[i] = RoundAmount(new(big.Rat).Mul(amt, f), decimals)
+		rest.Sub(rest, parts[i])
+	}
+	return parts
+}
@@ -0,0 +1,41 @@
+package book
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestRoundAmount(t *testing.T) {
+	for _, c := range []struct {
+		amt      string
+		decimals int
+		expected string
+	}{
+		{"1.005", 2, "1.01"},
+		{"-1.005", 2, "-1.01"},
+		{"1.004", 2, "1.00"},
+		{"2.5", 0, "3"},
+		{"-2.5", 0, "-3"},
+		{"1/3", 4, "0.3333"},
+	} {
+		amt, _ := new(big.Rat).SetString(c.amt)
+		if r := RoundAmount(amt, c.decimals).FloatString(c.decimals); r != c.expected {
+			t.Errorf("round %s to %d: expected %s, got %s", c.amt, c.decimals, c.expected, r)
+		}
+	}
+}
+
+func TestSplitAmount(t *testing.T) {
+	parts := SplitAmount(big.NewRat(100, 1), []*big.Rat{big.NewRat(1, 3), big.NewRat(1, 3), big.NewRat(1, 3)}, 2)
+	expected := []string{"33.33", "33.33", "33.34"}
+	sum := new(big.Rat)
+	for i, p := range parts {
+		if p.FloatString(2) != expected[i] {
+			t.Errorf("part %d: expected %s, got %s", i, expected[i], p.FloatString(2))
+		}
+		sum.Add(sum, p)
+	}
+	if sum.Cmp(big.NewRat(100, 1)) != 0 {
+		t.Errorf("expected parts to sum to 100, got %s", sum.FloatString(2))
+	}
+}
@@ -0,0 +1,212 @@
+package book
+
+import (
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+)
+
+// Methods for schedules
+var ScheduleMethods = []string{"straight", "declining", "sumofyears", "amortise"}
+
+// Depreciation or amortisation schedule
+//
+// Depreciation methods (straight, declining or sumofyears) spread the amount over
+// the number of periods (monthly, quarterly or yearly) from the posting date, with
+// subsequent periods starting at the beginning of each period.
+//
+// Declining is double-declining balance, switching to straight-line over the remaining
+// periods when that is larger, so the full amount is depreciated.
+//
+// Amortise spreads the amount daily from Start (or the posting date if zero) up to but
+// excluding End (or Periods months after the start if zero), with a period for each month.
+type Schedule struct {
+	Method  string
+	Period  string
+	Periods int
+	Start   Date
+	End     Date
+}
+
+// Period of a schedule
+type ScheduleEntry struct {
+	Date   Date
+	Amount *big.Rat
+}
+
+// Posting and its schedule
+type ScheduledPosting struct {
+	Posting
+	Holding string
+	Entries []ScheduleEntry
+}
+
+// Check the schedule is valid
+func (s Schedule) Validate() error {
+	switch s.Method {
+	case "straight", "declining", "sumofyears":
+		if s.Periods <= 0 {
+			return fmt.Errorf("periods %d must be positive", s.Periods)
+		}
+		if s.Period != "monthly" && s.Period != "quarterly" && s.Period != "yearly" {
+			return fmt.Errorf("period '%s' must be monthly, quarterly or yearly", s.Period)
+		}
+	case "amortise":
+		if s.End == 0 && s.Periods <= 0 {
+			return fmt.Errorf("amortise needs an end date or positive months")
+		}
+		if s.End != 0 && s.Start != 0 && s.End <= s.Start {
+			return fmt.Errorf("amortise end %s must be after start %s", s.End, s.Start)
+		}
+	default:
+		return fmt.Errorf("method '%s' must be one of %s", s.Method, strings.Join(ScheduleMethods, ", "))
+	}
+	return nil
+}
+
+// Plan the schedule for an amount on a date rounded to decimals.
+//
+// The amounts of the periods are rounded, with the remainder in the last period,
+// so they sum exactly to the amount.
+func (s Schedule) Plan(amt *big.Rat, date Date, decimals int) []ScheduleEntry {
+	if s.Method == "amortise" {
+		return s.planAmortise(amt, date, decimals)
+	}
+
+	// Fraction for each period
+	n := int64(s.Periods)
+	fractions := make([]*big.Rat, 0, n)
+	switch s.Method {
+	case "straight":
+		for i := int64(0); i < n; i++ {
+			fractions = append(fractions, big.NewRat(1, n))
+		}
+	case "sumofyears":
+		sum := n * (n + 1) / 2
+		for i := int64(0); i < n; i++ {
+			fractions = append(fractions, big.NewRat(n-i, sum))
+		}
+	case "declining":
+		rate := big.NewRat(2, n)
+		remaining := big.NewRat(1, 1)
+		for i := int64(0); i < n; i++ {
+			f := new(big.Rat).Mul(remaining, rate)
+			if sl := new(big.Rat).Quo(remaining, big.NewRat(n-i, 1)); sl.Cmp(f) > 0 {
+				f = sl
+			}
+			if f.Cmp(remaining) > 0 {
+				f.Set(remaining)
+			}
+			remaining.Sub(remaining, f)
+			fractions = append(fractions, f)
+		}
+	}
+
+	parts := SplitAmount(amt, fractions, decimals)
+	entries := make([]ScheduleEntry, 0, n)
+	d := date
+	for _, part := range parts {
+		entries = append(entries, ScheduleEntry{d, part})
+		d = d.FloorDiff(s.Period, 1)
+	}
+	return entries
+}
+
+func (s Schedule) planAmortise(amt *big.Rat, date Date, decimals int) []ScheduleEntry {
+	start := s.Start
+	if start == 0 {
+		start = date
+	}
+	end := s.End
+	if end == 0 {
+		end = addMonths(start, s.Periods)
+	}
+	total := int64(end.DaysSince(start))
+
+	// Days in each month
+	dates := make([]Date, 0)
+	fractions := make([]*big.Rat, 0)
+	for d := start; d < end; {
+		next := d.FloorMonth(1)
+		if next > end {
+			next = end
+		}
+		dates = append(dates, d)
+		fractions = append(fractions, big.NewRat(int64(next.DaysSince(d)), total))
+		d = next
+	}
+
+	parts := SplitAmount(amt, fractions, decimals)
+	entries := make([]ScheduleEntry, 0, len(parts))
+	for i, part := range parts {
+		entries = append(entries, ScheduleEntry{dates[i], part})
+	}
+	return entries
+}
+
+// Add months to a date, limiting the day to the end of the month
+func addMonths(date Date, months int) Date {
+	m := int(date/10000)*12 + int((date/100)%100) - 1 + months
+	year, month, day := m/12, m%12+1, int(date%100)
+	if last := GetDate(year, month, 1).FloorMonth(1).AddDays(-1); day > int(last%100) {
+		day = int(last % 100)
+	}
+	return GetDate(year, month, day)
+}
+
+// Return the schedules for all postings that match search_acct regular expression,
+// to be held in replace_acct.
+func (b *Book) Schedules(search_acct string, replace_acct string, s Schedule) []ScheduledPosting {
+	b.ensureBalances()
+	return b.schedules(search_acct, replace_acct, s)
+}
+
+func (b *Book) schedules(search_acct string, replace_acct string, s Schedule) []ScheduledPosting {
+	re := regexp.MustCompile(search_acct)
+	scheduled := make([]ScheduledPosting, 0)
+	for _, p := range b.post {
+		if !re.MatchString(p.GetAccount()) {
+			continue
+		}
+		dec, ok := b.ccy[p.ccy]
+		if !ok {
+			dec = 2
+		}
+		scheduled = append(scheduled, ScheduledPosting{
+			Posting: p,
+			Holding: re.ReplaceAllString(p.GetAccount(), replace_acct),
+			Entries: s.Plan(p.val, p.date, dec),
+		})
+	}
+	return scheduled
+}
+
+// Schedule Postings
+//
+// This takes an amount fully paid (or received) and spreads it over time according to
+// the schedule.
+//
+// For all postings that match search_acct regular expression, move the posting amount
+// into replace_acct (holding account) and, for each period of the schedule, move the
+// amount for the period back into the search_acct.
+func (b *Book) ApplySchedule(search_acct string, replace_acct string, s Schedule) {
+	b.ensureCompact()
+	newposts := b.post
+	for _, sp := range b.schedules(search_acct, replace_acct, s) {
+		p := sp.Posting
+
+		// Move all of the amount into the holding account
+		newposts = append(newposts, p.byFactor(big.NewRat(-1, 1)))
+		newposts = append(newposts, p.byAcctFactor(sp.Holding, big.NewRat(1, 1)))
+
+		// Move back each period
+		for _, e := range sp.Entries {
+			newposts = append(newposts, p.dup(sp.Holding, e.Date, new(big.Rat).Neg(e.Amount)))
+			newposts = append(newposts, p.dup(p.acct, e.Date, e.Amount))
+		}
+	}
+
+	b.post = newposts
+	b.compacted = false
+}
@@ -0,0 +1,38 @@
+package book
+
+import (
+	"math/big"
+	"testing"
+)
+
+func checkSchedule(t *testing.T, name string, entries []ScheduleEntry, dates []Date, amts []string) {
+	if len(entries) != len(amts) {
+		t.Fatalf("%s: expected %d periods, got %d", name, len(amts), len(entries))
+	}
+	for i, e := range entries {
+		if e.Date != dates[i] || e.Amount.FloatString(2) != amts[i] {
+			t.Errorf("%s: period %d expected %s %s, got %s %s", name, i, dates[i], amts[i], e.Date, e.Amount.FloatString(2))
+		}
+	}
+}
+
+func TestSchedulePlan(t *testing.T) {
+	amt := big.NewRat(1000, 1)
+	yearly := []Date{20200615, 20210101, 20220101, 20230101}
+
+	checkSchedule(t, "straight", Schedule{Method: "straight", Period: "yearly", Periods: 3}.Plan(amt, 20200615, 2),
+		yearly, []string{"333.33", "333.33", "333.34"})
+	checkSchedule(t, "sumofyears", Schedule{Method: "sumofyears", Period: "yearly", Periods: 3}.Plan(amt, 20200615, 2),
+		yearly, []string{"500.00", "333.33", "166.67"})
+
+	// 50%, 25%, then straight-line over the remaining 25%
+	checkSchedule(t, "declining", Schedule{Method: "declining", Period: "yearly", Periods: 4}.Plan(amt, 20200615, 2),
+		yearly, []string{"500.00", "250.00", "125.00", "125.00"})
+
+	// 366 days in 2020
+	checkSchedule(t, "amortise", Schedule{Method: "amortise", Start: 20200115, End: 20200415}.Plan(big.NewRat(910, 1), 20200101, 2),
+		[]Date{20200115, 20200201, 20200301, 20200401}, []string{"170.00", "290.00", "310.00", "140.00"})
+
+	checkSchedule(t, "amortise months", Schedule{Method: "amortise", Periods: 1}.Plan(big.NewRat(100, 1), 20200131, 2),
+		[]Date{20200131, 20200201}, []string{"3.45", "96.55"})
+}
@@ -0,0 +1,194 @@
+package book
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// How a transaction matched a search, best last
+type SearchMatch int
+
+const (
+	SearchNone   SearchMatch = iota
+	SearchFuzzy              // Every term is similar to a word
+	SearchTokens             // Some terms are words
+	SearchPhrase             // The terms are a phrase in a text
+)
+
+func (m SearchMatch) String() string {
+	return [4]string{"None", "Fuzzy", "Tokens", "Phrase"}[m]
+}
+
+// Transaction matching a search
+type SearchResult struct {
+	Transaction Transaction
+	Posting     *Posting    // Posting matched, or the largest if the payee, note or date matched
+	Match       SearchMatch // How the transaction matched
+	Score       float64     // Score within the match (0.0 to 1.0)
+}
+
+// Text of a transaction to search, and the posting it is from (-1 for the transaction)
+type searchText struct {
+	text   string
+	tokens []string
+	post   int
+	date   bool // only to rank transactions that match other texts
+}
+
+// Split text into lower case words of letters and digits
+func searchTokens(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// Search the transactions for the terms in the payee, transaction note, posting
+// notes, accounts and date, ignoring case. Terms in the date (eg the year) only
+// rank transactions matching other terms.
+//
+// Transactions with the terms as a phrase in one of the texts are first (scored by
+// the fraction of the text matched), then those with some of the terms as words
+// (scored by the fraction of terms), then those with every term similar to a word
+// (from similar(), 0.0 to 1.0, scored by the average similarity if at least
+// minScore). Results with the same match and score are the most recent first.
+func (b *Book) Search(terms string, similar func(a, b string) float64, minScore float64) []*SearchResult {
+	query := searchTokens(terms)
+	phrase := strings.Join(query, " ")
+	results := make([]*SearchResult, 0)
+	if len(query) == 0 {
+		return results
+	}
+
+	for _, trans := range b.Transactions() {
+		texts := []searchText{
+			{text: trans.GetPayee(), post: -1},
+			{text: trans.GetTransactionNote(), post: -1},
+			{text: trans.GetDate().String(), post: -1, date: true},
+		}
+		for i, p := range trans {
+			texts = append(texts, searchText{text: p.note, post: i}, searchText{text: p.acct, post: i})
+		}
+		for i := range texts {
+			texts[i].tokens = searchTokens(texts[i].text)
+		}
+
+		match, score, post := SearchPhrase, 0.0, -1
+		if score, post = searchPhrase(texts, phrase); score == 0 {
+			match = SearchTokens
+			if score, post = searchWords(texts, query); score == 0 {
+				match = SearchFuzzy
+				if score, post = searchSimilar(texts, query, similar); score < minScore {
+					continue
+				}
+			}
+		}
+
+		if post < 0 {
+			post = largestPosting(trans)
+		}
+		results = append(results, &SearchResult{trans, &trans[post], match, score})
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Match != results[j].Match {
+			return results[i].Match > results[j].Match
+		}
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Transaction.GetDate() > results[j].Transaction.GetDate()
+	})
+
+	return results
+}
+
+// Return the largest fraction of a text that the phrase is in as whole words (0 if
+// none) and its posting
+func searchPhrase(texts []searchText, phrase string) (float64, int) {
+	best, post := 0.0, -1
+	for _, t := range texts {
+		if t.date {
+			continue
+		}
+		text := strings.Join(t.tokens, " ")
+		if !strings.Contains(" "+text+" ", " "+phrase+" ") {
+			continue
+		}
+		if score := float64(len(phrase)) / float64(len(text)); score > best {
+			best, post = score, t.post
+		}
+	}
+	return best, post
+}
+
+// Return the fraction of the terms that are words of the texts (0 if none other
+// than the date), and the posting with the most of them (-1 if none)
+func searchWords(texts []searchText, query []string) (float64, int) {
+	found := make(map[string]bool)
+	post, postFound := -1, 0
+	other := false
+	for _, t := range texts {
+		count := 0
+		for _, q := range query {
+			for _, tok := range t.tokens {
+				if tok == q {
+					found[q] = true
+					other = other || !t.date
+					count++
+					break
+				}
+			}
+		}
+		if t.post >= 0 && count > postFound {
+			post, postFound = t.post, count
+		}
+	}
+
+	if !other {
+		return 0, -1
+	}
+
+	matched := 0
+	for _, q := range query {
+		if found[q] {
+			matched++
+		}
+	}
+	return float64(matched) / float64(len(query)), post
+}
+
+// Return the average similarity of the terms to the most similar words, and the
+// posting of the most similar word of the first term (-1 if not a posting)
+func searchSimilar(texts []searchText, query []string, similar func(a, b string) float64) (float64, int) {
+	total, post := 0.0, -1
+	for i, q := range query {
+		best := 0.0
+		for _, t := range texts {
+			if t.date {
+				continue
+			}
+			for _, tok := range t.tokens {
+				if s := similar(q, tok); s > best {
+					best = s
+					if i == 0 {
+						post = t.post
+					}
+				}
+			}
+		}
+		total += best
+	}
+	return total / float64(len(query)), post
+}
+
+// Return the index of the largest posting (ignoring currency) in the transaction
+func largestPosting(trans Transaction) int {
+	largest := 0
+	for i := range trans {
+		if trans[i].val.Cmp(trans[largest].val) > 0 {
+			largest = i
+		}
+	}
+	return largest
+}
@@ -0,0 +1,69 @@
+package book
+
+import (
+	"strings"
+	"testing"
+)
+
+// Similarity by the length of the common prefix
+func prefixSimilarity(a, b string) float64 {
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+	if len(a) > len(b) {
+		return float64(n) / float64(len(a))
+	}
+	return float64(n) / float64(len(b))
+}
+
+func TestSearch(t *testing.T) {
+	book := GetBook([]QuickBook{
+		{"2019-03-01", "Joe Smith Plumber", []QuickPosting{
+			{"Expense:House", "GBP", 120},
+			{"Asset:Bank", "GBP", -120},
+		}},
+		{"2019-06-01", "Plumbers Merchant", []QuickPosting{
+			{"Expense:House", "GBP", 40},
+			{"Asset:Bank", "GBP", -40},
+		}},
+		{"2020-01-01", "Smith and Plumber", []QuickPosting{
+			{"Expense:House:Plumber", "GBP", 80},
+			{"Asset:Bank", "GBP", -80},
+		}},
+		{"2019-02-01", "Shop", []QuickPosting{
+			{"Expense:Food", "GBP", 10},
+			{"Asset:Bank", "GBP", -10},
+		}},
+	}, nil)
+
+	search := func(terms string) string {
+		got := make([]string, 0)
+		for _, r := range book.Search(terms, prefixSimilarity, 0.7) {
+			got = append(got, r.Transaction.GetPayee()+"/"+r.Match.String()+"/"+r.Posting.GetAccount())
+		}
+		return strings.Join(got, ",")
+	}
+
+	// The phrase first, then the posting with the word
+	exp := "Joe Smith Plumber/Phrase/Expense:House,Smith and Plumber/Tokens/Expense:House:Plumber"
+	if got := search("smith PLUMBER"); got != exp {
+		t.Errorf("expected %s, got %s", exp, got)
+	}
+
+	// The date only ranks transactions
+	exp = "Joe Smith Plumber/Tokens/Expense:House,Smith and Plumber/Tokens/Expense:House:Plumber"
+	if got := search("plumber 2019"); got != exp {
+		t.Errorf("expected %s, got %s", exp, got)
+	}
+
+	// Similar words (6/7 and 6/8), the most recent first
+	exp = "Smith and Plumber/Fuzzy/Expense:House:Plumber,Joe Smith Plumber/Fuzzy/Expense:House,Plumbers Merchant/Fuzzy/Expense:House"
+	if got := search("plumbe"); got != exp {
+		t.Errorf("expected %s, got %s", exp, got)
+	}
+
+	if results := book.Search(" ; ", prefixSimilarity, 0.8); len(results) != 0 {
+		t.Errorf("expected no results without terms, got %d", len(results))
+	}
+}
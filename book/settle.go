@@ -0,0 +1,193 @@
+package book
+
+import (
+	"encoding/json"
+	"math/big"
+	"math/bits"
+	"sort"
+)
+
+// Transfer of an amount between two parties to settle their balances
+type Transfer struct {
+	From   string
+	To     string
+	CCY    string
+	Amount *big.Rat
+}
+
+func (t Transfer) MarshalJSON() ([]byte, error) {
+
+	type JsonTransfer struct {
+		From   string  `json:"from"`
+		To     string  `json:"to"`
+		CCY    string  `json:"ccy"`
+		Amount float64 `json:"amount"`
+	}
+
+	v, _ := t.Amount.Float64()
+	return json.Marshal(&JsonTransfer{t.From, t.To, t.CCY, v})
+}
+
+// Most parties with balances in a currency to search for the fewest transfers, as
+// the search is exponential in the number of parties
+const MaxSettleSearch = 16
+
+// Party with a balance to settle
+type settleParty struct {
+	name string
+	amt  *big.Rat
+}
+
+// Find the transfers to settle the balances between parties.
+//
+// Balances are by party and then currency: a positive balance is owed by the party
+// and a negative balance is owed to the party. The balances for each currency should
+// sum to zero.
+//
+// Each currency is settled separately with the fewest transfers: the parties are
+// split into as many groups with balances summing to zero as possible, and each
+// group is settled by greedily matching the largest debtor to the largest creditor,
+// needing one transfer less than the number of parties in the group. With more than
+// MaxSettleSearch parties in a currency they are settled as one group, needing at
+// most one transfer less than the number of parties.
+func Settle(balances map[string]map[string]*big.Rat) []Transfer {
+
+	// Parties with balances by currency
+	byccy := make(map[string][]settleParty)
+	for name, bals := range balances {
+		for ccy, amt := range bals {
+			if amt.Sign() != 0 {
+				byccy[ccy] = append(byccy[ccy], settleParty{name, new(big.Rat).Set(amt)})
+			}
+		}
+	}
+
+	ccys := make([]string, 0, len(byccy))
+	for ccy := range byccy {
+		ccys = append(ccys, ccy)
+	}
+	sort.Strings(ccys)
+
+	transfers := make([]Transfer, 0)
+	for _, ccy := range ccys {
+		parties := byccy[ccy]
+		sort.Slice(parties, func(i, j int) bool {
+			return parties[i].name < parties[j].name
+		})
+		for _, group := range settleGroups(parties) {
+			transfers = append(transfers, settleGreedy(ccy, group)...)
+		}
+	}
+
+	return transfers
+}
+
+// Split the parties into as many groups as possible with balances summing to zero.
+//
+// The most groups for each subset (by bit mask) of the parties is the most of the
+// subsets without one of its parties, plus one if its balances sum to zero. The
+// groups are then found by removing the parties in that order: adding them back in
+// reverse, each time the balances sum to zero completes a group.
+func settleGroups(parties []settleParty) [][]settleParty {
+	n := len(parties)
+	if n > MaxSettleSearch {
+		return [][]settleParty{parties}
+	}
+
+	size := 1 << uint(n)
+	sums := make([]big.Rat, size)
+	most := make([]int, size)
+	zero := func(mask int) int {
+		if sums[mask].Sign() == 0 {
+			return 1
+		}
+		return 0
+	}
+	for mask := 1; mask < size; mask++ {
+		low := bits.TrailingZeros(uint(mask))
+		sums[mask].Add(&sums[mask&(mask-1)], parties[low].amt)
+		for i := 0; i < n; i++ {
+			if bit := 1 << uint(i); mask&bit != 0 && most[mask^bit] > most[mask] {
+				most[mask] = most[mask^bit]
+			}
+		}
+		most[mask] += zero(mask)
+	}
+
+	order := make([]int, 0, n)
+	for mask := size - 1; mask != 0; {
+		for i := 0; i < n; i++ {
+			if bit := 1 << uint(i); mask&bit != 0 && most[mask^bit]+zero(mask) == most[mask] {
+				order = append(order, i)
+				mask ^= bit
+				break
+			}
+		}
+	}
+
+	groups := make([][]settleParty, 0, most[size-1])
+	group := make([]settleParty, 0, n)
+	total := new(big.Rat)
+	for i := len(order) - 1; i >= 0; i-- {
+		p := parties[order[i]]
+		group = append(group, p)
+		total.Add(total, p.amt)
+		if total.Sign() == 0 {
+			groups = append(groups, group)
+			group = make([]settleParty, 0, n)
+		}
+	}
+	if len(group) > 0 {
+		groups = append(groups, group)
+	}
+	return groups
+}
+
+// Settle the parties by greedily matching the largest debtor to the largest
+// creditor, needing at most one transfer less than the number of parties
+func settleGreedy(ccy string, parties []settleParty) []Transfer {
+	debtors := make([]settleParty, 0)
+	creditors := make([]settleParty, 0)
+	for _, p := range parties {
+		if p.amt.Sign() > 0 {
+			debtors = append(debtors, p)
+		} else {
+			creditors = append(creditors, settleParty{p.name, new(big.Rat).Neg(p.amt)})
+		}
+	}
+	bySize := func(parties []settleParty) {
+		sort.Slice(parties, func(i, j int) bool {
+			if c := parties[i].amt.Cmp(parties[j].amt); c != 0 {
+				return c > 0
+			}
+			return parties[i].name < parties[j].name
+		})
+	}
+	bySize(debtors)
+	bySize(creditors)
+
+	transfers := make([]Transfer, 0)
+	for len(debtors) > 0 && len(creditors) > 0 {
+		d, c := debtors[0], creditors[0]
+		amt := d.amt
+		if c.amt.Cmp(amt) < 0 {
+			amt = c.amt
+		}
+		amt = new(big.Rat).Set(amt)
+		transfers = append(transfers, Transfer{d.name, c.name, ccy, amt})
+
+		d.amt.Sub(d.amt, amt)
+		c.amt.Sub(c.amt, amt)
+		if d.amt.Sign() == 0 {
+			debtors = debtors[1:]
+		} else {
+			bySize(debtors)
+		}
+		if c.amt.Sign() == 0 {
+			creditors = creditors[1:]
+		} else {
+			bySize(creditors)
+		}
+	}
+	return transfers
+}
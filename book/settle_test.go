@@ -0,0 +1,90 @@
+package book
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestSettle(t *testing.T) {
+	balances := map[string]map[string]*big.Rat{
+		"Alice": {"GBP": big.NewRat(30, 1)},
+		"Bob":   {"GBP": big.NewRat(-50, 1), "EUR": big.NewRat(10, 1)},
+		"Carol": {"GBP": big.NewRat(20, 1)},
+		"Me":    {"EUR": big.NewRat(-10, 1)},
+	}
+
+	transfers := Settle(balances)
+	expected := []Transfer{
+		{"Bob", "Me", "EUR", big.NewRat(10, 1)},
+		{"Alice", "Bob", "GBP", big.NewRat(30, 1)},
+		{"Carol", "Bob", "GBP", big.NewRat(20, 1)},
+	}
+	if len(transfers) != len(expected) {
+		t.Fatalf("expected %d transfers, got %d: %v", len(expected), len(transfers), transfers)
+	}
+	for i, e := range expected {
+		tr := transfers[i]
+		if tr.From != e.From || tr.To != e.To || tr.CCY != e.CCY || tr.Amount.Cmp(e.Amount) != 0 {
+			t.Errorf("transfer %d: expected %v, got %v", i, e, tr)
+		}
+	}
+}
+
+func TestSettleFewest(t *testing.T) {
+	// Matching the largest debtor to the largest creditor needs four transfers
+	balances := map[string]map[string]*big.Rat{
+		"Alice": {"GBP": big.NewRat(-9, 1)},
+		"Bob":   {"GBP": big.NewRat(-8, 1)},
+		"Carol": {"GBP": big.NewRat(2, 1)},
+		"Dave":  {"GBP": big.NewRat(7, 1)},
+		"Erin":  {"GBP": big.NewRat(8, 1)},
+	}
+
+	transfers := Settle(balances)
+	expected := []Transfer{
+		{"Erin", "Bob", "GBP", big.NewRat(8, 1)},
+		{"Dave", "Alice", "GBP", big.NewRat(7, 1)},
+		{"Carol", "Alice", "GBP", big.NewRat(2, 1)},
+	}
+	if len(transfers) != len(expected) {
+		t.Fatalf("expected %d transfers, got %d: %v", len(expected), len(transfers), transfers)
+	}
+	for _, e := range expected {
+		found := false
+		for _, tr := range transfers {
+			if tr.From == e.From && tr.To == e.To && tr.CCY == e.CCY && tr.Amount.Cmp(e.Amount) == 0 {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("expected transfer %v in %v", e, transfers)
+		}
+	}
+}
+
+func TestSplitPost(t *testing.T) {
+	b := GetBook([]QuickBook{
+		{"2020-01-01", "Rent", []QuickPosting{
+			{"Asset:Bank", "GBP", -100},
+			{"Expense:Rent", "GBP", 100},
+		}},
+	}, []QuickPrice{})
+	b.ccy["GBP"] = 2
+
+	third := big.NewRat(1, 3)
+	b.SplitPost("^Expense:Rent$", []string{"Asset:Receivable:Alice", "Asset:Receivable:Bob"}, []*big.Rat{third, third})
+
+	bals := make(map[string]string)
+	for _, p := range b.Transactions()[0] {
+		bals[p.GetAccount()] = p.GetAmount().FloatString(2)
+	}
+	for acct, amt := range map[string]string{
+		"Asset:Receivable:Alice": "33.33",
+		"Asset:Receivable:Bob":   "33.33",
+		"Expense:Rent":           "33.34",
+	} {
+		if bals[acct] != amt {
+			t.Errorf("expected %s in %s, got %s", amt, acct, bals[acct])
+		}
+	}
+}
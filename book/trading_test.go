@@ -0,0 +1,57 @@
+package book
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestBuilderTrading(t *testing.T) {
+	b := NewBookBuilder()
+	b.SetTrading("Trading")
+	b.NewTransaction(20200101, "Exchange", "")
+	b.AddPosting("Asset:USD", "USD", big.NewRat(100, 1), "")
+	b.AddPosting("Asset:Bank", "GBP", big.NewRat(-80, 1), "")
+	b.NewTransaction(20200102, "Groceries", "")
+	b.AddPosting("Expense:Groceries", "GBP", big.NewRat(10, 1), "")
+	b.AddPosting("Asset:Bank", "GBP", big.NewRat(-10, 1), "")
+	book := b.Build()
+
+	trans := book.Transactions()
+	if len(trans[0]) != 4 || len(trans[1]) != 2 {
+		t.Fatalf("expected trading postings only for the exchange, got %d and %d postings", len(trans[0]), len(trans[1]))
+	}
+	amts := make(map[string]*big.Rat)
+	for _, p := range trans[0] {
+		amts[p.GetAccount()] = p.GetAmount()
+	}
+	if amts["Trading:USD"].Cmp(big.NewRat(-100, 1)) != 0 || amts["Trading:GBP"].Cmp(big.NewRat(80, 1)) != 0 {
+		t.Errorf("unexpected trading postings %v", amts)
+	}
+}
+
+func TestTradingAccounts(t *testing.T) {
+	book := GetBook([]QuickBook{
+		{"2020-01-01", "Exchange", []QuickPosting{
+			{"Asset:USD", "USD", 100},
+			{"Asset:Bank", "GBP", -80},
+			{"Equity:Exchange", "USD", -100},
+			{"Equity:Exchange", "GBP", 80},
+		}},
+		{"2020-01-02", "Other", []QuickPosting{
+			{"Asset:Bank", "GBP", -10},
+			{"Equity:Exchange", "GBP", 10},
+		}},
+	}, []QuickPrice{})
+
+	book.TradingAccounts("^Equity:Exchange$", "Trading")
+
+	accts := make(map[string]bool)
+	for _, trans := range book.Transactions() {
+		for _, p := range trans {
+			accts[p.GetAccount()] = true
+		}
+	}
+	if !accts["Trading:USD"] || !accts["Trading:GBP"] || !accts["Equity:Exchange"] {
+		t.Errorf("expected trading accounts for the exchange only, got %v", accts)
+	}
+}
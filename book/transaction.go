@@ -46,6 +46,11 @@ func (t Transaction) InferRates(base string) map[string]*big.Rat {
 			}
 		}
 
+		// If at end, break
+		if i >= len(t) {
+			break
+		}
+
 		lastAccount = i
 		i++
 	}
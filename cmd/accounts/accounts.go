@@ -1,54 +1,223 @@
 package accounts
 
 import (
+	"fmt"
+	"github.com/mescanne/goledger/book"
 	rapp "github.com/mescanne/goledger/cmd/app"
+	"github.com/mescanne/goledger/cmd/utils"
 	"github.com/spf13/cobra"
+	"math/big"
+	"regexp"
+	"sort"
+	"strings"
 )
 
-func Add(cmd *cobra.Command, app *rapp.App) {
+// Configuration for the accounts command
+type AccountsConfig struct {
+	Sort     string // Sort order of sub-accounts (see accountSorts)
+	Inactive int    // Days without activity to flag an account inactive (0 for none)
+	Convert  bool   // Show balances converted to the base currency
+	Type     string
+}
+
+// Default configuration if none specified
+var DefaultAccounts AccountsConfig = AccountsConfig{
+	Sort:     "name",
+	Inactive: 365,
+	Type:     "Text",
+}
+
+var accountSorts = []string{"name", "balance", "activity"}
+
+const accounts_long = `Show matching accounts as a tree
+
+Each account shows its balance including sub-accounts (per currency, or
+converted to the base currency with --convert), the number of transactions,
+and the dates of the first and last transactions.
+
+Accounts with no transactions in the last --inactive days are flagged
+inactive, and accounts with a single transaction may be typos. Use --all to
+include accounts with zero balances (eg closed accounts).
+
+Sub-accounts are sorted by name, balance (largest first) or activity (most
+recent first).
+
+The regex matches the accounts to show, along with their parent accounts.
+
+With --json only the names of the matching accounts are shown, as a JSON
+array; use --type Json for the tree with balances.
+`
+
+func Add(cmd *cobra.Command, app *rapp.App, cfg *AccountsConfig) {
+	if cfg.Sort == "" {
+		cfg.Sort = DefaultAccounts.Sort
+	}
+	if cfg.Type == "" {
+		cfg.Type = DefaultAccounts.Type
+	}
+
 	ncmd := &cobra.Command{
 		Use:               "accts [regex]",
 		Aliases:           []string{"accounts"},
 		Short:             "Show matching accounts",
-		Long:              "Show matching accounts",
+		Long:              accounts_long,
 		DisableAutoGenTag: true,
 	}
 	ncmd.Args = cobra.MaximumNArgs(1)
 
-	// Set defaults
 	var useJson bool
-	ncmd.Flags().BoolVar(&useJson, "json", false, "Show accounts using json")
+	ncmd.Flags().BoolVar(&useJson, "json", false, "show matching account names as a json array")
+	sortType := utils.NewEnum(&cfg.Sort, accountSorts, "sort")
+	ncmd.Flags().Var(sortType, "sort", fmt.Sprintf("sort sub-accounts by (values %s)", sortType.Values()))
+	reportType := utils.NewEnum(&cfg.Type, []string{"Text", "Json", "CSV"}, "reportType")
+	ncmd.Flags().Var(reportType, "type", fmt.Sprintf("report type (%s)", reportType.Values()))
+	ncmd.Flags().IntVar(&cfg.Inactive, "inactive", cfg.Inactive, "days without transactions to flag inactive (0 for none)")
+	ncmd.Flags().BoolVar(&cfg.Convert, "convert", cfg.Convert, "convert balances to base currency")
 
 	ncmd.RunE = func(cmd *cobra.Command, args []string) error {
-		b, err := app.LoadBook()
-		if err != nil {
-			return err
-		}
 		regex := "^.*$"
 		if len(args) == 1 {
 			regex = args[0]
 		}
+		return cfg.run(app, regex, useJson)
+	}
 
-		// Create printer
-		bp := app.NewBookPrinter(b.GetCCYDecimals())
+	cmd.AddCommand(ncmd)
+}
+
+func (cfg *AccountsConfig) run(app *rapp.App, regex string, names bool) error {
+	re, err := regexp.Compile(regex)
+	if err != nil {
+		return fmt.Errorf("invalid regex: '%s': %w", regex, err)
+	}
+
+	b, err := app.LoadBook()
+	if err != nil {
+		return err
+	}
+
+	if names {
+		return app.NewBookPrinter(b.GetCCYDecimals()).PrintJSON(b.Accounts(regex, !app.All), true)
+	}
+
+	if app.BaseCCY == "" && (cfg.Convert || cfg.Sort == "balance") {
+		return fmt.Errorf("unable to convert -- no CCY specified")
+	}
 
-		if useJson {
-			accts := make([]string, 0, 100)
-			for _, acct := range b.Accounts(regex, !app.All) {
-				accts = append(accts, acct)
+	tree := b.AccountTree(app.Divider, app.BaseCCY)
+	tree.Prune(func(n *book.AccountNode) bool {
+		return n.Postings > 0 && re.MatchString(n.Account) && (app.All || n.HasBalance())
+	})
+
+	switch cfg.Sort {
+	case "balance":
+		tree.SortByValue()
+	case "activity":
+		tree.SortBy(func(a, b *book.AccountNode) bool {
+			return a.Last > b.Last
+		})
+	}
+
+	bp := app.NewBookPrinter(b.GetCCYDecimals())
+	if cfg.Type == "Json" {
+		return bp.PrintJSON(tree, true)
+	} else if cfg.Type == "CSV" {
+		return cfg.showCSV(bp, app.BaseCCY, tree)
+	}
+	return cfg.show(bp, app.BaseCCY, tree)
+}
+
+// Return the balances of the account including sub-accounts to show
+func (cfg *AccountsConfig) balances(n *book.AccountNode, baseCCY string) ([]string, []*big.Rat) {
+	if cfg.Convert {
+		return []string{baseCCY}, []*big.Rat{n.Value}
+	}
+	ccys := make([]string, 0, len(n.Total))
+	for ccy := range n.Total {
+		ccys = append(ccys, ccy)
+	}
+	sort.Strings(ccys)
+	amts := make([]*big.Rat, 0, len(ccys))
+	for _, ccy := range ccys {
+		amts = append(amts, n.Total[ccy])
+	}
+	return ccys, amts
+}
+
+// Return the status of the account: inactive, or single for one transaction
+func (cfg *AccountsConfig) status(n *book.AccountNode, today book.Date) string {
+	if cfg.Inactive > 0 && today.DaysSince(n.Last) > cfg.Inactive {
+		return "inactive"
+	} else if n.Transactions == 1 {
+		return "single"
+	}
+	return ""
+}
+
+func (cfg *AccountsConfig) show(b *rapp.BookPrinter, baseCCY string, tree *book.AccountTree) error {
+	today := book.GetToday()
+
+	rows := make([][]rapp.ColumnValue, 0, 100)
+	rows = append(rows, []rapp.ColumnValue{
+		rapp.ColumnString(b.Ansi(rapp.BlueUL, "Account")),
+		rapp.ColumnRightString(b.Ansi(rapp.BlueUL, "Balance")),
+		rapp.ColumnRightString(b.Ansi(rapp.BlueUL, "Trans")),
+		rapp.ColumnString(b.Ansi(rapp.BlueUL, "First")),
+		rapp.ColumnString(b.Ansi(rapp.BlueUL, "Last")),
+		rapp.ColumnString(b.Ansi(rapp.BlueUL, "Status")),
+	})
+
+	for _, n := range tree.Nodes() {
+		name := strings.Repeat("  ", n.Depth-1) + n.Name
+		if n.Depth == 1 {
+			name = b.Ansi(rapp.Blue, n.Name)
+		}
+
+		ccys, amts := cfg.balances(n, baseCCY)
+		for i := range ccys {
+			row := []rapp.ColumnValue{
+				rapp.ColumnString(""),
+				b.GetColumnMoney(ccys[i], amts[i]),
+				rapp.ColumnString(""),
+				rapp.ColumnString(""),
+				rapp.ColumnString(""),
+				rapp.ColumnString(""),
 			}
-			bp.PrintJSON(accts, true)
-		} else {
-			rows := make([][]rapp.ColumnValue, 0, 100)
-			rows = append(rows, []rapp.ColumnValue{rapp.ColumnString(bp.Ansi(rapp.BlueUL, "Account"))})
-			for _, acct := range b.Accounts(regex, !app.All) {
-				rows = append(rows, []rapp.ColumnValue{rapp.ColumnString(acct)})
+			if i == 0 {
+				row[0] = rapp.ColumnString(name)
+				row[2] = rapp.ColumnRightString(fmt.Sprintf("%d", n.Transactions))
+				row[3] = rapp.ColumnString(n.First.String())
+				row[4] = rapp.ColumnString(n.Last.String())
+				row[5] = rapp.ColumnString(cfg.status(n, today))
 			}
-			bp.PrintColumns(rows, []bool{false})
+			rows = append(rows, row)
 		}
-
-		return nil
 	}
 
-	cmd.AddCommand(ncmd)
+	b.PrintColumns(rows, []bool{true, false, false, false, false, false})
+	return nil
+}
+
+func (cfg *AccountsConfig) showCSV(b *rapp.BookPrinter, baseCCY string, tree *book.AccountTree) error {
+	today := book.GetToday()
+
+	rows := make([][]string, 0, 100)
+	rows = append(rows, []string{"account", "depth", "ccy", "balance", "transactions", "first", "last", "status"})
+	for _, n := range tree.Nodes() {
+		ccys, amts := cfg.balances(n, baseCCY)
+		for i := range ccys {
+			f, _ := amts[i].Float64()
+			rows = append(rows, []string{
+				n.Account,
+				fmt.Sprintf("%d", n.Depth),
+				ccys[i],
+				fmt.Sprintf("%f", f),
+				fmt.Sprintf("%d", n.Transactions),
+				n.First.String(),
+				n.Last.String(),
+				cfg.status(n, today),
+			})
+		}
+	}
+	return b.PrintCSV(rows)
 }
@@ -13,6 +13,9 @@ import (
 	"io"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
 	"text/template"
 )
@@ -28,6 +31,7 @@ __goledger_handle_noun() {
 // Configuration for an Application
 type App struct {
 	Ledger  string              // Location of ledger file
+	Prices  []string            // Price database files or globs (ledger P lines or CSV)
 	BaseCCY string              // Conversion CCY for reporting
 	Verbose bool                // Verbose modw
 	Divider string              // Default (normally ":")
@@ -36,6 +40,16 @@ type App struct {
 	All     bool                // Use all accounts, rather than just accounts with a non-zero balance
 	Lang    string              // Language for formatting
 	Output  io.Writer           // Default output - only setting in the app (for web)
+
+	// Prefix of trading accounts for multi-currency transactions (empty to reject them)
+	Trading string
+
+	// Start of the fiscal year as MM-DD (eg 04-06), January 1 if empty
+	FiscalYear string
+
+	// Revaluation (revalue= operation)
+	Revalue         string // Account for unrealised gains and losses
+	RevalueAccounts string // Accounts revalued (regex)
 }
 
 // Default configuration if none specified
@@ -46,6 +60,9 @@ var DefaultApp App = App{
 	Divider: ":",
 	Colour:  true,
 	All:     false,
+
+	Revalue:         "Equity:Unrealized",
+	RevalueAccounts: "^(Asset|Liability)(:.*)?$",
 }
 
 func init() {
@@ -57,14 +74,100 @@ func init() {
 
 // Load a book from the configured ledger file
 func (app *App) LoadBook() (*book.Book, error) {
+	return app.loadBook(false)
+}
+
+// Load a book from the configured ledger file, keeping postings from different
+// lines of the ledger separate (see book.Builder.SetKeepSources)
+func (app *App) LoadSourceBook() (*book.Book, error) {
+	return app.loadBook(true)
+}
+
+func (app *App) loadBook(sources bool) (*book.Book, error) {
 	bbuilder := book.NewBookBuilder()
+	bbuilder.SetTrading(app.Trading)
+	bbuilder.SetKeepSources(sources)
 	if err := loader.ParseFile(bbuilder, app.Ledger); err != nil {
 		return nil, err
 	}
+	files, err := app.PriceFiles()
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		if err := loader.ParsePriceFile(bbuilder, file); err != nil {
+			return nil, err
+		}
+	}
 	b := bbuilder.Build()
 	return b, nil
 }
 
+var fiscalYearRe = regexp.MustCompile("^([0-9]?[0-9])[-/]([0-9]?[0-9])$")
+
+// Return the fiscal period type for the configured start of the fiscal year
+func (app *App) fiscalPeriod() (string, error) {
+	if app.FiscalYear == "" {
+		return "fiscal", nil
+	}
+	mat := fiscalYearRe.FindStringSubmatch(app.FiscalYear)
+	if mat == nil {
+		return "", fmt.Errorf("fiscal year '%s' must be MM-DD", app.FiscalYear)
+	}
+	month, _ := strconv.Atoi(mat[1])
+	day, _ := strconv.Atoi(mat[2])
+	return book.FiscalPeriod(month, day)
+}
+
+// Return the period type (see book.FloorTypes), with fiscal years starting on
+// the configured start of the fiscal year
+func (app *App) Period(by string) (string, error) {
+	if by != "fiscal" {
+		return by, nil
+	}
+	return app.fiscalPeriod()
+}
+
+// Parse a date expression (see book.ParseDate) with the configured fiscal year
+func (app *App) ParseDate(expr string) (book.Date, error) {
+	fiscal, err := app.fiscalPeriod()
+	if err != nil {
+		return 0, err
+	}
+	return book.ParseFiscalDate(expr, fiscal)
+}
+
+// Parse a period expression (see book.ParsePeriod) with the configured fiscal year
+func (app *App) ParsePeriod(expr string) (book.Date, book.Date, error) {
+	fiscal, err := app.fiscalPeriod()
+	if err != nil {
+		return 0, 0, err
+	}
+	return book.ParseFiscalPeriod(expr, fiscal)
+}
+
+// Find the configured price database files
+//
+// Relative paths and globs are relative to the directory of the ledger file,
+// the same as includes within the ledger. Each must match at least one file.
+func (app *App) PriceFiles() ([]string, error) {
+	files := make([]string, 0, len(app.Prices))
+	for _, pattern := range app.Prices {
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(filepath.Dir(app.Ledger), pattern)
+		}
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid price file pattern '%s': %w", pattern, err)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no price files matching '%s'", pattern)
+		}
+		files = append(files, matches...)
+	}
+	return files, nil
+}
+
 const version = "0.1"
 
 const goledger_long = `goledger is a text-based accounting.
@@ -142,8 +245,12 @@ func (app *App) LoadCommand() *cobra.Command {
 		// Prior to the RunE method running, suppress any usage output
 		// if there is an error -- at this point all CLI syntax-related
 		// errors should be resolved. This is just for runtime errors.
-		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
 			cmd.SilenceErrors = true
+
+			// Check the fiscal year before running
+			_, err := app.fiscalPeriod()
+			return err
 		},
 
 		// Cleanup if needed
@@ -158,7 +265,10 @@ func (app *App) LoadCommand() *cobra.Command {
 	}
 
 	appCmd.PersistentFlags().StringVarP(&app.Ledger, "ledger", "l", app.Ledger, "ledger to read")
+	appCmd.PersistentFlags().StringSliceVar(&app.Prices, "prices", app.Prices, "price database files or globs (ledger P lines or csv)")
 	appCmd.PersistentFlags().StringVar(&app.BaseCCY, "ccy", app.BaseCCY, "base currency")
+	appCmd.PersistentFlags().StringVar(&app.Trading, "trading", app.Trading, "prefix of trading accounts to balance multi-currency transactions")
+	appCmd.PersistentFlags().StringVar(&app.FiscalYear, "fiscalyear", app.FiscalYear, "start of fiscal year (MM-DD)")
 	appCmd.PersistentFlags().StringVar(&app.Divider, "divider", app.Divider, "divider for account components for reports")
 	appCmd.PersistentFlags().StringVar(&app.Lang, "lang", app.Lang, "language")
 	appCmd.PersistentFlags().BoolVar(&app.Verbose, "verbose", app.Verbose, "verbose")
@@ -0,0 +1,32 @@
+package app
+
+import (
+	"testing"
+)
+
+func TestFiscalYear(t *testing.T) {
+	app := &App{FiscalYear: "04-06"}
+	if by, err := app.Period("fiscal"); err != nil || by != "fiscal:04-06" {
+		t.Errorf("expected period fiscal:04-06, got %s (%v)", by, err)
+	}
+	if by, err := app.Period("monthly"); err != nil || by != "monthly" {
+		t.Errorf("expected period monthly, got %s (%v)", by, err)
+	}
+	if d, err := app.ParseDate("FY2024"); err != nil || d != 20240406 {
+		t.Errorf("expected FY2024 from 2024-04-06, got %s (%v)", d, err)
+	}
+	if since, asof, err := app.ParsePeriod("FY2024"); err != nil || since != 20240406 || asof != 20250406 {
+		t.Errorf("expected FY2024 from 2024-04-06 to 2025-04-06, got %s..%s (%v)", since, asof, err)
+	}
+
+	// Calendar years by default
+	if by, err := (&App{}).Period("fiscal"); err != nil || by != "fiscal" {
+		t.Errorf("expected period fiscal, got %s (%v)", by, err)
+	}
+
+	for _, fy := range []string{"13-01", "02-30", "April"} {
+		if _, err := (&App{FiscalYear: fy}).Period("fiscal"); err == nil {
+			t.Errorf("%s: expected error", fy)
+		}
+	}
+}
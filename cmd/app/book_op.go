@@ -3,6 +3,7 @@ package app
 import (
 	"fmt"
 	"github.com/mescanne/goledger/book"
+	"math/big"
 	"regexp"
 	"strconv"
 	"strings"
@@ -43,6 +44,23 @@ Operations:
     Re-direct (through new transfer posting) 0.2 of all regular expenses
     into the Expense:Irregular category.
 
+  split=/search-regex/account=share[,account=share...]/
+
+    All accounts matching search-regex -- for their postings -- will have
+    pairs of postings applied to transfer each share of the posting amount
+    into each account. Shares are a percentage (40%), a fraction (1/3) or
+    a decimal (0.4). The accounts can use captured groups from the search-regex.
+
+    Shares are rounded to the currency decimals. Any remaining share stays in
+    the matching account, or if the shares total 100% the last account takes
+    any rounding difference.
+
+    Example:
+    split=/^Expense:Rent$/Asset:Receivable:Alice=40%/
+
+    Record 40% of the rent as owed by Alice. Split rules can be configured
+    as macros.
+
   asof=date
   since=date
 
@@ -50,10 +68,18 @@ Operations:
     asof date), and the since date (all postings since and including the
     since date).
 
-    Date can be of formart "YYYY-MM-DD" or "(this|next|last) (year|month|quarter)".
+    Date can be of format:
+      YYYY-MM-DD, DD-MM-YYYY, YYYYMMDD
+      YYYY-MM, YYYY, 2024Q2, 2024H1, FY2024 (first day of the period)
+      today, yesterday, tomorrow, today-30d, today+2w (d, w, m, q or y)
+      3 months ago, 2 weeks ago
+      ytd, qtd, mtd, wtd (first day of the year, quarter, month or week)
+      last 90 days, last 6 months (first day of the range including today)
+      (this|next|last) (year|fiscal year|half year|quarter|month|week)
+    Invalid dates are rejected.
 
-    This is the closing year/month/quarter from today, next/last is the one
-    after and the one before.
+    This is the first day of the year/month/quarter containing today, next/last
+    is the one after and the one before.
 
     Example:
     asof="this year"
@@ -62,16 +88,81 @@ Operations:
     This will include everything since the preceeding Jan 1st (including Jan 1st),
     up to the subsequent Jan 1st (excluding Jan 1st).
 
+  period=period
+
+    This sets both the since and asof dates to cover a period, which is a date
+    covering all of the period (eg 2024Q2, 2024-03, 2024, last month, ytd or
+    last 90 days) or a range from..to covering from the start of from to the end
+    of to. Either side of the range may be empty.
+
+    Example:
+    period=2024Q1..2024Q3
+    period="last 90 days"
+    period=2024-04..
+
+  trading=/search-regex/prefix/
+
+    In all multi-currency transactions, postings in accounts matching
+    search-regex are moved into trading accounts prefix:<CCY> for their
+    currency. Converted to the base currency, the trading accounts are the
+    gains and losses from holding the currencies.
+
+    Example:
+    trading=/^Equity:Exchange$/Trading/
+
+    Multi-currency transactions without an exchange account can be balanced
+    with trading accounts when loading using the trading configuration (eg
+    trading = "Trading").
+
+  revalue=date
+
+    Revalues the balances of the accounts (revalueaccounts in the configuration,
+    by default all Asset and Liability accounts) in currencies other than the
+    base currency at the price on date (inclusive).
+
+    The difference between the market value and the cost (each posting at the
+    price of its own date) is posted in the base currency to the account on
+    the date, balanced by the revalue account (by default Equity:Unrealized).
+    When converted to the base currency the balances are then at market value.
+
+    Example:
+    revalue="this month"
+
   combine=type
 
-    Type can be yearly, quarterly, monthly, or all. This will floor all
-    transaction dates according to the rule.
+    Type can be yearly, fiscal, halfyearly, quarterly, monthly, weekly (ISO
+    weeks starting Monday), today or none. This will floor all transaction
+    dates according to the rule. The fiscal year starts on the configured
+    fiscalyear month and day (eg 04-06 for the UK tax year).
 
-  depreciate=/search-regex/asset-acccount/months/
+  depreciate=/search-regex/asset-acccount/periods/(method/)?
 
     For all matching accounts, immediately transfer the transaction into asset-account,
-    and then over the specified months transfer it back into the matching account a
-    straight-line portion of it.
+    and then over the specified periods transfer it back into the matching account a
+    portion of it.
+
+    Periods is a number of months, or a number followed by q for quarters or y for
+    years (eg 36, 12q, 3y). Method is straight (default), declining (double-declining
+    balance) or sumofyears (sum-of-years-digits).
+
+    Example:
+    depreciate=/^Expense:Car$/Asset:Car/5y/declining/
+
+  amortise=/search-regex/holding-account/start/end/
+  amortise=/search-regex/holding-account/months/
+
+    For all matching accounts, immediately transfer the transaction into
+    holding-account, and then transfer it back into the matching account
+    daily from start up to (excluding) end, or for the number of months
+    from the transaction date, with a posting each month.
+
+    This is for prepaid expenses and deferred income.
+
+    Example:
+    amortise=/^Expense:Insurance$/Asset:Prepaid/2024-03-01/2025-03-01/
+
+  Amounts for depreciate and amortise are rounded to the currency, with any
+  remainder in the last period. Use the schedule command to show the plan.
 
 `
 
@@ -82,7 +173,10 @@ var re_op_prefix = regexp.MustCompile("^[A-Za-z][0-9A-Za-z_]*=")
 var mapccy_op = regexp.MustCompile("^/([^/]+)/([^/]+)/$")
 var map_op = regexp.MustCompile("^/([^/]+)/([^/]+)/(([^/]+)/)?$")
 var move_op = regexp.MustCompile("^/([^/]+)/([^/]+)/([0-9\\.]+)/$")
-var deprec_op = regexp.MustCompile("^/([^/]+)/([^/]+)/([0-9\\.]+)/$")
+var trading_op = regexp.MustCompile("^/([^/]+)/([^/]+)/$")
+var split_op = regexp.MustCompile("^/([^/]+)/(.+)/$")
+var deprec_op = regexp.MustCompile("^/([^/]+)/([^/]+)/([0-9]+)([mqy]?)/(([a-z]+)/)?$")
+var amort_op = regexp.MustCompile("^/([^/]+)/([^/]+)/(([^/]+)/([^/]+)|([0-9]+))/$")
 
 func (app *App) BookOps(b *book.Book, ops ...string) error {
 	for _, op := range ops {
@@ -141,41 +235,177 @@ func (app *App) bookOp(b *book.Book, op string) error {
 		}
 		b.AdjustPost(args[1], args[2], factor)
 		return nil
+	case "split=":
+		args := split_op.FindStringSubmatch(op_act)
+		if args == nil {
+			return fmt.Errorf("split operation '%s', invalid: must be format '%s'", op_act, split_op.String())
+		}
+		accts, shares, err := parseShares(args[2])
+		if err != nil {
+			return fmt.Errorf("split shares '%s', invalid: %w", args[2], err)
+		}
+		b.SplitPost(args[1], accts, shares)
+		return nil
 	case "asof=":
-		d := book.DateFromString(op_act)
-		if d == book.Date(0) {
-			return fmt.Errorf("asof date '%s', invalid", op_act)
+		d, err := app.ParseDate(op_act)
+		if err != nil {
+			return fmt.Errorf("asof date: %w", err)
 		}
 		b.FilterByDateAsof(d)
 		return nil
 	case "since=":
-		d := book.DateFromString(op_act)
-		if d == book.Date(0) {
-			return fmt.Errorf("since date '%s', invalid", op_act)
+		d, err := app.ParseDate(op_act)
+		if err != nil {
+			return fmt.Errorf("since date: %w", err)
 		}
 		b.FilterByDateSince(d)
 		return nil
+	case "period=":
+		since, asof, err := app.ParsePeriod(op_act)
+		if err != nil {
+			return err
+		}
+		if since != 0 {
+			b.FilterByDateSince(since)
+		}
+		if asof != 0 {
+			b.FilterByDateAsof(asof)
+		}
+		return nil
+	case "trading=":
+		args := trading_op.FindStringSubmatch(op_act)
+		if args == nil {
+			return fmt.Errorf("trading operation '%s', invalid: must be format '%s'", op_act, trading_op.String())
+		}
+		b.TradingAccounts(args[1], args[2])
+		return nil
+	case "revalue=":
+		d, err := app.ParseDate(op_act)
+		if err != nil {
+			return fmt.Errorf("revalue date: %w", err)
+		}
+		if app.BaseCCY == "" {
+			return fmt.Errorf("unable to revalue -- no CCY specified")
+		}
+		re, err := regexp.Compile(app.RevalueAccounts)
+		if err != nil {
+			return fmt.Errorf("failed compiling revalue accounts '%s': %w", app.RevalueAccounts, err)
+		}
+		b.Revalue(d, app.BaseCCY, re, app.Revalue)
+		return nil
 	case "combine=":
 		for _, typ := range book.FloorTypes {
 			if strings.EqualFold(op_act, typ) {
-				b.SplitBy(typ)
+				by, err := app.Period(typ)
+				if err != nil {
+					return err
+				}
+				b.SplitBy(by)
 				return nil
 			}
 		}
 		return fmt.Errorf("combine type '%s', invalid: must be one of %s",
 			op_act, strings.Join(book.FloorTypes, ","))
-	case "depreciate=":
+	case "depreciate=", "amortise=":
+		search, holding, sched, err := app.ParseScheduleOp(op)
+		if err != nil {
+			return err
+		}
+		b.ApplySchedule(search, holding, sched)
+		return nil
+	default:
+		return fmt.Errorf("operation type '%s' invalid: must be one of map, move, split, trading, since, asof, period, revalue, combine, depreciate, or amortise", op_type)
+	}
+}
+
+// Parse account=share[,account=share...] into accounts and fractions
+func parseShares(s string) ([]string, []*big.Rat, error) {
+	accts := make([]string, 0)
+	shares := make([]*big.Rat, 0)
+	total := new(big.Rat)
+	for _, part := range strings.Split(s, ",") {
+		idx := strings.LastIndex(part, "=")
+		if idx <= 0 {
+			return nil, nil, fmt.Errorf("'%s' must be account=share", part)
+		}
+		acct, share := part[:idx], part[idx+1:]
+
+		pct := strings.HasSuffix(share, "%")
+		v, ok := new(big.Rat).SetString(strings.TrimSuffix(share, "%"))
+		if !ok || v.Sign() <= 0 {
+			return nil, nil, fmt.Errorf("share '%s' for %s must be a positive percentage, fraction or decimal", share, acct)
+		}
+		if pct {
+			v.Quo(v, big.NewRat(100, 1))
+		}
+		total.Add(total, v)
+
+		accts = append(accts, acct)
+		shares = append(shares, v)
+	}
+	if total.Cmp(big.NewRat(1, 1)) > 0 {
+		return nil, nil, fmt.Errorf("shares total more than 100%%")
+	}
+	return accts, shares, nil
+}
+
+// Parse a depreciate= or amortise= operation into the search and holding
+// accounts and the schedule
+func (app *App) ParseScheduleOp(op string) (string, string, book.Schedule, error) {
+	var sched book.Schedule
+
+	if strings.HasPrefix(op, "depreciate=") {
+		op_act := op[len("depreciate="):]
 		args := deprec_op.FindStringSubmatch(op_act)
 		if args == nil {
-			return fmt.Errorf("depreciate operation '%s', invalid: must be format '%s'", op_act, deprec_op.String())
+			return "", "", sched, fmt.Errorf("depreciate operation '%s', invalid: must be format '%s'", op_act, deprec_op.String())
 		}
-		months, err := strconv.ParseInt(args[3], 10, 64)
+		periods, err := strconv.Atoi(args[3])
 		if err != nil {
-			return fmt.Errorf("depreciate months '%s', invalid: must be integer: %v", args[3], err)
+			return "", "", sched, fmt.Errorf("depreciate periods '%s', invalid: must be integer: %v", args[3], err)
 		}
-		b.Depreciate(args[1], args[2], "monthly", months)
-		return nil
-	default:
-		return fmt.Errorf("operation type '%s' invalid: must be one of map, move, since, asof, combine, or depreciate", op_type)
+		sched.Periods = periods
+		sched.Period = map[string]string{"": "monthly", "m": "monthly", "q": "quarterly", "y": "yearly"}[args[4]]
+		sched.Method = args[6]
+		if sched.Method == "" {
+			sched.Method = "straight"
+		}
+		if sched.Method == "amortise" {
+			return "", "", sched, fmt.Errorf("depreciate method '%s', invalid: use the amortise operation", sched.Method)
+		}
+		if err = sched.Validate(); err != nil {
+			return "", "", sched, fmt.Errorf("depreciate operation '%s', invalid: %w", op_act, err)
+		}
+		return args[1], args[2], sched, nil
+	}
+
+	if strings.HasPrefix(op, "amortise=") {
+		op_act := op[len("amortise="):]
+		args := amort_op.FindStringSubmatch(op_act)
+		if args == nil {
+			return "", "", sched, fmt.Errorf("amortise operation '%s', invalid: must be format '%s'", op_act, amort_op.String())
+		}
+		sched.Method = "amortise"
+		if args[6] != "" {
+			months, err := strconv.Atoi(args[6])
+			if err != nil {
+				return "", "", sched, fmt.Errorf("amortise months '%s', invalid: must be integer: %v", args[6], err)
+			}
+			sched.Periods = months
+		} else {
+			var err error
+			if sched.Start, err = app.ParseDate(args[4]); err != nil {
+				return "", "", sched, fmt.Errorf("amortise start date: %w", err)
+			}
+			if sched.End, err = app.ParseDate(args[5]); err != nil {
+				return "", "", sched, fmt.Errorf("amortise end date: %w", err)
+			}
+		}
+		if err := sched.Validate(); err != nil {
+			return "", "", sched, fmt.Errorf("amortise operation '%s', invalid: %w", op_act, err)
+		}
+		return args[1], args[2], sched, nil
 	}
+
+	return "", "", sched, fmt.Errorf("operation '%s' invalid: must be depreciate or amortise", op)
 }
@@ -0,0 +1,300 @@
+package chart
+
+import (
+	"fmt"
+	"github.com/mescanne/goledger/book"
+	"github.com/mescanne/goledger/cmd/app"
+	"github.com/mescanne/goledger/cmd/utils"
+	"github.com/spf13/cobra"
+	"math/big"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Configuration for charts
+type ChartConfig struct {
+	Width    int    // Width of the chart in pixels
+	Height   int    // Height of the chart in pixels
+	Splitby  string // Period for expenses and net worth (see chartPeriods)
+	Expenses string // Expense accounts regex
+	NetWorth string // Net worth accounts regex
+}
+
+// Default configuration if none specified
+var DefaultChart ChartConfig = ChartConfig{
+	Width:    900,
+	Height:   450,
+	Splitby:  "monthly",
+	Expenses: "^Expense(:|$)",
+	NetWorth: "^(Asset|Liability)(:|$)",
+}
+
+var chartPeriods = []string{"weekly", "monthly", "quarterly", "halfyearly", "yearly", "fiscal"}
+
+const chart_long = `Charts of balances, expenses and net worth
+
+Charts are written as standalone SVG to standard output, so they can be
+saved and opened directly in a browser or embedded in an HTML report.
+
+All amounts are in the base currency. Any operations (eg since=) are applied
+before charting.
+
+Example:
+  chart networth 'since=last year' > networth.svg
+`
+
+func Add(root *cobra.Command, app *app.App, cfg *ChartConfig) {
+	if cfg.Width == 0 {
+		cfg.Width = DefaultChart.Width
+	}
+	if cfg.Height == 0 {
+		cfg.Height = DefaultChart.Height
+	}
+	if cfg.Splitby == "" {
+		cfg.Splitby = DefaultChart.Splitby
+	}
+	if cfg.Expenses == "" {
+		cfg.Expenses = DefaultChart.Expenses
+	}
+	if cfg.NetWorth == "" {
+		cfg.NetWorth = DefaultChart.NetWorth
+	}
+
+	ncmd := &cobra.Command{
+		Use:               "chart",
+		Short:             "Charts of balances, expenses and net worth (SVG)",
+		Long:              chart_long,
+		DisableAutoGenTag: true,
+	}
+	ncmd.PersistentFlags().IntVar(&cfg.Width, "width", cfg.Width, "width of chart")
+	ncmd.PersistentFlags().IntVar(&cfg.Height, "height", cfg.Height, "height of chart")
+	splitby := utils.NewEnum(&cfg.Splitby, chartPeriods, "period")
+	ncmd.PersistentFlags().Var(splitby, "splitby", fmt.Sprintf("period for expenses and net worth (values %s)", splitby.Values()))
+	root.AddCommand(ncmd)
+
+	var combined bool
+	balCmd := &cobra.Command{
+		Use:               "balance [macros|ops...] <acct|regex>",
+		Short:             "Line chart of running balances of accounts",
+		Args:              cobra.MinimumNArgs(1),
+		DisableAutoGenTag: true,
+	}
+	balCmd.Flags().BoolVar(&combined, "combined", false, "one line for all matching accounts combined")
+	balCmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return cfg.runBalance(app, combined, args)
+	}
+	ncmd.AddCommand(balCmd)
+
+	expCmd := &cobra.Command{
+		Use:               "expenses [macros|ops...]",
+		Short:             "Stacked bar chart of expenses per period by top-level category",
+		DisableAutoGenTag: true,
+	}
+	expCmd.Flags().StringVar(&cfg.Expenses, "accounts", cfg.Expenses, "expense accounts regex")
+	expCmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return cfg.runExpenses(app, args)
+	}
+	ncmd.AddCommand(expCmd)
+
+	nwCmd := &cobra.Command{
+		Use:               "networth [macros|ops...]",
+		Short:             "Area chart of net worth at the end of each period",
+		DisableAutoGenTag: true,
+	}
+	nwCmd.Flags().StringVar(&cfg.NetWorth, "accounts", cfg.NetWorth, "net worth accounts regex")
+	nwCmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return cfg.runNetWorth(app, args)
+	}
+	ncmd.AddCommand(nwCmd)
+}
+
+// Load the book with operations applied and check there is a base currency
+func loadBook(app *app.App, args []string) (*book.Book, error) {
+	if app.BaseCCY == "" {
+		return nil, fmt.Errorf("unable to chart -- no CCY specified")
+	}
+	b, err := app.LoadBook()
+	if err != nil {
+		return nil, err
+	}
+	if err = app.BookOps(b, args...); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Return a label function for amounts in the currency
+func moneyLabel(bp *app.BookPrinter, ccy string) func(float64) string {
+	return func(v float64) string {
+		return bp.FormatSymbol(ccy) + bp.FormatNumber(ccy, new(big.Rat).SetFloat64(v))
+	}
+}
+
+// Running base balance by date, keeping the last balance of each date
+func balancePoints(rep book.RegistryReport) []point {
+	points := make([]point, 0, len(rep))
+	for _, e := range rep {
+		v, _ := e.BaseBalance.Float64()
+		if n := len(points); n > 0 && points[n-1].Date == e.Date {
+			points[n-1].Value = v
+			continue
+		}
+		points = append(points, point{e.Date, v})
+	}
+	return points
+}
+
+func (cfg *ChartConfig) runBalance(rapp *app.App, combined bool, args []string) error {
+	b, err := loadBook(rapp, args[:len(args)-1])
+	if err != nil {
+		return err
+	}
+	arg := args[len(args)-1]
+
+	data := make([]series, 0)
+	if combined {
+		re, err := regexp.Compile(arg)
+		if err != nil {
+			return fmt.Errorf("invalid regex: '%s': %w", arg, err)
+		}
+		data = append(data, series{arg, balancePoints(b.ExtractRegister(rapp.BaseCCY, re, false, false))})
+	} else {
+		for _, acct := range b.Accounts(arg, !rapp.All) {
+			re, err := regexp.Compile(fmt.Sprintf("^%s$", regexp.QuoteMeta(acct)))
+			if err != nil {
+				return fmt.Errorf("failed compiling re for account '%s': %w", acct, err)
+			}
+			data = append(data, series{acct, balancePoints(b.ExtractRegister(rapp.BaseCCY, re, false, false))})
+		}
+	}
+	if len(data) == 0 {
+		return fmt.Errorf("no accounts match '%s'", arg)
+	}
+
+	bp := rapp.NewBookPrinter(b.GetCCYDecimals())
+	writeLineChart(bp, "Balance", data, cfg.Width, cfg.Height, false, moneyLabel(bp, rapp.BaseCCY))
+	return nil
+}
+
+// Return every period from the first to the last date
+func periodRange(first, last book.Date, by string) []book.Date {
+	periods := make([]book.Date, 0)
+	for d := first.Floor(by); d <= last; d = d.FloorDiff(by, 1) {
+		periods = append(periods, d)
+	}
+	return periods
+}
+
+func (cfg *ChartConfig) runExpenses(rapp *app.App, args []string) error {
+	re, err := regexp.Compile(cfg.Expenses)
+	if err != nil {
+		return fmt.Errorf("failed compiling expense accounts '%s': %w", cfg.Expenses, err)
+	}
+	b, err := loadBook(rapp, args)
+	if err != nil {
+		return err
+	}
+
+	by, err := rapp.Period(cfg.Splitby)
+	if err != nil {
+		return err
+	}
+
+	rep := b.ExtractRegister(rapp.BaseCCY, re, false, false)
+	if len(rep) == 0 {
+		return fmt.Errorf("no postings match '%s'", cfg.Expenses)
+	}
+	periods := periodRange(rep[0].Date, rep[len(rep)-1].Date, by)
+	pidx := make(map[book.Date]int)
+	for i, d := range periods {
+		pidx[d] = i
+	}
+
+	// Category is the account component after the top level
+	values := make(map[string][]float64)
+	totals := make(map[string]float64)
+	for _, e := range rep {
+		parts := strings.SplitN(e.Account, rapp.Divider, 3)
+		category := parts[0]
+		if len(parts) > 1 {
+			category = parts[1]
+		}
+		if _, ok := values[category]; !ok {
+			values[category] = make([]float64, len(periods))
+		}
+		v, _ := e.BaseAmount.Float64()
+		values[category][pidx[e.Date.Floor(by)]] += v
+		totals[category] += v
+	}
+
+	// Largest categories at the bottom
+	categories := make([]string, 0, len(values))
+	for c := range values {
+		categories = append(categories, c)
+	}
+	sort.Slice(categories, func(i, j int) bool {
+		if totals[categories[i]] != totals[categories[j]] {
+			return totals[categories[i]] > totals[categories[j]]
+		}
+		return categories[i] < categories[j]
+	})
+	stacks := make([][]float64, 0, len(categories))
+	for _, c := range categories {
+		stacks = append(stacks, values[c])
+	}
+
+	bp := rapp.NewBookPrinter(b.GetCCYDecimals())
+	writeStackedBarChart(bp, "Expenses", periods, by, categories, stacks, cfg.Width, cfg.Height, moneyLabel(bp, rapp.BaseCCY))
+	return nil
+}
+
+func (cfg *ChartConfig) runNetWorth(rapp *app.App, args []string) error {
+	re, err := regexp.Compile(cfg.NetWorth)
+	if err != nil {
+		return fmt.Errorf("failed compiling net worth accounts '%s': %w", cfg.NetWorth, err)
+	}
+	b, err := loadBook(rapp, args)
+	if err != nil {
+		return err
+	}
+
+	by, err := rapp.Period(cfg.Splitby)
+	if err != nil {
+		return err
+	}
+
+	rep := b.ExtractRegister(rapp.BaseCCY, re, false, false)
+	if len(rep) == 0 {
+		return fmt.Errorf("no postings match '%s'", cfg.NetWorth)
+	}
+
+	// Value the balances of each currency at the end of each period
+	bals := make(map[string]*big.Rat)
+	points := make([]point, 0)
+	idx := 0
+	for _, d := range periodRange(rep[0].Date, rep[len(rep)-1].Date, by) {
+		end := d.FloorDiff(by, 1)
+		for ; idx < len(rep) && rep[idx].Date < end; idx++ {
+			bal, ok := bals[rep[idx].CCY]
+			if !ok {
+				bal = new(big.Rat)
+				bals[rep[idx].CCY] = bal
+			}
+			bal.Add(bal, rep[idx].Amount)
+		}
+
+		last := end.AddDays(-1)
+		total := new(big.Rat)
+		for ccy, bal := range bals {
+			rate, _ := b.GetPrice(last, ccy, rapp.BaseCCY)
+			total.Add(total, new(big.Rat).Mul(bal, rate))
+		}
+		v, _ := total.Float64()
+		points = append(points, point{last, v})
+	}
+
+	bp := rapp.NewBookPrinter(b.GetCCYDecimals())
+	writeLineChart(bp, "Net Worth", []series{{"Net Worth", points}}, cfg.Width, cfg.Height, true, moneyLabel(bp, rapp.BaseCCY))
+	return nil
+}
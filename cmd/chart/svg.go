@@ -0,0 +1,277 @@
+package chart
+
+import (
+	"fmt"
+	"github.com/mescanne/goledger/book"
+	"html"
+	"io"
+	"math"
+	"strings"
+)
+
+// Colours for series, repeated if there are more series
+var palette = []string{
+	"#1f77b4", "#ff7f0e", "#2ca02c", "#d62728", "#9467bd",
+	"#8c564b", "#e377c2", "#7f7f7f", "#bcbd22", "#17becf",
+}
+
+// Margins around the plot area
+const (
+	marginLeft   = 90
+	marginRight  = 160
+	marginTop    = 40
+	marginBottom = 40
+)
+
+type point struct {
+	Date  book.Date
+	Value float64
+}
+
+// Named series of points in date order
+type series struct {
+	Name   string
+	Points []point
+}
+
+// Drawing area with the scale of the values
+type canvas struct {
+	w             io.Writer
+	width, height int
+	minX, maxX    float64
+	minY, maxY    float64
+	label         func(float64) string
+}
+
+func newCanvas(w io.Writer, width, height int, label func(float64) string) *canvas {
+	return &canvas{
+		w:      w,
+		width:  width,
+		height: height,
+		minX:   math.Inf(1),
+		maxX:   math.Inf(-1),
+		minY:   0,
+		maxY:   0,
+		label:  label,
+	}
+}
+
+func (c *canvas) includeX(x float64) {
+	c.minX = math.Min(c.minX, x)
+	c.maxX = math.Max(c.maxX, x)
+}
+
+func (c *canvas) includeY(y float64) {
+	c.minY = math.Min(c.minY, y)
+	c.maxY = math.Max(c.maxY, y)
+}
+
+func (c *canvas) x(x float64) float64 {
+	w := float64(c.width - marginLeft - marginRight)
+	if c.maxX <= c.minX {
+		return marginLeft + w/2
+	}
+	return marginLeft + (x-c.minX)/(c.maxX-c.minX)*w
+}
+
+func (c *canvas) y(y float64) float64 {
+	h := float64(c.height - marginTop - marginBottom)
+	if c.maxY <= c.minY {
+		return marginTop + h
+	}
+	return marginTop + (c.maxY-y)/(c.maxY-c.minY)*h
+}
+
+func (c *canvas) printf(format string, a ...interface{}) {
+	fmt.Fprintf(c.w, format, a...)
+}
+
+// Return a step of 1, 2 or 5 times a power of ten giving about n ticks over the span
+func niceStep(span float64, n int) float64 {
+	if span <= 0 {
+		return 1
+	}
+	raw := span / float64(n)
+	pow := math.Pow(10, math.Floor(math.Log10(raw)))
+	for _, m := range []float64{1, 2, 5} {
+		if m*pow >= raw {
+			return m * pow
+		}
+	}
+	return 10 * pow
+}
+
+// Extend the value scale to whole ticks
+func (c *canvas) niceY() float64 {
+	step := niceStep(c.maxY-c.minY, 5)
+	c.minY = math.Floor(c.minY/step) * step
+	c.maxY = math.Ceil(c.maxY/step) * step
+	if c.maxY == c.minY {
+		c.maxY = c.minY + step
+	}
+	return step
+}
+
+func (c *canvas) start(title string) {
+	c.printf("<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%d\" height=\"%d\" viewBox=\"0 0 %d %d\" font-family=\"sans-serif\" font-size=\"12\">\n",
+		c.width, c.height, c.width, c.height)
+	c.printf("<rect width=\"100%%\" height=\"100%%\" fill=\"white\"/>\n")
+	c.printf("<text x=\"%d\" y=\"%d\" font-size=\"16\" font-weight=\"bold\">%s</text>\n", marginLeft, marginTop/2+5, html.EscapeString(title))
+}
+
+func (c *canvas) end() {
+	c.printf("</svg>\n")
+}
+
+// Draw the value axis with grid lines
+func (c *canvas) yAxis() {
+	step := c.niceY()
+	right := c.width - marginRight
+	for v := c.minY; v <= c.maxY+step/2; v += step {
+		y := c.y(v)
+		stroke := "#e0e0e0"
+		if math.Abs(v) < step/2 {
+			stroke = "#000000"
+		}
+		c.printf("<line x1=\"%d\" y1=\"%.1f\" x2=\"%d\" y2=\"%.1f\" stroke=\"%s\"/>\n", marginLeft, y, right, y, stroke)
+		c.printf("<text x=\"%d\" y=\"%.1f\" text-anchor=\"end\">%s</text>\n", marginLeft-5, y+4, html.EscapeString(c.label(v)))
+	}
+}
+
+// Draw the date axis with about five labels
+func (c *canvas) dateAxis() {
+	bottom := c.height - marginBottom
+	c.printf("<line x1=\"%d\" y1=\"%d\" x2=\"%d\" y2=\"%d\" stroke=\"#000000\"/>\n", marginLeft, bottom, c.width-marginRight, bottom)
+	if c.maxX < c.minX {
+		return
+	}
+	ticks := 5
+	if c.maxX == c.minX {
+		ticks = 1
+	}
+	for i := 0; i < ticks; i++ {
+		days := c.minX
+		if ticks > 1 {
+			days += (c.maxX - c.minX) * float64(i) / float64(ticks-1)
+		}
+		x := c.x(days)
+		c.printf("<line x1=\"%.1f\" y1=\"%d\" x2=\"%.1f\" y2=\"%d\" stroke=\"#000000\"/>\n", x, bottom, x, bottom+5)
+		c.printf("<text x=\"%.1f\" y=\"%d\" text-anchor=\"middle\">%s</text>\n", x, bottom+18, book.GetDateFromDays(int(days)))
+	}
+}
+
+// Draw the legend to the right of the plot area
+func (c *canvas) legend(names []string) {
+	x := c.width - marginRight + 15
+	for i, name := range names {
+		y := marginTop + i*18
+		c.printf("<rect x=\"%d\" y=\"%d\" width=\"12\" height=\"12\" fill=\"%s\"/>\n", x, y, palette[i%len(palette)])
+		c.printf("<text x=\"%d\" y=\"%d\">%s</text>\n", x+17, y+10, html.EscapeString(name))
+	}
+}
+
+// Write a line chart of the series, filling the area under the lines if area is set.
+//
+// The lines step at each point, as balances change on the date of a posting.
+func writeLineChart(w io.Writer, title string, data []series, width, height int, area bool, label func(float64) string) {
+	c := newCanvas(w, width, height, label)
+	for _, s := range data {
+		for _, p := range s.Points {
+			c.includeX(float64(p.Date.AsDays()))
+			c.includeY(p.Value)
+		}
+	}
+
+	c.start(title)
+	c.yAxis()
+	c.dateAxis()
+
+	names := make([]string, 0, len(data))
+	for i, s := range data {
+		names = append(names, s.Name)
+		if len(s.Points) == 0 {
+			continue
+		}
+		colour := palette[i%len(palette)]
+
+		path := make([]string, 0, len(s.Points)*2)
+		for j, p := range s.Points {
+			x := c.x(float64(p.Date.AsDays()))
+			if j > 0 {
+				path = append(path, fmt.Sprintf("L%.1f,%.1f", x, c.y(s.Points[j-1].Value)))
+			} else {
+				path = append(path, fmt.Sprintf("M%.1f,%.1f", x, c.y(p.Value)))
+			}
+			path = append(path, fmt.Sprintf("L%.1f,%.1f", x, c.y(p.Value)))
+		}
+
+		if area {
+			first := c.x(float64(s.Points[0].Date.AsDays()))
+			last := c.x(float64(s.Points[len(s.Points)-1].Date.AsDays()))
+			c.printf("<path d=\"%s L%.1f,%.1f L%.1f,%.1f Z\" fill=\"%s\" fill-opacity=\"0.3\" stroke=\"none\"/>\n",
+				strings.Join(path, " "), last, c.y(0), first, c.y(0), colour)
+		}
+		c.printf("<path d=\"%s\" fill=\"none\" stroke=\"%s\" stroke-width=\"2\"/>\n", strings.Join(path, " "), colour)
+	}
+
+	c.legend(names)
+	c.end()
+}
+
+// Write a stacked bar chart with a bar for each period and a stack for each category.
+//
+// Positive values stack upwards and negative values downwards from zero.
+func writeStackedBarChart(w io.Writer, title string, periods []book.Date, by string, categories []string, values [][]float64, width, height int, label func(float64) string) {
+	c := newCanvas(w, width, height, label)
+	for i := range periods {
+		pos, neg := 0.0, 0.0
+		for j := range categories {
+			if v := values[j][i]; v > 0 {
+				pos += v
+			} else {
+				neg += v
+			}
+		}
+		c.includeY(pos)
+		c.includeY(neg)
+	}
+
+	c.start(title)
+	c.yAxis()
+
+	// Bars are evenly spaced, one per period
+	bottom := c.height - marginBottom
+	c.printf("<line x1=\"%d\" y1=\"%d\" x2=\"%d\" y2=\"%d\" stroke=\"#000000\"/>\n", marginLeft, bottom, c.width-marginRight, bottom)
+	slot := float64(c.width-marginLeft-marginRight) / float64(len(periods))
+	labelEvery := int(math.Ceil(float64(len(periods)) * 80 / float64(c.width-marginLeft-marginRight)))
+	if labelEvery < 1 {
+		labelEvery = 1
+	}
+	for i, d := range periods {
+		x := marginLeft + slot*float64(i) + slot*0.1
+		pos, neg := 0.0, 0.0
+		for j := range categories {
+			v := values[j][i]
+			if v == 0 {
+				continue
+			}
+			var top, base float64
+			if v > 0 {
+				base, top = pos, pos+v
+				pos = top
+			} else {
+				base, top = neg+v, neg
+				neg = base
+			}
+			c.printf("<rect x=\"%.1f\" y=\"%.1f\" width=\"%.1f\" height=\"%.1f\" fill=\"%s\"><title>%s %s: %s</title></rect>\n",
+				x, c.y(top), slot*0.8, c.y(base)-c.y(top), palette[j%len(palette)],
+				d.Label(by), html.EscapeString(categories[j]), html.EscapeString(label(v)))
+		}
+		if i%labelEvery == 0 {
+			c.printf("<text x=\"%.1f\" y=\"%d\" text-anchor=\"middle\">%s</text>\n", x+slot*0.4, bottom+18, d.Label(by))
+		}
+	}
+
+	c.legend(categories)
+	c.end()
+}
@@ -0,0 +1,215 @@
+package check
+
+import (
+	"fmt"
+	"github.com/antzucaro/matchr"
+	"github.com/mescanne/goledger/book"
+	"github.com/mescanne/goledger/cmd/app"
+	"github.com/spf13/cobra"
+	"os"
+	"strings"
+)
+
+// Configuration for the check command
+type CheckConfig struct {
+	Skip       []string // Checks to skip
+	Distance   int      // Maximum edit distance of account names for typos
+	Similarity float64  // Minimum similarity of payees for duplicates (0.0 to 1.0)
+	Tolerance  float64  // Tolerance of inverse prices (relative, 0.01 is 1%)
+}
+
+// Default configuration if none specified
+var DefaultCheck CheckConfig = CheckConfig{
+	Distance:   2,
+	Similarity: 0.9,
+	Tolerance:  0.01,
+}
+
+// Checks in the order they are run
+var checks = []struct {
+	name string
+	help string
+}{
+	{book.CheckSingleUse, "accounts used only once"},
+	{book.CheckTypos, "account names within --distance edits of another account (likely typos)"},
+	{book.CheckPricePath, "currencies with no prices to the base currency"},
+	{book.CheckFuture, "transactions dated in the future"},
+	{book.CheckDuplicates, "transactions with the same date and amount, and payees at least --similarity"},
+	{book.CheckInverse, "prices inconsistent (by more than --tolerance) with prices of the inverse pair"},
+	{book.CheckRenumbered, "payees renumbered (eg 'Shop (2)') as repeated on the same date"},
+}
+
+func checkNames() []string {
+	names := make([]string, 0, len(checks))
+	for _, c := range checks {
+		names = append(names, c.name)
+	}
+	return names
+}
+
+func checkLong() string {
+	var sb strings.Builder
+	sb.WriteString(`Check the ledger for likely mistakes
+
+Run checks of the ledger and show the problems found, and exit with an error if
+there are any (eg for CI). The checks are:
+
+`)
+	for _, c := range checks {
+		sb.WriteString(fmt.Sprintf("  %-11s %s\n", c.name, c.help))
+	}
+	sb.WriteString(`
+Use --skip to skip checks (or --only to run just some of them). Checks can be
+skipped by default with skip in the [check] section of the configuration.
+`)
+	return sb.String()
+}
+
+func Add(root *cobra.Command, app *app.App, cfg *CheckConfig) {
+	if cfg.Distance == 0 {
+		cfg.Distance = DefaultCheck.Distance
+	}
+	if cfg.Similarity == 0 {
+		cfg.Similarity = DefaultCheck.Similarity
+	}
+	if cfg.Tolerance == 0 {
+		cfg.Tolerance = DefaultCheck.Tolerance
+	}
+
+	ncmd := &cobra.Command{
+		Use:               "check",
+		Short:             "Check ledger for likely mistakes",
+		Long:              checkLong(),
+		DisableAutoGenTag: true,
+	}
+	ncmd.Args = cobra.NoArgs
+
+	var only []string
+	var useJson bool
+	ncmd.Flags().StringSliceVar(&cfg.Skip, "skip", cfg.Skip, fmt.Sprintf("checks to skip (values %s)", strings.Join(checkNames(), ", ")))
+	ncmd.Flags().StringSliceVar(&only, "only", nil, "only run checks")
+	ncmd.Flags().IntVar(&cfg.Distance, "distance", cfg.Distance, "maximum edit distance of account names for typos")
+	ncmd.Flags().Float64Var(&cfg.Similarity, "similarity", cfg.Similarity, "minimum similarity of payees (0.0 to 1.0) for duplicates")
+	ncmd.Flags().Float64Var(&cfg.Tolerance, "tolerance", cfg.Tolerance, "tolerance of inverse prices (relative, 0.01 is 1%)")
+	ncmd.Flags().BoolVar(&useJson, "json", false, "show problems using json")
+
+	ncmd.RunE = func(cmd *cobra.Command, args []string) error {
+		run, err := cfg.selectChecks(only)
+		if err != nil {
+			return err
+		}
+
+		findings, err := cfg.run(app, run, useJson)
+		if err != nil {
+			return err
+		}
+		if findings > 0 {
+			cmd.SilenceUsage = true
+			return fmt.Errorf("check found %d problem(s)", findings)
+		}
+		return nil
+	}
+
+	root.AddCommand(ncmd)
+}
+
+// Return the checks to run: only (if any) without those skipped
+func (cfg *CheckConfig) selectChecks(only []string) (map[string]bool, error) {
+	valid := make(map[string]bool)
+	for _, c := range checks {
+		valid[c.name] = true
+	}
+	for _, name := range append(only, cfg.Skip...) {
+		if !valid[name] {
+			return nil, fmt.Errorf("invalid check '%s', expected %s", name, strings.Join(checkNames(), ", "))
+		}
+	}
+
+	run := make(map[string]bool)
+	for _, c := range checks {
+		run[c.name] = len(only) == 0
+	}
+	for _, name := range only {
+		run[name] = true
+	}
+	for _, name := range cfg.Skip {
+		run[name] = false
+	}
+	return run, nil
+}
+
+func (cfg *CheckConfig) run(rapp *app.App, run map[string]bool, useJson bool) (int, error) {
+	b, err := rapp.LoadBook()
+	if err != nil {
+		return 0, err
+	}
+
+	if run[book.CheckPricePath] && rapp.BaseCCY == "" {
+		fmt.Fprintf(os.Stderr, "WARNING: skipping check %s -- no CCY specified\n", book.CheckPricePath)
+		run[book.CheckPricePath] = false
+	}
+
+	similar := func(a, b string) float64 {
+		return matchr.JaroWinkler(strings.ToUpper(a), strings.ToUpper(b), true)
+	}
+
+	findings := make([]book.CheckFinding, 0)
+	for _, c := range checks {
+		if !run[c.name] {
+			continue
+		}
+		switch c.name {
+		case book.CheckSingleUse:
+			findings = append(findings, b.CheckSingleUse()...)
+		case book.CheckTypos:
+			findings = append(findings, b.CheckTypos(rapp.Divider, matchr.DamerauLevenshtein, cfg.Distance)...)
+		case book.CheckPricePath:
+			findings = append(findings, b.CheckPricePath(rapp.BaseCCY)...)
+		case book.CheckFuture:
+			findings = append(findings, b.CheckFuture(book.GetToday())...)
+		case book.CheckDuplicates:
+			findings = append(findings, b.CheckDuplicates(similar, cfg.Similarity)...)
+		case book.CheckInverse:
+			findings = append(findings, b.CheckInverse(cfg.Tolerance)...)
+		case book.CheckRenumbered:
+			findings = append(findings, b.CheckRenumbered()...)
+		}
+	}
+
+	bp := rapp.NewBookPrinter(b.GetCCYDecimals())
+	if useJson {
+		return len(findings), bp.PrintJSON(findings, true)
+	}
+	if len(findings) > 0 {
+		showFindings(bp, findings)
+	}
+	return len(findings), nil
+}
+
+func showFindings(b *app.BookPrinter, findings []book.CheckFinding) {
+	rows := make([][]app.ColumnValue, 0, len(findings)+1)
+	rows = append(rows, []app.ColumnValue{
+		app.ColumnString(b.Ansi(app.UL, "Check")),
+		app.ColumnString(b.Ansi(app.UL, "Date")),
+		app.ColumnString(b.Ansi(app.UL, "Subject")),
+		app.ColumnString(b.Ansi(app.UL, "Problem")),
+		app.ColumnString(b.Ansi(app.UL, "Source")),
+	})
+	for _, f := range findings {
+		date, source := "", ""
+		if f.Date != 0 {
+			date = f.Date.String()
+		}
+		if f.File != "" {
+			source = fmt.Sprintf("%s:%d", f.File, f.Line)
+		}
+		rows = append(rows, []app.ColumnValue{
+			app.ColumnString(f.Check),
+			app.ColumnString(date),
+			app.ColumnString(f.Subject),
+			app.ColumnString(f.Message),
+			app.ColumnString(source),
+		})
+	}
+	b.PrintColumns(rows, []bool{false, false, true, true, true})
+}
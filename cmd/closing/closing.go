@@ -0,0 +1,104 @@
+package closing
+
+import (
+	"fmt"
+	"github.com/mescanne/goledger/book"
+	"github.com/mescanne/goledger/cmd/app"
+	"github.com/mescanne/goledger/cmd/reports"
+	"github.com/spf13/cobra"
+	"regexp"
+)
+
+// Configuration for closing the books
+type CloseConfig struct {
+	Income   string // Income and expense accounts regex (closed into Retained)
+	Balance  string // Balance sheet accounts regex (carried forward)
+	Retained string // Retained earnings account
+	Opening  string // Opening balance account
+}
+
+// Default configuration if none specified
+var DefaultClose CloseConfig = CloseConfig{
+	Income:   "^(Income|Expense)(:.*)?$",
+	Balance:  "^(Asset|Liability)(:.*)?$",
+	Retained: "Equity:RetainedEarnings",
+	Opening:  "Equity:OpeningBalances",
+}
+
+const close_long = `Year-end close
+
+Generates ledger transactions to close the books at the end of the year:
+
+  - The closing transaction on the last day of the year transfers all of the
+    income and expense account balances into the retained earnings account.
+
+  - The opening transaction on the first day of the next year carries forward
+    the balances of all the balance sheet accounts, with the remaining balance
+    in the opening balance account.
+
+The transactions balance for each currency and the opening balances are the
+same as the balances from the report with asof=<year+1>-01-01.
+
+Operations (eg map=) are applied before closing.
+`
+
+func Add(root *cobra.Command, app *app.App, cfg *CloseConfig) {
+	if cfg.Income == "" {
+		cfg.Income = DefaultClose.Income
+	}
+	if cfg.Balance == "" {
+		cfg.Balance = DefaultClose.Balance
+	}
+	if cfg.Retained == "" {
+		cfg.Retained = DefaultClose.Retained
+	}
+	if cfg.Opening == "" {
+		cfg.Opening = DefaultClose.Opening
+	}
+
+	var year int
+	ncmd := &cobra.Command{
+		Use:               "close [macros|ops...]",
+		Short:             "Generate year-end closing and opening transactions",
+		Long:              close_long,
+		DisableAutoGenTag: true,
+	}
+	ncmd.Flags().IntVar(&year, "year", 0, "year to close")
+	ncmd.Flags().StringVar(&cfg.Income, "income", cfg.Income, "income and expense accounts regex")
+	ncmd.Flags().StringVar(&cfg.Balance, "balance", cfg.Balance, "balance sheet accounts regex")
+	ncmd.Flags().StringVar(&cfg.Retained, "retained", cfg.Retained, "retained earnings account")
+	ncmd.Flags().StringVar(&cfg.Opening, "opening", cfg.Opening, "opening balance account")
+	cobra.MarkFlagRequired(ncmd.Flags(), "year")
+	ncmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return cfg.run(app, year, args)
+	}
+	root.AddCommand(ncmd)
+}
+
+func (cfg *CloseConfig) run(app *app.App, year int, args []string) error {
+	if year <= 0 {
+		return fmt.Errorf("invalid year %d", year)
+	}
+	income, err := regexp.Compile(cfg.Income)
+	if err != nil {
+		return fmt.Errorf("failed compiling income accounts '%s': %w", cfg.Income, err)
+	}
+	balance, err := regexp.Compile(cfg.Balance)
+	if err != nil {
+		return fmt.Errorf("failed compiling balance accounts '%s': %w", cfg.Balance, err)
+	}
+
+	b, err := app.LoadBook()
+	if err != nil {
+		return err
+	}
+	if err = app.BookOps(b, args...); err != nil {
+		return err
+	}
+
+	closed := b.CloseBooks(book.GetDate(year+1, 1, 1), income, balance, cfg.Retained, cfg.Opening)
+
+	// Use decimals of main book
+	bp := app.NewBookPrinter(b.GetCCYDecimals())
+	return reports.ShowLedger(bp, closed.Transactions())
+}
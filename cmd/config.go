@@ -97,6 +97,11 @@ There are two notable exceptions:
     configuration pre-configured. This allows you to create an import
     definition per CSV file (or other format) that you download.
 
+  - prices
+    List of price database files or globs (relative to the ledger file)
+    that are loaded in addition to P lines in the ledger. These can be
+    ledger P lines or CSV (date, unit, ccy, price) with a .csv extension.
+
   - register.accounts
     List of accounts that shell-completion will match if used. This is
     to make it easier to use the CLI.
@@ -115,7 +120,12 @@ const DEFAULT_CONFIG_FILE = `
 # Main defaults
 #
 #ledger =  "default_ledger_file"
+#prices =  ["prices/*.csv", "prices.ledger"]
 #baseccy = "ÃÂÃÂÃÂÃÂ£"
+#trading = "Trading"
+#fiscalyear = "04-06"
+#revalue = "Equity:Unrealized"
+#revalueaccounts = "^(Asset|Liability)(:.*)?$"
 
 #
 # Defaults for the report command
@@ -126,6 +136,10 @@ type =      "Ansi"
 sum =       true
 convert =   true
 credit =    "^(Income|Trading|Liability|Equity)(:.*)?$"
+#columnar = false
+#total =    false
+#average =  false
+#change =   false
 
 [report.macros]
 macroA = [
@@ -133,6 +147,15 @@ macroA = [
 	"book operation B",
 ]
 
+#
+# Defaults for the cashflow command
+#
+#[cashflow]
+#cash =      "^Asset:(Bank|Cash)(:.*)?$"
+#investing = "^Asset:(Investment|Property|Vehicle)(:.*)?$"
+#financing = "^(Liability:(Loan|Mortgage)|Equity)(:.*)?$"
+#splitby =   "yearly"
+
 #
 # Defaults for the register command
 #
@@ -144,6 +167,66 @@ accounts = [
 count = -100
 type = "Text"
 asc = true
+#period = "monthly"
+#related = false
+
+#
+# Defaults for the close command
+#
+#[close]
+#income =   "^(Income|Expense)(:.*)?$"
+#balance =  "^(Asset|Liability)(:.*)?$"
+#retained = "Equity:RetainedEarnings"
+#opening =  "Equity:OpeningBalances"
+
+#
+# Defaults for the settle command
+#
+#[settle]
+#accounts = "^(?:Asset:Receivable|Liability:Payable):([^:]+)"
+#self = "Me"
+
+#
+# Defaults for the chart command
+#
+#[chart]
+#width = 900
+#height = 450
+#splitby = "monthly"
+#expenses = "^Expense(:|$)"
+#networth = "^(Asset|Liability)(:|$)"
+
+#
+# Defaults for the accts command
+#
+#[accounts]
+#sort = "name"
+#inactive = 365
+#convert = false
+#type = "Text"
+
+#
+# Defaults for the check command (see help check)
+#
+#[check]
+#skip = ["single"]
+#distance = 2
+#similarity = 0.9
+#tolerance = 0.01
+
+#
+# Loans and mortgages (see help loan)
+#
+#[loans.mortgage]
+#principal = "250000"
+#rate = 4.5
+#term = 300
+#start = "2023-06-12"
+#paymentday = 1
+#account = "Asset:Bank"
+#liability = "Liability:Mortgage"
+#interest = "Expense:Interest"
+#dedup = true
 
 [importdefs.bankformat]
 description = "Bank Format"
@@ -151,7 +234,13 @@ configtype = "csv"
 account = "Asset:BankDefaultAccount"
 counteraccount = "Expense:DefaultExpenseAccount"
 dedup = true
+#dedupstrategy = "similar"
+#dedupdays = 3
+#dedupsimilarity = 0.7
 reclassify = true
+#reclassifythreshold = 0.5
+#rulesfile = "rules.toml"
+#rulesmode = "first"
 
 [importdefs.bankformat.params]
 ccy = "ÃÂÃÂÃÂÃÂ£"
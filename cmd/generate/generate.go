@@ -22,7 +22,7 @@ func (gen *Generate) run(app *app.App, rcmd *cobra.Command, args []string) error
 	}
 
 	// Generate the records from the main book
-	b, err := gen.generate(main, gen.Code)
+	b, err := gen.generate(main, app.Divider, gen.Code)
 	if err != nil {
 		return fmt.Errorf("error processing data: %w", err)
 	}
@@ -6,12 +6,12 @@ import (
 	"go.starlark.net/starlark"
 )
 
-func (gen *Generate) generate(b *book.Book, sc string) (*book.Book, error) {
+func (gen *Generate) generate(b *book.Book, divider string, sc string) (*book.Book, error) {
 
 	bbuilder := book.NewBookBuilder()
 	addf := script.GetBuilderFunction(bbuilder, "acct", "ccy", "cacct")
 	globals := map[string]starlark.Value{
-		"data":  script.ConvertBookToStarlark(b),
+		"data":  script.ConvertBookToStarlark(b, divider),
 		"add":   addf,
 		"error": script.Errorf,
 	}
@@ -7,8 +7,10 @@ import (
 	"github.com/mescanne/goledger/cmd/app"
 	"github.com/mescanne/goledger/cmd/reports"
 	"github.com/mescanne/goledger/cmd/utils"
+	"github.com/mescanne/goledger/script"
 	"github.com/spf13/cobra"
 	"os"
+	"strings"
 )
 
 type ImportDef struct {
@@ -31,6 +33,129 @@ type ImportDef struct {
 	// Rules to apply after import
 	Dedup      bool
 	Reclassify bool
+
+	// Deduplication strategy: exact (date and payee) or similar (account,
+	// amount and currency within DedupDays, with payee similarity of at
+	// least DedupSimilarity)
+	DedupStrategy   string
+	DedupDays       int
+	DedupSimilarity float64
+
+	// Report the transactions dropped as duplicates
+	ShowDuplicates bool
+
+	// Minimum confidence (0.0-1.0) to reclassify the counteraccount
+	ReclassifyThreshold float64
+
+	// Explain the reclassification of each transaction
+	Explain bool
+
+	// Rules applied before deduplication and reclassification (see help rules)
+	Rules     []Rule
+	RulesFile string
+	RulesMode string
+}
+
+// Defaults for the similar deduplication strategy
+const (
+	defaultDedupDays       = 3
+	defaultDedupSimilarity = 0.7
+
+	defaultReclassifyThreshold = 0.5
+)
+
+// Remove the transactions in b that duplicate those in ref
+func (imp *ImportDef) dedup(b *book.Book, ref *book.Book) ([]book.DuplicateMatch, error) {
+	switch imp.DedupStrategy {
+	case "", "exact":
+		return b.RemoveDuplicatesOf(ref), nil
+	case "similar":
+		days := imp.DedupDays
+		if days == 0 {
+			days = defaultDedupDays
+		}
+		minScore := imp.DedupSimilarity
+		if minScore == 0 {
+			minScore = defaultDedupSimilarity
+		}
+		return b.RemoveSimilarDuplicatesOf(ref, imp.Account, days, func(a, b string) float64 {
+			return matchr.JaroWinkler(strings.ToUpper(a), strings.ToUpper(b), true)
+		}, minScore), nil
+	default:
+		return nil, fmt.Errorf("invalid dedup strategy '%s': must be exact or similar", imp.DedupStrategy)
+	}
+}
+
+// Show the transactions dropped as duplicates as ledger comments
+func (imp *ImportDef) showDuplicates(bp *app.BookPrinter, matches []book.DuplicateMatch) {
+	for _, m := range matches {
+		amt := ""
+		for _, p := range m.Import {
+			if p.GetAccount() == imp.Account {
+				amt = " " + bp.FormatSimpleMoney(p.GetCCY(), p.GetAmount())
+				break
+			}
+		}
+		bp.Printf("; duplicate: %s %s%s matches %s %s (score %.2f)\n",
+			m.Import.GetDate(), m.Import.GetPayee(), amt,
+			m.Existing.GetDate(), m.Existing.GetPayee(), m.Score)
+	}
+	if len(matches) > 0 {
+		bp.Printf("\n")
+	}
+}
+
+// Show the reclassifications as ledger comments
+func (imp *ImportDef) showClassifications(bp *app.BookPrinter, results []*book.Classification) {
+	for _, r := range results {
+		cands := make([]string, 0, len(r.Candidates))
+		for _, c := range r.Candidates {
+			cands = append(cands, fmt.Sprintf("%s %.2f", c.Account, c.Probability))
+		}
+		action := "reclassified as " + r.Account
+		if !r.Applied {
+			action = "kept " + imp.CounterAccount
+		}
+		bp.Printf("; %s %s: %s (confidence %.2f; candidates %s)\n",
+			r.Transaction.GetDate(), r.Transaction.GetPayee(), action, r.Confidence, strings.Join(cands, ", "))
+		if imp.Explain {
+			for _, e := range r.Evidence {
+				bp.Printf(";   %s: %d of %d with %s\n", e.Feature, e.Count, e.Total, r.Account)
+			}
+		}
+	}
+	if len(results) > 0 {
+		bp.Printf("\n")
+	}
+}
+
+// Import the file (or stdin for -) into a new book
+func (imp *ImportDef) importFile(importer script.StarlarkReader, arg string) (*book.Book, error) {
+
+	// Get the reader
+	r := os.Stdin
+	if arg != "-" {
+		f, err := os.Open(arg)
+		if err != nil {
+			return nil, fmt.Errorf("error opening %s: %w", arg, err)
+		}
+		defer f.Close()
+		r = f
+	}
+
+	// Load the records
+	imports, err := importer(r)
+	if err != nil {
+		return nil, fmt.Errorf("error importing data: %w", err)
+	}
+
+	// Convert the records
+	b, err := imp.processData(imports, arg, imp.Code)
+	if err != nil {
+		return nil, fmt.Errorf("error processing data: %w", err)
+	}
+
+	return b, nil
 }
 
 func (imp *ImportDef) run(app *app.App, rcmd *cobra.Command, args []string) error {
@@ -55,56 +180,67 @@ func (imp *ImportDef) run(app *app.App, rcmd *cobra.Command, args []string) erro
 	// Track previous books
 	prevbooks := make([]*book.Book, 0, len(args))
 
+	// Classifier trained on the main book
+	var classifier *book.Classifier
+
 	// Iterate the import files
 	for _, arg := range args {
 
-		// Get the reader
-		r := os.Stdin
-		if arg != "-" {
-			r, err = os.Open(arg)
-			if err != nil {
-				return fmt.Errorf("error opening %s: %w", arg, err)
-			}
-			defer r.Close()
-		}
-
-		// Load the records
-		imports, err := importer(r)
+		// Import the file
+		b, err := imp.importFile(importer, arg)
 		if err != nil {
-			return fmt.Errorf("error importing data: %w", err)
+			return err
 		}
 
-		// Convert the records
-		b, err := imp.processData(imports, arg, imp.Code)
+		// Apply the rules
+		b, _, err = imp.applyRules(b, main.GetCCYDecimals())
 		if err != nil {
-			return fmt.Errorf("error processing data: %w", err)
+			return err
 		}
 
 		// Deduplication if needed (including previous books)
+		duplicates := make([]book.DuplicateMatch, 0)
 		if imp.Dedup {
-			b.RemoveDuplicatesOf(main)
+			matches, err := imp.dedup(b, main)
+			if err != nil {
+				return err
+			}
+			duplicates = append(duplicates, matches...)
 		}
 
 		// Always deduplicate multiple files
 		for _, prev := range prevbooks {
-			b.RemoveDuplicatesOf(prev)
+			matches, err := imp.dedup(b, prev)
+			if err != nil {
+				return err
+			}
+			duplicates = append(duplicates, matches...)
 		}
 
 		// Reclassification if needed
+		classified := make([]*book.Classification, 0)
 		if imp.Reclassify {
-			b.ReclassifyByAccount(main, imp.Account, func(a, b string) float64 {
-				d := matchr.JaroWinkler(a, b, true)
-				if d > 0.5 {
-					return d
-				} else {
-					return 0.0
-				}
-			})
+			threshold := imp.ReclassifyThreshold
+			if threshold == 0 {
+				threshold = defaultReclassifyThreshold
+			}
+			if classifier == nil {
+				classifier = book.NewClassifier(main, imp.Account)
+			}
+			classified = b.ReclassifyByClassifier(classifier, imp.CounterAccount, threshold)
 		}
 
 		// Use decimals of main book
 		bp := app.NewBookPrinter(main.GetCCYDecimals())
 
+		// Report the duplicates dropped
+		if imp.ShowDuplicates {
+			imp.showDuplicates(bp, duplicates)
+		}
+
+		// Report the reclassifications
+		imp.showClassifications(bp, classified)
+
 		// Dump report ledger-style
 		if err := reports.ShowLedger(bp, b.Transactions()); err != nil {
 			return err
@@ -126,7 +262,15 @@ func (imp *ImportDef) add(name string, app *app.App) *cobra.Command {
 		DisableAutoGenTag: true,
 	}
 	ncmd.Flags().BoolVarP(&imp.Dedup, "dedup", "d", imp.Dedup, "deduplicate transactions based on payee and date")
+	ncmd.Flags().StringVar(&imp.DedupStrategy, "dedup-strategy", imp.DedupStrategy, "deduplication strategy: exact (date and payee) or similar (amount within days)")
+	ncmd.Flags().IntVar(&imp.DedupDays, "dedup-days", imp.DedupDays, fmt.Sprintf("days either side to match for similar deduplication (default %d)", defaultDedupDays))
+	ncmd.Flags().Float64Var(&imp.DedupSimilarity, "dedup-similarity", imp.DedupSimilarity, fmt.Sprintf("minimum payee similarity (0.0-1.0) for similar deduplication (default %.1f)", defaultDedupSimilarity))
+	ncmd.Flags().BoolVar(&imp.ShowDuplicates, "show-duplicates", imp.ShowDuplicates, "show the transactions dropped as duplicates")
+	ncmd.Flags().StringVar(&imp.RulesFile, "rules", imp.RulesFile, "file of import rules (see help rules)")
+	ncmd.Flags().StringVar(&imp.RulesMode, "rules-mode", imp.RulesMode, "apply the first matching rule (first) or every matching rule (all)")
 	ncmd.Flags().BoolVarP(&imp.Reclassify, "reclassify", "r", imp.Reclassify, "reclassify the counteraccount based on previous transactions")
+	ncmd.Flags().Float64Var(&imp.ReclassifyThreshold, "threshold", imp.ReclassifyThreshold, fmt.Sprintf("minimum confidence (0.0-1.0) to reclassify (default %.1f)", defaultReclassifyThreshold))
+	ncmd.Flags().BoolVar(&imp.Explain, "explain", imp.Explain, "explain the features behind each reclassification")
 	if imp.Code == "" {
 		ncmd.Flags().StringVar(&imp.Code, "code", imp.Code, "code for import or file:<file> for external code (see help code)")
 		cobra.MarkFlagRequired(ncmd.Flags(), "code")
@@ -166,6 +310,9 @@ func Add(root *cobra.Command, app *app.App, config map[string]*ImportDef) {
 		ncmd.AddCommand(def.add(name, app))
 	}
 
+	// Rules testing
+	addRules(root, app, config)
+
 	// Add in the help
 	root.AddCommand(&cobra.Command{
 		Use:               "format",
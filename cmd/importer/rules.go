@@ -0,0 +1,472 @@
+package importer
+
+import (
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"github.com/mescanne/goledger/book"
+	"github.com/mescanne/goledger/cmd/app"
+	"github.com/spf13/cobra"
+	"io/ioutil"
+	"math/big"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var RulesUsage = `Import Rules
+
+Rules are applied to imported transactions (in order) before deduplication and
+reclassification. They are configured for an import definition in the config
+file or in a separate rules file (rulesfile), with the same structure:
+
+    [[rules]]
+    name = "groceries"
+    payee = "TESCO|SAINSBURY"
+    maxamount = "0"
+    counteraccount = "Expense:Groceries"
+    tags = [ "food" ]
+
+Conditions (all specified must match):
+  payee            - regular expression matching the payee
+  note             - regular expression matching the transaction note
+  account          - regular expression matching any posting account
+  minamount        - amount of the import account posting is at least this
+  maxamount        - amount of the import account posting is less than this
+  since            - transaction date is on or after this date
+  before           - transaction date is before this date
+
+Actions:
+  counteraccount   - set the counteraccount
+  setpayee         - rewrite the payee (may use $1, $2 from the payee expression)
+  split            - split the counteraccount by percentage, eg
+                     split = [ { account = "Expense:A", percent = 60 },
+                               { account = "Expense:B", percent = 40 } ]
+                     Any percentage remaining stays in the counteraccount
+  tags             - add tags to the transaction note
+  meta             - add key-value metadata to the transaction note
+  drop             - drop the transaction
+
+With rulesmode "first" (default) only the first matching rule is applied, and
+with "all" every matching rule is applied in order. Postings split by a rule
+are kept by later rules: counteraccount and split only apply to the remaining
+counteraccount.
+`
+
+// Split of a counteraccount by percentage
+type RuleSplit struct {
+	Account string
+	Percent float64
+}
+
+// Import rule
+type Rule struct {
+	Name string
+
+	// Conditions
+	Payee     string
+	Note      string
+	Account   string
+	MinAmount string
+	MaxAmount string
+	Since     string
+	Before    string
+
+	// Actions
+	CounterAccount string
+	SetPayee       string
+	Split          []RuleSplit
+	Tags           []string
+	Meta           map[string]string
+	Drop           bool
+}
+
+// Rule ready for matching
+type compiledRule struct {
+	*Rule
+	name      string
+	payee     *regexp.Regexp
+	note      *regexp.Regexp
+	account   *regexp.Regexp
+	minAmount *big.Rat
+	maxAmount *big.Rat
+	since     book.Date
+	before    book.Date
+	split     []*big.Rat
+}
+
+// Result of applying the rules to an imported transaction
+type RuleResult struct {
+	Transaction book.Transaction // Transaction as imported
+	Rules       []string         // Names of the rules applied
+	Dropped     bool
+	Accounts    []string // Counteraccounts after the rules
+}
+
+// Posting of a transaction being rewritten
+type rulePosting struct {
+	acct  string
+	ccy   string
+	amt   *big.Rat
+	note  string
+	split bool // share from a split, kept by later rules
+}
+
+func compileRegexp(re string) (*regexp.Regexp, error) {
+	if re == "" {
+		return nil, nil
+	}
+	return regexp.Compile(re)
+}
+
+func compileAmount(amt string) (*big.Rat, error) {
+	if amt == "" {
+		return nil, nil
+	}
+	v, ok := new(big.Rat).SetString(amt)
+	if !ok {
+		return nil, fmt.Errorf("invalid amount '%s'", amt)
+	}
+	return v, nil
+}
+
+func (r *Rule) compile(idx int) (*compiledRule, error) {
+	c := &compiledRule{Rule: r, name: r.Name}
+	if c.name == "" {
+		c.name = fmt.Sprintf("#%d", idx+1)
+	}
+
+	var err error
+	if c.payee, err = compileRegexp(r.Payee); err != nil {
+		return nil, fmt.Errorf("rule %s payee: %w", c.name, err)
+	}
+	if c.note, err = compileRegexp(r.Note); err != nil {
+		return nil, fmt.Errorf("rule %s note: %w", c.name, err)
+	}
+	if c.account, err = compileRegexp(r.Account); err != nil {
+		return nil, fmt.Errorf("rule %s account: %w", c.name, err)
+	}
+	if c.minAmount, err = compileAmount(r.MinAmount); err != nil {
+		return nil, fmt.Errorf("rule %s minamount: %w", c.name, err)
+	}
+	if c.maxAmount, err = compileAmount(r.MaxAmount); err != nil {
+		return nil, fmt.Errorf("rule %s maxamount: %w", c.name, err)
+	}
+	if r.Since != "" {
+		if c.since, err = book.ParseDate(r.Since); err != nil {
+			return nil, fmt.Errorf("rule %s since: %w", c.name, err)
+		}
+	}
+	if r.Before != "" {
+		if c.before, err = book.ParseDate(r.Before); err != nil {
+			return nil, fmt.Errorf("rule %s before: %w", c.name, err)
+		}
+	}
+	if r.SetPayee != "" && c.payee == nil {
+		return nil, fmt.Errorf("rule %s: setpayee requires a payee expression", c.name)
+	}
+
+	// Split fractions, with any remaining fraction for the counteraccount (or
+	// the rounding for the last split account if the split is 100%)
+	total := new(big.Rat)
+	for _, s := range r.Split {
+		pct, ok := new(big.Rat).SetString(strconv.FormatFloat(s.Percent, 'f', -1, 64))
+		if !ok || pct.Sign() <= 0 || s.Account == "" {
+			return nil, fmt.Errorf("rule %s: invalid split %s %v%%", c.name, s.Account, s.Percent)
+		}
+		f := pct.Quo(pct, big.NewRat(100, 1))
+		total.Add(total, f)
+		c.split = append(c.split, f)
+	}
+	if total.Cmp(big.NewRat(1, 1)) > 0 {
+		return nil, fmt.Errorf("rule %s: split is more than 100%%", c.name)
+	}
+	if len(c.split) > 0 && total.Cmp(big.NewRat(1, 1)) < 0 {
+		c.split = append(c.split, new(big.Rat).Sub(big.NewRat(1, 1), total))
+	}
+
+	return c, nil
+}
+
+// Return the rules of the import definition (config and rules file)
+func (imp *ImportDef) getRules() ([]*compiledRule, error) {
+	rules := make([]Rule, 0, len(imp.Rules))
+	rules = append(rules, imp.Rules...)
+
+	if imp.RulesFile != "" {
+		b, err := ioutil.ReadFile(imp.RulesFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading rules file: %w", err)
+		}
+		var rfile struct{ Rules []Rule }
+		md, err := toml.Decode(string(b), &rfile)
+		if err != nil {
+			return nil, fmt.Errorf("reading rules file %s: %w", imp.RulesFile, err)
+		}
+		for _, k := range md.Undecoded() {
+			return nil, fmt.Errorf("extra config in rules file %s: %s", imp.RulesFile, k.String())
+		}
+		rules = append(rules, rfile.Rules...)
+	}
+
+	switch imp.RulesMode {
+	case "", "first", "all":
+	default:
+		return nil, fmt.Errorf("invalid rules mode '%s': must be first or all", imp.RulesMode)
+	}
+
+	compiled := make([]*compiledRule, 0, len(rules))
+	for i := range rules {
+		c, err := rules[i].compile(i)
+		if err != nil {
+			return nil, err
+		}
+		compiled = append(compiled, c)
+	}
+
+	return compiled, nil
+}
+
+// Check if the rule matches the transaction, amt being the import account amount
+func (r *compiledRule) matches(date book.Date, payee string, note string, posts []rulePosting, amt *big.Rat) bool {
+	if r.payee != nil && !r.payee.MatchString(payee) {
+		return false
+	}
+	if r.note != nil && !r.note.MatchString(note) {
+		return false
+	}
+	if r.account != nil {
+		found := false
+		for _, p := range posts {
+			if r.account.MatchString(p.acct) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if r.minAmount != nil && amt.Cmp(r.minAmount) < 0 {
+		return false
+	}
+	if r.maxAmount != nil && amt.Cmp(r.maxAmount) >= 0 {
+		return false
+	}
+	if r.since != 0 && date < r.since {
+		return false
+	}
+	if r.before != 0 && date >= r.before {
+		return false
+	}
+	return true
+}
+
+// Add tags and metadata to a note
+func (r *compiledRule) annotate(note string) string {
+	parts := make([]string, 0, 2+len(r.Meta))
+	if note != "" {
+		parts = append(parts, note)
+	}
+	if len(r.Tags) > 0 {
+		parts = append(parts, ":"+strings.Join(r.Tags, ":")+":")
+	}
+	keys := make([]string, 0, len(r.Meta))
+	for k := range r.Meta {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		parts = append(parts, fmt.Sprintf("%s: %s", k, r.Meta[k]))
+	}
+	return strings.Join(parts, " ")
+}
+
+// Apply the import rules to the book.
+//
+// Returns the new book (or the same book if there are no rules) and the result
+// of the rules for each transaction.
+func (imp *ImportDef) applyRules(b *book.Book, decimals map[string]int) (*book.Book, []RuleResult, error) {
+	rules, err := imp.getRules()
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(rules) == 0 {
+		return b, nil, nil
+	}
+
+	results := make([]RuleResult, 0)
+	builder := book.NewBookBuilder()
+	for _, trans := range b.Transactions() {
+		date := trans.GetDate()
+		payee := trans.GetPayee()
+		note := trans.GetTransactionNote()
+		posts := make([]rulePosting, 0, len(trans))
+		for _, p := range trans {
+			posts = append(posts, rulePosting{p.GetAccount(), p.GetCCY(), p.GetAmount(), p.GetPostNote(), false})
+		}
+
+		// Amount of the import account
+		amt := posts[0].amt
+		for _, p := range posts {
+			if p.acct == imp.Account {
+				amt = p.amt
+				break
+			}
+		}
+
+		result := RuleResult{Transaction: trans, Rules: make([]string, 0, 1)}
+		for _, r := range rules {
+			if !r.matches(date, payee, note, posts, amt) {
+				continue
+			}
+			result.Rules = append(result.Rules, r.name)
+
+			if r.Drop {
+				result.Dropped = true
+				break
+			}
+			if r.SetPayee != "" {
+				payee = r.payee.ReplaceAllString(payee, r.SetPayee)
+			}
+			note = r.annotate(note)
+			if r.CounterAccount != "" {
+				for i := range posts {
+					if posts[i].acct != imp.Account && !posts[i].split {
+						posts[i].acct = r.CounterAccount
+					}
+				}
+			}
+			if len(r.split) > 0 {
+				nposts := make([]rulePosting, 0, len(posts)+len(r.Split))
+				for _, p := range posts {
+					if p.acct == imp.Account || p.split {
+						nposts = append(nposts, p)
+						continue
+					}
+					dec, ok := decimals[p.ccy]
+					if !ok {
+						dec = 2
+					}
+					parts := book.SplitAmount(p.amt, r.split, dec)
+					for i, s := range r.Split {
+						nposts = append(nposts, rulePosting{s.Account, p.ccy, parts[i], p.note, true})
+					}
+					if len(parts) > len(r.Split) && parts[len(r.Split)].Sign() != 0 {
+						nposts = append(nposts, rulePosting{p.acct, p.ccy, parts[len(r.Split)], p.note, false})
+					}
+				}
+				posts = nposts
+			}
+
+			if imp.RulesMode != "all" {
+				break
+			}
+		}
+
+		if !result.Dropped {
+			builder.NewTransaction(date, payee, note)
+			for _, p := range posts {
+				builder.AddPosting(p.acct, p.ccy, p.amt, p.note)
+				if p.acct != imp.Account {
+					result.Accounts = append(result.Accounts, p.acct)
+				}
+			}
+		}
+		results = append(results, result)
+	}
+
+	return builder.Build(), results, nil
+}
+
+// Add the rules command to test import rules
+func addRules(root *cobra.Command, app *app.App, config map[string]*ImportDef) {
+	rcmd := &cobra.Command{
+		Use:               "rules",
+		Short:             "Import rules",
+		Long:              RulesUsage,
+		DisableAutoGenTag: true,
+	}
+	root.AddCommand(rcmd)
+
+	tcmd := &cobra.Command{
+		Use:               "test <importdef> <file>...",
+		Short:             "Show the rules applied to each imported transaction",
+		Long:              "Show the rules applied to each imported transaction",
+		Args:              cobra.MinimumNArgs(2),
+		DisableAutoGenTag: true,
+	}
+	tcmd.RunE = func(cmd *cobra.Command, args []string) error {
+		imp, ok := config[args[0]]
+		if !ok {
+			return fmt.Errorf("unknown import definition '%s'", args[0])
+		}
+		if imp.CCY == "" {
+			imp.CCY = app.BaseCCY
+		}
+
+		importer, err := NewBookImporterByConfig(&imp.CLIConfig)
+		if err != nil {
+			return fmt.Errorf("invalid import configuration for '%v': %w", &imp.CLIConfig, err)
+		}
+
+		main, err := app.LoadBook()
+		if err != nil {
+			return fmt.Errorf("error loading book: %s", err)
+		}
+		all := make([]RuleResult, 0)
+		for _, arg := range args[1:] {
+			b, err := imp.importFile(importer, arg)
+			if err != nil {
+				return err
+			}
+			_, results, err := imp.applyRules(b, main.GetCCYDecimals())
+			if err != nil {
+				return err
+			}
+			all = append(all, results...)
+		}
+
+		imp.showRuleResults(app.NewBookPrinter(main.GetCCYDecimals()), all)
+
+		return nil
+	}
+	rcmd.AddCommand(tcmd)
+}
+
+// Show the rules applied to each transaction
+func (imp *ImportDef) showRuleResults(bp *app.BookPrinter, results []RuleResult) {
+	rows := make([][]app.ColumnValue, 0, len(results)+1)
+	rows = append(rows, []app.ColumnValue{
+		app.ColumnString(bp.Ansi(app.UL, "Date")),
+		app.ColumnString(bp.Ansi(app.UL, "Payee")),
+		app.ColumnRightString(bp.Ansi(app.UL, "Amount")),
+		app.ColumnString(bp.Ansi(app.UL, "Rules")),
+		app.ColumnString(bp.Ansi(app.UL, "Result")),
+	})
+	for _, r := range results {
+		amt := r.Transaction[0]
+		for _, p := range r.Transaction {
+			if p.GetAccount() == imp.Account {
+				amt = p
+				break
+			}
+		}
+		fired := "-"
+		if len(r.Rules) > 0 {
+			fired = strings.Join(r.Rules, ",")
+		}
+		res := strings.Join(r.Accounts, ",")
+		if r.Dropped {
+			res = bp.Ansi(app.Red, "dropped")
+		}
+		rows = append(rows, []app.ColumnValue{
+			app.ColumnString(r.Transaction.GetDate().String()),
+			app.ColumnString(r.Transaction.GetPayee()),
+			bp.GetColumnMoney(amt.GetCCY(), amt.GetAmount()),
+			app.ColumnString(fired),
+			app.ColumnString(res),
+		})
+	}
+	bp.PrintColumns(rows, []bool{false, true, false, false, true})
+}
@@ -0,0 +1,132 @@
+package importer
+
+import (
+	"fmt"
+	"github.com/mescanne/goledger/book"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+// Book of imported transactions, each paying the amount (in pence) from the bank
+func getImportBook(payees []string, amounts []int64) *book.Book {
+	b := book.NewBookBuilder()
+	for i, payee := range payees {
+		b.NewTransaction(book.GetDate(2020, 1, i+1), payee, "")
+		b.AddPosting("Asset:Bank", "GBP", big.NewRat(-amounts[i], 100), "")
+		b.AddPosting("Expense:Unknown", "GBP", big.NewRat(amounts[i], 100), "")
+	}
+	return b.Build()
+}
+
+// Show the transactions as payee, and account=amount of the postings not in the bank
+func dumpImport(b *book.Book) string {
+	trans := make([]string, 0)
+	for _, t := range b.Transactions() {
+		posts := make([]string, 0, len(t))
+		for _, p := range t {
+			if p.GetAccount() != "Asset:Bank" {
+				posts = append(posts, fmt.Sprintf("%s=%s", p.GetAccount(), p.GetAmount().FloatString(2)))
+			}
+		}
+		trans = append(trans, t.GetPayee()+" "+strings.Join(posts, " "))
+	}
+	return strings.Join(trans, ",")
+}
+
+func TestApplyRules(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules []Rule
+		exp   string
+	}{
+		{"none", nil,
+			"TESCO 123 Expense:Unknown=10.00,SHELL Expense:Unknown=20.00"},
+		{"match", []Rule{{Payee: "^TESCO", CounterAccount: "Expense:Groceries"}},
+			"TESCO 123 Expense:Groceries=10.00,SHELL Expense:Unknown=20.00"},
+		{"amount", []Rule{{MaxAmount: "-15", CounterAccount: "Expense:Fuel"}},
+			"TESCO 123 Expense:Unknown=10.00,SHELL Expense:Fuel=20.00"},
+		{"drop", []Rule{{Payee: "SHELL", Drop: true}},
+			"TESCO 123 Expense:Unknown=10.00"},
+		{"setpayee", []Rule{{Payee: "^TESCO ([0-9]+)$", SetPayee: "Tesco Store $1"}},
+			"Tesco Store 123 Expense:Unknown=10.00,SHELL Expense:Unknown=20.00"},
+		{"counteraccount", []Rule{{Payee: "SHELL", CounterAccount: "Expense:Fuel"}, {CounterAccount: "Expense:Other"}},
+			"TESCO 123 Expense:Other=10.00,SHELL Expense:Fuel=20.00"},
+		{"split remainder", []Rule{{Payee: "SHELL", Split: []RuleSplit{{"Expense:A", 60}, {"Expense:B", 15}}}},
+			"TESCO 123 Expense:Unknown=10.00,SHELL Expense:A=12.00 Expense:B=3.00 Expense:Unknown=5.00"},
+		{"split 100%", []Rule{{Payee: "TESCO", Split: []RuleSplit{{"Expense:A", 33.33}, {"Expense:B", 33.33}, {"Expense:C", 33.34}}}},
+			"TESCO 123 Expense:A=3.33 Expense:B=3.33 Expense:C=3.34,SHELL Expense:Unknown=20.00"},
+	}
+
+	for _, test := range tests {
+		imp := &ImportDef{Account: "Asset:Bank", Rules: test.rules}
+		b, _, err := imp.applyRules(getImportBook([]string{"TESCO 123", "SHELL"}, []int64{1000, 2000}), map[string]int{"GBP": 2})
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+			continue
+		}
+		if got := dumpImport(b); got != test.exp {
+			t.Errorf("%s: expected %s, got %s", test.name, test.exp, got)
+		}
+	}
+}
+
+func TestRulesMode(t *testing.T) {
+	rules := []Rule{
+		{Name: "first", Payee: "TESCO", CounterAccount: "Expense:Groceries"},
+		{Name: "second", Payee: "TESCO", Tags: []string{"food"}},
+	}
+
+	for mode, exp := range map[string]string{"first": "first", "all": "first,second"} {
+		imp := &ImportDef{Account: "Asset:Bank", Rules: rules, RulesMode: mode}
+		_, results, err := imp.applyRules(getImportBook([]string{"TESCO 123"}, []int64{1000}), nil)
+		if err != nil {
+			t.Fatalf("mode %s: unexpected error %v", mode, err)
+		}
+		if got := strings.Join(results[0].Rules, ","); got != exp {
+			t.Errorf("mode %s: expected rules %s, got %s", mode, exp, got)
+		}
+	}
+}
+
+func TestRulesAllSplit(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules []Rule
+		exp   string
+	}{
+		{"split then counteraccount", []Rule{{Payee: "SHELL", Split: []RuleSplit{{"Expense:A", 60}}}, {CounterAccount: "Expense:Other"}},
+			"TESCO 123 Expense:Other=10.00,SHELL Expense:A=12.00 Expense:Other=8.00"},
+		{"split 100% then counteraccount", []Rule{{Payee: "SHELL", Split: []RuleSplit{{"Expense:A", 50}, {"Expense:B", 50}}}, {CounterAccount: "Expense:Other"}},
+			"TESCO 123 Expense:Other=10.00,SHELL Expense:A=10.00 Expense:B=10.00"},
+		{"split then split", []Rule{{Payee: "SHELL", Split: []RuleSplit{{"Expense:A", 50}}}, {Split: []RuleSplit{{"Expense:B", 50}}}},
+			"TESCO 123 Expense:B=5.00 Expense:Unknown=5.00,SHELL Expense:A=10.00 Expense:B=5.00 Expense:Unknown=5.00"},
+	}
+
+	for _, test := range tests {
+		imp := &ImportDef{Account: "Asset:Bank", Rules: test.rules, RulesMode: "all"}
+		b, _, err := imp.applyRules(getImportBook([]string{"TESCO 123", "SHELL"}, []int64{1000, 2000}), map[string]int{"GBP": 2})
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+			continue
+		}
+		if got := dumpImport(b); got != test.exp {
+			t.Errorf("%s: expected %s, got %s", test.name, test.exp, got)
+		}
+	}
+}
+
+func TestRulesInvalid(t *testing.T) {
+	tests := map[string]Rule{
+		"split over 100%":      {Split: []RuleSplit{{"Expense:A", 60}, {"Expense:B", 50}}},
+		"setpayee no payee":    {SetPayee: "Shop"},
+		"invalid payee regexp": {Payee: "("},
+		"invalid amount":       {MinAmount: "ten"},
+	}
+	for name, rule := range tests {
+		imp := &ImportDef{Account: "Asset:Bank", Rules: []Rule{rule}}
+		if _, _, err := imp.applyRules(getImportBook(nil, nil), nil); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
@@ -0,0 +1,387 @@
+package loan
+
+import (
+	"fmt"
+	"github.com/mescanne/goledger/book"
+	"github.com/mescanne/goledger/cmd/app"
+	"github.com/mescanne/goledger/cmd/reports"
+	"github.com/spf13/cobra"
+	"math/big"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+const loan_long = `Loans and mortgages
+
+Loans are configured in the configuration file, eg:
+
+    [loans.mortgage]
+    description = "House mortgage"
+    principal = "250000"
+    rate = 4.5             # annual percentage
+    term = 300             # months
+    start = "2023-06-12"   # payments start the following month
+    paymentday = 1
+    account = "Asset:Bank"
+    liability = "Liability:Mortgage"
+    interest = "Expense:Interest"
+    overpayments = [ { date = "2024-01-01", amount = "5000" } ]
+    ratechanges = [ { date = "2025-06-01", rate = 5.1 } ]
+
+Interest is charged monthly on the balance before each payment, and the
+payment is recalculated over the remaining term after an overpayment or
+rate change.
+`
+
+// Overpayment of a loan
+type OverpaymentConfig struct {
+	Date   string
+	Amount string
+}
+
+// Change of the rate of a loan
+type RateChangeConfig struct {
+	Date string
+	Rate float64
+}
+
+// Loan configuration
+type LoanConfig struct {
+	Description string
+
+	// Loan terms
+	Principal    string
+	Rate         float64 // Annual percentage
+	Term         int     // Months
+	Start        string
+	PaymentDay   int
+	Overpayments []OverpaymentConfig
+	RateChanges  []RateChangeConfig
+
+	// Transactions
+	CCY       string
+	Payee     string
+	Account   string // Account payments are made from
+	Liability string
+	Interest  string
+	Dedup     bool
+}
+
+// Defaults if none specified
+var DefaultLoan LoanConfig = LoanConfig{
+	Account:   "Asset:Bank",
+	Liability: "Liability:Mortgage",
+	Interest:  "Expense:Interest",
+}
+
+// Convert an annual percentage to a rate
+func percentToRate(pct float64) (*big.Rat, error) {
+	r, ok := new(big.Rat).SetString(strconv.FormatFloat(pct, 'f', -1, 64))
+	if !ok {
+		return nil, fmt.Errorf("invalid rate %v", pct)
+	}
+	return r.Quo(r, big.NewRat(100, 1)), nil
+}
+
+// Return the loan model for the configuration
+func (cfg *LoanConfig) getLoan() (*book.Loan, error) {
+	principal, ok := new(big.Rat).SetString(cfg.Principal)
+	if !ok {
+		return nil, fmt.Errorf("invalid principal '%s'", cfg.Principal)
+	}
+	rate, err := percentToRate(cfg.Rate)
+	if err != nil {
+		return nil, err
+	}
+	start, err := book.ParseDate(cfg.Start)
+	if err != nil {
+		return nil, fmt.Errorf("start date: %w", err)
+	}
+
+	loan := &book.Loan{
+		Principal:  principal,
+		Rate:       rate,
+		Term:       cfg.Term,
+		Start:      start,
+		PaymentDay: cfg.PaymentDay,
+	}
+	for _, o := range cfg.Overpayments {
+		amt, ok := new(big.Rat).SetString(o.Amount)
+		if !ok || amt.Sign() <= 0 {
+			return nil, fmt.Errorf("invalid overpayment amount '%s'", o.Amount)
+		}
+		d, err := book.ParseDate(o.Date)
+		if err != nil {
+			return nil, fmt.Errorf("overpayment date: %w", err)
+		}
+		loan.Overpayments = append(loan.Overpayments, book.LoanOverpayment{Date: d, Amount: amt})
+	}
+	for _, c := range cfg.RateChanges {
+		r, err := percentToRate(c.Rate)
+		if err != nil {
+			return nil, err
+		}
+		d, err := book.ParseDate(c.Date)
+		if err != nil {
+			return nil, fmt.Errorf("rate change date: %w", err)
+		}
+		loan.RateChanges = append(loan.RateChanges, book.LoanRate{Date: d, Rate: r})
+	}
+
+	if err := loan.Validate(); err != nil {
+		return nil, err
+	}
+	return loan, nil
+}
+
+// Return the currency of the loan, the base CCY if none specified
+func (cfg *LoanConfig) currency(app *app.App) string {
+	if cfg.CCY == "" {
+		return app.BaseCCY
+	}
+	return cfg.CCY
+}
+
+// Return the schedule of the loan rounded for the currency of the main book
+func (cfg *LoanConfig) getSchedule(ccy string, main *book.Book) ([]book.LoanPeriod, error) {
+	loan, err := cfg.getLoan()
+	if err != nil {
+		return nil, err
+	}
+	dec, ok := main.GetCCYDecimals()[ccy]
+	if !ok {
+		dec = 2
+	}
+	return loan.Schedule(dec), nil
+}
+
+// Generate the transactions for the payments of the schedule before asof
+func (cfg *LoanConfig) generate(ccy string, periods []book.LoanPeriod, asof book.Date) *book.Book {
+	builder := book.NewBookBuilder()
+	for _, p := range periods {
+		if asof != 0 && p.Date >= asof {
+			break
+		}
+		total := new(big.Rat).Add(p.Payment, p.Overpayment)
+		builder.NewTransaction(p.Date, cfg.Payee, "")
+		builder.AddPosting(cfg.Liability, ccy, new(big.Rat).Add(p.Principal, p.Overpayment), "")
+		if p.Interest.Sign() != 0 {
+			builder.AddPosting(cfg.Interest, ccy, p.Interest, "")
+		}
+		builder.AddPosting(cfg.Account, ccy, total.Neg(total), "")
+	}
+	return builder.Build()
+}
+
+func (cfg *LoanConfig) add(name string, app *app.App) *cobra.Command {
+	if cfg.Account == "" {
+		cfg.Account = DefaultLoan.Account
+	}
+	if cfg.Liability == "" {
+		cfg.Liability = DefaultLoan.Liability
+	}
+	if cfg.Interest == "" {
+		cfg.Interest = DefaultLoan.Interest
+	}
+	if cfg.Payee == "" {
+		cfg.Payee = name
+	}
+	description := cfg.Description
+	if description == "" {
+		description = "Loan " + name
+	}
+
+	ncmd := &cobra.Command{
+		Use:               name,
+		Short:             description,
+		Long:              description,
+		DisableAutoGenTag: true,
+	}
+
+	scmd := &cobra.Command{
+		Use:               "schedule",
+		Short:             "Show the amortisation schedule",
+		Long:              "Show the amortisation schedule",
+		Args:              cobra.NoArgs,
+		DisableAutoGenTag: true,
+	}
+	scmd.RunE = func(cmd *cobra.Command, args []string) error {
+		main, err := app.LoadBook()
+		if err != nil {
+			return err
+		}
+		ccy := cfg.currency(app)
+		periods, err := cfg.getSchedule(ccy, main)
+		if err != nil {
+			return fmt.Errorf("loan %s: %w", name, err)
+		}
+		cfg.showSchedule(app.NewBookPrinter(main.GetCCYDecimals()), ccy, periods)
+		return nil
+	}
+	ncmd.AddCommand(scmd)
+
+	var asof string
+	gcmd := &cobra.Command{
+		Use:               "generate",
+		Short:             "Generate the payment transactions",
+		Long:              "Generate the payment transactions up to (excluding) the asof date",
+		Args:              cobra.NoArgs,
+		DisableAutoGenTag: true,
+	}
+	gcmd.Flags().StringVar(&asof, "asof", "", "generate payments before this date (default today, or all for the full term)")
+	gcmd.Flags().BoolVarP(&cfg.Dedup, "dedup", "d", cfg.Dedup, "deduplicate transactions based on payee and date")
+	gcmd.RunE = func(cmd *cobra.Command, args []string) error {
+		end, err := parseAsof(asof)
+		if err != nil {
+			return err
+		}
+		main, err := app.LoadBook()
+		if err != nil {
+			return err
+		}
+		ccy := cfg.currency(app)
+		periods, err := cfg.getSchedule(ccy, main)
+		if err != nil {
+			return fmt.Errorf("loan %s: %w", name, err)
+		}
+
+		b := cfg.generate(ccy, periods, end)
+		if cfg.Dedup {
+			b.RemoveDuplicatesOf(main)
+		}
+
+		// Use decimals of main book
+		bp := app.NewBookPrinter(main.GetCCYDecimals())
+		return reports.ShowLedger(bp, b.Transactions())
+	}
+	ncmd.AddCommand(gcmd)
+
+	var casof string
+	ccmd := &cobra.Command{
+		Use:               "compare",
+		Short:             "Compare the scheduled balance with the ledger",
+		Long:              "Compare the remaining principal of the schedule with the liability balance in the ledger after each payment",
+		Args:              cobra.NoArgs,
+		DisableAutoGenTag: true,
+	}
+	ccmd.Flags().StringVar(&casof, "asof", "", "compare payments before this date (default today, or all for the full term)")
+	ccmd.RunE = func(cmd *cobra.Command, args []string) error {
+		end, err := parseAsof(casof)
+		if err != nil {
+			return err
+		}
+		main, err := app.LoadBook()
+		if err != nil {
+			return err
+		}
+		ccy := cfg.currency(app)
+		periods, err := cfg.getSchedule(ccy, main)
+		if err != nil {
+			return fmt.Errorf("loan %s: %w", name, err)
+		}
+		cfg.showCompare(app.NewBookPrinter(main.GetCCYDecimals()), main, ccy, periods, end)
+		return nil
+	}
+	ncmd.AddCommand(ccmd)
+
+	return ncmd
+}
+
+func parseAsof(asof string) (book.Date, error) {
+	if asof == "" {
+		return book.GetToday(), nil
+	}
+	if asof == "all" {
+		return 0, nil
+	}
+	d, err := book.ParseDate(asof)
+	if err != nil {
+		return 0, fmt.Errorf("asof date: %w", err)
+	}
+	return d, nil
+}
+
+func (cfg *LoanConfig) showSchedule(bp *app.BookPrinter, ccy string, periods []book.LoanPeriod) {
+	rows := make([][]app.ColumnValue, 0, len(periods)+1)
+	rows = append(rows, []app.ColumnValue{
+		app.ColumnString(bp.Ansi(app.UL, "Date")),
+		app.ColumnRightString(bp.Ansi(app.UL, "Rate")),
+		app.ColumnRightString(bp.Ansi(app.UL, "Payment")),
+		app.ColumnRightString(bp.Ansi(app.UL, "Interest")),
+		app.ColumnRightString(bp.Ansi(app.UL, "Principal")),
+		app.ColumnRightString(bp.Ansi(app.UL, "Overpayment")),
+		app.ColumnRightString(bp.Ansi(app.UL, "Balance")),
+	})
+	for _, p := range periods {
+		rate, _ := p.Rate.Float64()
+		rows = append(rows, []app.ColumnValue{
+			app.ColumnString(p.Date.String()),
+			app.ColumnRightString(fmt.Sprintf("%.2f%%", rate*100)),
+			bp.GetColumnMoney(ccy, p.Payment),
+			bp.GetColumnMoney(ccy, p.Interest),
+			bp.GetColumnMoney(ccy, p.Principal),
+			bp.GetColumnMoney(ccy, p.Overpayment),
+			bp.GetColumnMoney(ccy, p.Balance),
+		})
+	}
+	bp.PrintColumns(rows, make([]bool, 7))
+}
+
+func (cfg *LoanConfig) showCompare(bp *app.BookPrinter, main *book.Book, ccy string, periods []book.LoanPeriod, asof book.Date) {
+	re := regexp.MustCompile("^" + regexp.QuoteMeta(cfg.Liability) + "$")
+
+	rows := make([][]app.ColumnValue, 0, len(periods)+1)
+	rows = append(rows, []app.ColumnValue{
+		app.ColumnString(bp.Ansi(app.UL, "Date")),
+		app.ColumnRightString(bp.Ansi(app.UL, "Schedule")),
+		app.ColumnRightString(bp.Ansi(app.UL, "Ledger")),
+		app.ColumnRightString(bp.Ansi(app.UL, "Difference")),
+	})
+	for _, p := range periods {
+		if asof != 0 && p.Date >= asof {
+			break
+		}
+
+		// Liability balance (negative) after the payment date
+		ledger := new(big.Rat)
+		for _, ab := range main.AccountBalances(re, p.Date.AddDays(1)) {
+			if ab.CCY == ccy {
+				ledger.Neg(ab.Balance)
+			}
+		}
+		diff := new(big.Rat).Sub(ledger, p.Balance)
+
+		var diffCol app.ColumnValue = bp.GetColumnMoney(ccy, diff)
+		if diff.Sign() != 0 {
+			diffCol = app.ColumnRightString(bp.Ansi(app.Red, bp.FormatSimpleMoney(ccy, diff)))
+		}
+		rows = append(rows, []app.ColumnValue{
+			app.ColumnString(p.Date.String()),
+			bp.GetColumnMoney(ccy, p.Balance),
+			bp.GetColumnMoney(ccy, ledger),
+			diffCol,
+		})
+	}
+	bp.PrintColumns(rows, make([]bool, 4))
+}
+
+func Add(root *cobra.Command, app *app.App, config map[string]*LoanConfig) {
+	ncmd := &cobra.Command{
+		Use:               "loan",
+		Short:             "Loan and mortgage schedules",
+		Long:              loan_long,
+		DisableAutoGenTag: true,
+	}
+
+	names := make([]string, 0, len(config))
+	for name := range config {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		ncmd.AddCommand(config[name].add(name, app))
+	}
+
+	root.AddCommand(ncmd)
+}
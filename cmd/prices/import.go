@@ -0,0 +1,130 @@
+package prices
+
+import (
+	"fmt"
+	"github.com/mescanne/goledger/book"
+	"github.com/mescanne/goledger/cmd/app"
+	"github.com/mescanne/goledger/loader"
+	"github.com/spf13/cobra"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const import_long = `Import prices into a price database file
+
+Reads prices from the files (ledger P lines or CSV with date, unit, ccy,
+and price) and appends the prices that are not already in the price
+database file. A price is already there if the file has a price for the
+same unit and ccy on the same date.
+
+The price database file is written in the same format as it is read: CSV
+if it has a .csv extension, otherwise ledger P lines.
+
+The price database file defaults to the first configured price file (see
+the prices configuration) if it is not a glob.
+`
+
+func addImport(root *cobra.Command, app *app.App) {
+	var target string
+	ncmd := &cobra.Command{
+		Use:               "import <file>...",
+		Short:             "Import prices into a price database file",
+		Long:              import_long,
+		DisableAutoGenTag: true,
+	}
+	ncmd.Args = cobra.MinimumNArgs(1)
+	ncmd.Flags().StringVar(&target, "file", "", "price database file to append to")
+	ncmd.MarkFlagFilename("file")
+	ncmd.RunE = func(cmd *cobra.Command, args []string) error {
+		if target == "" {
+			if len(app.Prices) == 0 || strings.ContainsAny(app.Prices[0], "*?[") {
+				return fmt.Errorf("no price database file: use --file or configure prices")
+			}
+			target = app.Prices[0]
+			if !filepath.IsAbs(target) {
+				target = filepath.Join(filepath.Dir(app.Ledger), target)
+			}
+		}
+		return importPrices(app, target, args)
+	}
+	root.AddCommand(ncmd)
+}
+
+func importPrices(rapp *app.App, target string, files []string) error {
+
+	// Existing prices in the price database
+	existing := book.NewBookBuilder()
+	if _, err := os.Stat(target); err == nil {
+		if err := loader.ParsePriceFile(existing, target); err != nil {
+			return err
+		}
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("reading %s: %w", target, err)
+	}
+	current := existing.Build()
+
+	// New prices (same date within the new prices is merged)
+	incoming := book.NewBookBuilder()
+	for _, file := range files {
+		if err := loader.ParsePriceFile(incoming, file); err != nil {
+			return err
+		}
+	}
+	quotes := incoming.Build()
+
+	// Open for appending, ensuring we start on a new line
+	b, err := ioutil.ReadFile(target)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("reading %s: %w", target, err)
+	}
+	fh, err := os.OpenFile(target, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("failed opening %s: %w", target, err)
+	}
+	defer fh.Close()
+	if len(b) > 0 && b[len(b)-1] != '\n' {
+		if _, err := fh.Write([]byte("\n")); err != nil {
+			return fmt.Errorf("failed writing %s: %w", target, err)
+		}
+	}
+
+	// Printer for the price database
+	fapp := *rapp
+	fapp.Output = fh
+	fapp.Colour = false
+	bp := fapp.NewBookPrinter(quotes.GetCCYDecimals())
+
+	added := 0
+	for _, pair := range quotes.GetPricePairs() {
+		dates := make(map[book.Date]bool)
+		for _, p := range current.GetPriceList(pair.Unit, pair.CCY) {
+			dates[p.GetDate()] = true
+		}
+
+		newprices := make(book.PriceList, 0, 10)
+		for _, p := range quotes.GetPriceList(pair.Unit, pair.CCY) {
+			if !dates[p.GetDate()] {
+				newprices = append(newprices, p)
+			}
+		}
+		if len(newprices) == 0 {
+			continue
+		}
+
+		if strings.EqualFold(filepath.Ext(target), ".csv") {
+			err = ShowCSV(bp, pair.Unit, pair.CCY, newprices)
+		} else {
+			err = ShowLedger(bp, pair.Unit, pair.CCY, newprices)
+		}
+		if err != nil {
+			return fmt.Errorf("failed writing %s: %w", target, err)
+		}
+		added += len(newprices)
+	}
+
+	fmt.Fprintf(rapp.Output, "Added %d prices to %s\n", added, target)
+
+	return nil
+}
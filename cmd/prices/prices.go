@@ -0,0 +1,263 @@
+package prices
+
+import (
+	"fmt"
+	"github.com/mescanne/goledger/book"
+	"github.com/mescanne/goledger/cmd/app"
+	"github.com/mescanne/goledger/cmd/export"
+	"github.com/mescanne/goledger/cmd/utils"
+	"github.com/spf13/cobra"
+	"math/big"
+	"regexp"
+)
+
+const prices_long = `Inspect, validate and export the price book
+
+Lists every price pair (unit and ccy) with the first and last date
+of the prices, the number of prices, the largest gap in days between
+two consecutive prices, and the number of days since the last price
+(staleness).
+
+Outliers are prices that change by more than the threshold from the
+last preceding price that is not an outlier (0.2 is 20%). If the inverse
+of the price is within the threshold of the preceding price it is flagged
+as inverted -- typically a quote entered the wrong way around.
+`
+
+// Largest number of decimals for exact formatting of prices
+const maxPriceDecimals = 10
+
+func Add(root *cobra.Command, app *app.App) {
+	ncmd := &cobra.Command{
+		Use:               "prices [regex]",
+		Short:             "Show price pairs with statistics",
+		Long:              prices_long,
+		DisableAutoGenTag: true,
+	}
+	ncmd.Args = cobra.MaximumNArgs(1)
+
+	var useJson bool
+	var threshold float64
+	ncmd.Flags().BoolVar(&useJson, "json", false, "Show price pairs using json")
+	ncmd.PersistentFlags().Float64Var(&threshold, "threshold", 0.2, "relative change between prices to flag as an outlier")
+	ncmd.RunE = func(cmd *cobra.Command, args []string) error {
+		b, err := app.LoadBook()
+		if err != nil {
+			return err
+		}
+		pairs, err := matchingPairs(b, args)
+		if err != nil {
+			return err
+		}
+		return showPairs(app.NewBookPrinter(b.GetCCYDecimals()), b, pairs, threshold, useJson)
+	}
+
+	outCmd := &cobra.Command{
+		Use:               "outliers [regex]",
+		Short:             "Show suspicious prices",
+		Long:              prices_long,
+		DisableAutoGenTag: true,
+	}
+	outCmd.Args = cobra.MaximumNArgs(1)
+	outCmd.RunE = func(cmd *cobra.Command, args []string) error {
+		b, err := app.LoadBook()
+		if err != nil {
+			return err
+		}
+		pairs, err := matchingPairs(b, args)
+		if err != nil {
+			return err
+		}
+		return showOutliers(app.NewBookPrinter(b.GetCCYDecimals()), b, pairs, threshold)
+	}
+	ncmd.AddCommand(outCmd)
+
+	var exportType string = "Ledger"
+	exportCmd := &cobra.Command{
+		Use:               "export <unit> <ccy>",
+		Short:             "Export price history for a pair",
+		Long:              "Export price history for a pair as ledger P lines, CSV (date, unit, ccy, price) or JSON",
+		DisableAutoGenTag: true,
+	}
+	exportCmd.Args = cobra.ExactArgs(2)
+	priceType := utils.NewEnum(&exportType, []string{"Ledger", "CSV", "Json"}, "exportType")
+	exportCmd.Flags().Var(priceType, "type", fmt.Sprintf("export type (%s)", priceType.Values()))
+	exportCmd.RunE = func(cmd *cobra.Command, args []string) error {
+		b, err := app.LoadBook()
+		if err != nil {
+			return err
+		}
+		pl := b.GetPriceList(args[0], args[1])
+		if len(pl) == 0 {
+			return fmt.Errorf("no prices for unit '%s' in ccy '%s'", args[0], args[1])
+		}
+		bp := app.NewBookPrinter(b.GetCCYDecimals())
+		if exportType == "Json" {
+			return bp.PrintJSON(pl, true)
+		} else if exportType == "CSV" {
+			return ShowCSV(bp, args[0], args[1], pl)
+		} else {
+			return ShowLedger(bp, args[0], args[1], pl)
+		}
+	}
+	ncmd.AddCommand(exportCmd)
+
+	addImport(ncmd, app)
+
+	root.AddCommand(ncmd)
+}
+
+// Find the price pairs where either unit or ccy matches the (optional) regex
+func matchingPairs(b *book.Book, args []string) ([]book.PricePair, error) {
+	regex := "^.*$"
+	if len(args) == 1 {
+		regex = args[0]
+	}
+	re, err := regexp.Compile(regex)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regex: '%s': %w", regex, err)
+	}
+
+	pairs := make([]book.PricePair, 0, 10)
+	for _, pair := range b.GetPricePairs() {
+		if re.MatchString(pair.Unit) || re.MatchString(pair.CCY) {
+			pairs = append(pairs, pair)
+		}
+	}
+	return pairs, nil
+}
+
+func showPairs(bp *app.BookPrinter, b *book.Book, pairs []book.PricePair, threshold float64, useJson bool) error {
+	today := book.GetToday()
+
+	if useJson {
+		type JsonPair struct {
+			Unit     string    `json:"unit"`
+			CCY      string    `json:"ccy"`
+			First    book.Date `json:"first"`
+			Last     book.Date `json:"last"`
+			Count    int       `json:"count"`
+			MaxGap   int       `json:"maxGap"`
+			Stale    int       `json:"stale"`
+			Outliers int       `json:"outliers"`
+		}
+		jpairs := make([]JsonPair, 0, len(pairs))
+		for _, pair := range pairs {
+			pl := b.GetPriceList(pair.Unit, pair.CCY)
+			stats := pl.GetStats()
+			jpairs = append(jpairs, JsonPair{
+				Unit:     pair.Unit,
+				CCY:      pair.CCY,
+				First:    stats.First,
+				Last:     stats.Last,
+				Count:    stats.Count,
+				MaxGap:   stats.MaxGap,
+				Stale:    today.DaysSince(stats.Last),
+				Outliers: len(pl.FindOutliers(threshold)),
+			})
+		}
+		return bp.PrintJSON(jpairs, true)
+	}
+
+	rows := make([][]app.ColumnValue, 0, len(pairs)+1)
+	rows = append(rows, []app.ColumnValue{
+		app.ColumnString(bp.Ansi(app.UL, "Unit")),
+		app.ColumnString(bp.Ansi(app.UL, "CCY")),
+		app.ColumnString(bp.Ansi(app.UL, "First")),
+		app.ColumnString(bp.Ansi(app.UL, "Last")),
+		app.ColumnRightString(bp.Ansi(app.UL, "Count")),
+		app.ColumnRightString(bp.Ansi(app.UL, "Max Gap")),
+		app.ColumnRightString(bp.Ansi(app.UL, "Stale")),
+		app.ColumnRightString(bp.Ansi(app.UL, "Outliers")),
+	})
+	for _, pair := range pairs {
+		pl := b.GetPriceList(pair.Unit, pair.CCY)
+		stats := pl.GetStats()
+		outliers := fmt.Sprintf("%d", len(pl.FindOutliers(threshold)))
+		if outliers != "0" {
+			outliers = bp.Ansi(app.Red, outliers)
+		}
+		rows = append(rows, []app.ColumnValue{
+			app.ColumnString(pair.Unit),
+			app.ColumnString(pair.CCY),
+			app.ColumnString(stats.First.String()),
+			app.ColumnString(stats.Last.String()),
+			app.ColumnRightString(fmt.Sprintf("%d", stats.Count)),
+			app.ColumnRightString(fmt.Sprintf("%d", stats.MaxGap)),
+			app.ColumnRightString(fmt.Sprintf("%d", today.DaysSince(stats.Last))),
+			app.ColumnRightString(outliers),
+		})
+	}
+	bp.PrintColumns(rows, []bool{false, false, false, false, false, false, false, false})
+
+	return nil
+}
+
+func showOutliers(bp *app.BookPrinter, b *book.Book, pairs []book.PricePair, threshold float64) error {
+	rows := make([][]app.ColumnValue, 0, 10)
+	rows = append(rows, []app.ColumnValue{
+		app.ColumnString(bp.Ansi(app.UL, "Unit")),
+		app.ColumnString(bp.Ansi(app.UL, "CCY")),
+		app.ColumnString(bp.Ansi(app.UL, "Date")),
+		app.ColumnRightString(bp.Ansi(app.UL, "Previous")),
+		app.ColumnRightString(bp.Ansi(app.UL, "Price")),
+		app.ColumnRightString(bp.Ansi(app.UL, "Change")),
+		app.ColumnString(bp.Ansi(app.UL, "Note")),
+	})
+	for _, pair := range pairs {
+		for _, o := range b.GetPriceList(pair.Unit, pair.CCY).FindOutliers(threshold) {
+			note := ""
+			if o.Inverted {
+				note = bp.Ansi(app.Red, "inverted?")
+			}
+			rows = append(rows, []app.ColumnValue{
+				app.ColumnString(pair.Unit),
+				app.ColumnString(pair.CCY),
+				app.ColumnString(o.Date.String()),
+				app.ColumnRightString(FormatPrice(o.Prev)),
+				app.ColumnRightString(FormatPrice(o.Price)),
+				app.ColumnRightString(bp.Sprintf("%.1f%%", o.Change*100)),
+				app.ColumnString(note),
+			})
+		}
+	}
+	bp.PrintColumns(rows, []bool{false, false, false, false, false, false, false})
+
+	return nil
+}
+
+// Format a price exactly (no locale, no rounding) where it is a finite
+// decimal, otherwise to the maximum number of decimals.
+func FormatPrice(price *big.Rat) string {
+	if price.IsInt() {
+		return price.FloatString(0)
+	}
+	p := big.NewInt(1)
+	ten := big.NewInt(10)
+	var m big.Int
+	for dec := 1; dec <= maxPriceDecimals; dec++ {
+		p.Mul(p, ten)
+		if m.Mod(p, price.Denom()).Sign() == 0 {
+			return price.FloatString(dec)
+		}
+	}
+	return price.FloatString(maxPriceDecimals)
+}
+
+// Show the prices as ledger P lines
+func ShowLedger(b *app.BookPrinter, unit string, ccy string, pl book.PriceList) error {
+	for _, p := range pl {
+		b.Printf("P %s 00:00:00 %s %s%s\n", p.GetDate(), export.LedgerFormatCurrency(unit),
+			b.FormatSymbol(export.LedgerFormatCurrency(ccy)), FormatPrice(p.GetPrice()))
+	}
+	return nil
+}
+
+// Show the prices as CSV (date, unit, ccy, price)
+func ShowCSV(b *app.BookPrinter, unit string, ccy string, pl book.PriceList) error {
+	rows := make([][]string, 0, len(pl))
+	for _, p := range pl {
+		rows = append(rows, []string{p.GetDate().String(), unit, ccy, FormatPrice(p.GetPrice())})
+	}
+	return b.PrintCSV(rows)
+}
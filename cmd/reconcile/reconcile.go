@@ -0,0 +1,195 @@
+package reconcile
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/mescanne/goledger/book"
+	"github.com/mescanne/goledger/cmd/app"
+	"github.com/mescanne/goledger/loader"
+	"github.com/spf13/cobra"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+)
+
+const reconcile_long = `Reconcile an account against a statement
+
+Calculates the cleared balance of the account as of the statement date
+(inclusive) and the difference to the statement balance. Postings are
+cleared if they (or their transaction) are marked with '*' in the ledger.
+
+The uncleared postings are listed, and the smallest set of the most
+recent uncleared postings (see --window) that adds up to the difference
+is found. The search is limited, so with a large window it may stop
+without finding a match. These are the postings that have likely cleared since the last
+reconciliation.
+
+The statement balance is in the same sign as the ledger: for liability
+accounts such as credit cards it is normally negative.
+
+With --mark the matched postings are marked as cleared ('*') in the
+source ledger files after confirmation.
+`
+
+type reconcileCmd struct {
+	Date    string
+	Balance string
+	CCY     string
+	Window  int
+	Mark    bool
+	Yes     bool
+}
+
+func Add(root *cobra.Command, app *app.App) {
+	rec := &reconcileCmd{
+		Window: 20,
+	}
+	ncmd := &cobra.Command{
+		Use:               "reconcile <acct>",
+		Short:             "Reconcile an account against a statement",
+		Long:              reconcile_long,
+		DisableAutoGenTag: true,
+	}
+	ncmd.Args = cobra.ExactArgs(1)
+	ncmd.Flags().StringVar(&rec.Date, "statement-date", rec.Date, "statement date (inclusive)")
+	ncmd.Flags().StringVar(&rec.Balance, "statement-balance", rec.Balance, "statement balance")
+	ncmd.Flags().StringVar(&rec.CCY, "statement-ccy", rec.CCY, "statement currency (default base currency)")
+	ncmd.Flags().IntVar(&rec.Window, "window", rec.Window, fmt.Sprintf("number of most recent uncleared postings to search for a match (1 to %d)", book.MaxReconcileWindow))
+	ncmd.Flags().BoolVar(&rec.Mark, "mark", rec.Mark, "mark matched postings as cleared in the ledger files")
+	ncmd.Flags().BoolVar(&rec.Yes, "yes", rec.Yes, "mark without asking for confirmation")
+	cobra.MarkFlagRequired(ncmd.Flags(), "statement-date")
+	cobra.MarkFlagRequired(ncmd.Flags(), "statement-balance")
+	ncmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return rec.run(app, cmd, args)
+	}
+	root.AddCommand(ncmd)
+}
+
+func (rec *reconcileCmd) run(rapp *app.App, cmd *cobra.Command, args []string) error {
+	date, err := rapp.ParseDate(rec.Date)
+	if err != nil {
+		return fmt.Errorf("statement date: %w", err)
+	}
+	balance, ok := big.NewRat(0, 1).SetString(strings.ReplaceAll(rec.Balance, ",", ""))
+	if !ok {
+		return fmt.Errorf("invalid statement balance '%s'", rec.Balance)
+	}
+	ccy := rec.CCY
+	if ccy == "" {
+		ccy = rapp.BaseCCY
+	}
+	if ccy == "" {
+		return fmt.Errorf("no statement currency -- no CCY specified")
+	}
+
+	// Match the postings on their own lines of the ledger
+	b, err := rapp.LoadSourceBook()
+	if err != nil {
+		return err
+	}
+
+	r, err := b.Reconcile(args[0], ccy, date, balance, rec.Window)
+	if err != nil {
+		return err
+	}
+
+	bp := rapp.NewBookPrinter(b.GetCCYDecimals())
+	showReconciliation(bp, r)
+
+	if !rec.Mark || len(r.Match) == 0 {
+		return nil
+	}
+
+	if !rec.Yes {
+		bp.Printf("\nMark %d postings as cleared? [y/N] ", len(r.Match))
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		if !strings.EqualFold(strings.TrimSpace(answer), "y") {
+			return nil
+		}
+	}
+
+	return markCleared(r.Match)
+}
+
+func showReconciliation(bp *app.BookPrinter, r *book.Reconciliation) {
+	bp.Printf("%s (%s) as of %s\n\n", bp.Ansi(app.BlueUL, r.Account), r.CCY, r.Date)
+	bp.PrintColumns([][]app.ColumnValue{
+		{app.ColumnString("Cleared balance"), bp.GetColumnMoney(r.CCY, r.Cleared)},
+		{app.ColumnString("Statement balance"), bp.GetColumnMoney(r.CCY, r.Statement)},
+		{app.ColumnString("Difference"), bp.GetColumnMoney(r.CCY, r.Difference)},
+	}, []bool{false, false})
+
+	if r.Difference.Sign() == 0 {
+		bp.Printf("\nReconciled.\n")
+		return
+	}
+
+	matched := make(map[string]bool)
+	for _, p := range r.Match {
+		matched[postingKey(p)] = true
+	}
+
+	bp.Printf("\nUncleared postings (* matched):\n\n")
+	rows := make([][]app.ColumnValue, 0, len(r.Uncleared)+1)
+	rows = append(rows, []app.ColumnValue{
+		app.ColumnString(bp.Ansi(app.UL, " ")),
+		app.ColumnString(bp.Ansi(app.UL, "Date")),
+		app.ColumnString(bp.Ansi(app.UL, "Payee")),
+		app.ColumnRightString(bp.Ansi(app.UL, "Amount")),
+		app.ColumnString(bp.Ansi(app.UL, "Source")),
+	})
+	for _, p := range r.Uncleared {
+		mark := " "
+		if matched[postingKey(p)] {
+			mark = "*"
+		}
+		file, line := p.GetSource()
+		rows = append(rows, []app.ColumnValue{
+			app.ColumnString(mark),
+			app.ColumnString(p.GetDate().String()),
+			app.ColumnString(p.GetPayee()),
+			bp.GetColumnMoney(p.GetCCY(), p.GetAmount()),
+			app.ColumnString(fmt.Sprintf("%s:%d", file, line)),
+		})
+	}
+	bp.PrintColumns(rows, []bool{false, true, true, false, true})
+
+	if r.Exhausted {
+		bp.Printf("\nNo match found: stopped searching after %d sets of postings (try a smaller --window).\n", book.MaxReconcileSearch)
+	} else if r.Match == nil {
+		bp.Printf("\nNo set of the most recent uncleared postings adds up to the difference.\n")
+	} else {
+		bp.Printf("\n%d postings add up to the difference.\n", len(r.Match))
+	}
+}
+
+func postingKey(p book.Posting) string {
+	file, line := p.GetSource()
+	return fmt.Sprintf("%s:%d:%d:%s", file, line, p.GetDate(), p.GetPayee())
+}
+
+// Mark the postings as cleared in their source files
+func markCleared(posts []book.Posting) error {
+	lines := make(map[string][]int)
+	for _, p := range posts {
+		file, line := p.GetSource()
+		if file == "" {
+			return fmt.Errorf("posting %s %s has no source file", p.GetDate(), p.GetPayee())
+		}
+		lines[file] = append(lines[file], line)
+	}
+
+	files := make([]string, 0, len(lines))
+	for file := range lines {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+
+	for _, file := range files {
+		if err := loader.MarkCleared(file, lines[file]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package register
+
+import (
+	"fmt"
+	"github.com/mescanne/goledger/book"
+	"github.com/mescanne/goledger/cmd/app"
+	"github.com/mescanne/goledger/cmd/utils"
+	"html"
+	"math/big"
+	"strings"
+)
+
+// Show the registers as a HTML document, with a table for each titled
+// register (no title if empty). Converted amounts and balances are shown if
+// there is a base currency, with the source of the price for conversions.
+func ShowHTML(b *app.BookPrinter, titles []string, reports []book.RegistryReport, withAcct bool, HTMLCSS string) error {
+
+	HTMLCSS, err := utils.GetCSS(HTMLCSS, registerStyleSheet)
+	if err != nil {
+		return err
+	}
+
+	b.Printf("<html><head><style>\n%s\n</style></head><body>\n", HTMLCSS)
+
+	for i, report := range reports {
+		if titles[i] != "" {
+			b.Printf("<h3 class=\"title\">%s</h3>\n", html.EscapeString(titles[i]))
+		}
+
+		baseCCY := ""
+		if len(report) > 0 {
+			baseCCY = report[0].BaseCCY
+		}
+
+		b.Printf("<table class=\"register\">\n")
+		b.Printf("  <thead><tr><th>Date</th><th>Payee</th>")
+		if withAcct {
+			b.Printf("<th>Account</th>")
+		}
+		b.Printf("<th>Counteraccount</th><th class=\"amount\">Amount</th><th class=\"amount\">Balance</th>")
+		if baseCCY != "" {
+			ccy := html.EscapeString(baseCCY)
+			b.Printf("<th class=\"amount\">Amount (%s)</th><th class=\"amount\">Balance (%s)</th><th>Price</th>", ccy, ccy)
+		}
+		b.Printf("</tr></thead>\n")
+
+		b.Printf("  <tbody>\n")
+		for _, r := range report {
+			b.Printf("    <tr><td class=\"date\">%s</td><td>%s</td>", r.Date, html.EscapeString(r.Payee))
+			if withAcct {
+				b.Printf("<td>%s</td>", html.EscapeString(r.Account))
+			}
+			cacct := r.CounterAccount
+			if r.Count > 0 {
+				cacct = fmt.Sprintf("%d postings", r.Count)
+			}
+			b.Printf("<td>%s</td>", html.EscapeString(cacct))
+			b.Printf("%s%s", htmlAmount(b, r.CCY, r.Amount), htmlAmount(b, r.CCY, r.Balance))
+			if baseCCY != "" {
+				b.Printf("%s%s", htmlAmount(b, baseCCY, r.BaseAmount), htmlAmount(b, baseCCY, r.BaseBalance))
+				if r.CCY != baseCCY {
+					b.Printf("<td class=\"source %s\">%s</td>", strings.ToLower(r.BaseSource.String()), r.BaseSource)
+				} else {
+					b.Printf("<td></td>")
+				}
+			}
+			b.Printf("</tr>\n")
+		}
+		b.Printf("  </tbody>\n")
+		b.Printf("</table>\n")
+	}
+
+	b.Printf("</body></html>\n")
+
+	return nil
+}
+
+func htmlAmount(b *app.BookPrinter, ccy string, amt *big.Rat) string {
+	sign := "neg"
+	if amt.Sign() >= 0 {
+		sign = "pos"
+	}
+	return fmt.Sprintf("<td class=\"amount %s\">%s%s</td>", sign,
+		html.EscapeString(b.FormatSymbol(ccy)), b.FormatNumber(ccy, amt))
+}
+
+const registerStyleSheet = `
+.title {
+	font-family: sans-serif;
+	color: #00009f;
+}
+.register {
+	font-family: sans-serif;
+	border-collapse: collapse;
+	color: #00009f;
+	margin-bottom: 20px;
+}
+.register th {
+	text-align: left;
+	border-bottom: 1px solid black;
+	padding: 4px 8px;
+}
+.register td {
+	padding: 2px 8px;
+}
+.register tbody tr:nth-child(even) {
+	background: #e4f2f7;
+}
+.register .date {
+	white-space: nowrap;
+}
+.register .amount {
+	text-align: right;
+	white-space: nowrap;
+}
+.register .neg {
+	color: #9f0000;
+}
+.register .source {
+	font-size: smaller;
+}
+.register .source.inferred {
+	color: #9f6f00;
+}
+.register .source.outofrange, .register .source.none {
+	color: #9f0000;
+	font-weight: bold;
+}
+`
@@ -0,0 +1,39 @@
+package register
+
+import (
+	"github.com/mescanne/goledger/book"
+	"github.com/mescanne/goledger/cmd/app"
+	"github.com/mescanne/goledger/cmd/reports"
+	"regexp"
+	"strings"
+)
+
+// Show the transactions with postings to accounts matching re as a ledger,
+// restricted to the dates and aggregated by period
+func (reg *RegisterReport) showLedger(b *app.BookPrinter, bk *book.Book, re *regexp.Regexp, d registerDates) error {
+	bk.FilterTransaction(func(date book.Date, payee string, posts book.Transaction) bool {
+		if (d.min != 0 && date < d.min) || (d.max != 0 && date >= d.max) {
+			return false
+		}
+		for _, p := range posts {
+			if re.MatchString(p.GetAccount()) {
+				return true
+			}
+		}
+		return false
+	})
+	if d.by != "none" {
+		bk.SplitBy(d.by)
+	}
+
+	return reports.ShowLedger(b, bk.Transactions())
+}
+
+// Regex matching exactly the accounts
+func quoteAccounts(accts []string) string {
+	quoted := make([]string, len(accts))
+	for i, acct := range accts {
+		quoted[i] = regexp.QuoteMeta(acct)
+	}
+	return strings.Join(quoted, "|")
+}
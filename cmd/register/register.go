@@ -26,12 +26,29 @@ type RegisterReport struct {
 	Macros    []string
 	Accounts  []string
 	Split     bool
+	Related   bool
+	Period    string
+	HTMLCSS   string
 }
 
+var registerPeriods = []string{"none", "weekly", "monthly", "quarterly", "halfyearly", "yearly", "fiscal"}
+
 const register_long = `Register account postings
 
 Show a registry of postings individual accounts. This is useful for reconciliation
 between accounts and for investigating postings.
+
+With --related the counter-postings are shown instead of the account postings,
+for example the expenses paid from an account, with running balances of them.
+
+With --period the register is aggregated into one entry for each period (and
+currency), with the total of the period and the closing balance.
+
+HTML output has the balances, amounts converted to the base currency and the
+source of the prices for the conversions. Ledger output is the transactions
+with postings to the accounts (in date order, regardless of --count and --asc),
+for example to start a separate ledger for them; they include the
+counter-postings, so --related is not supported.
 `
 
 func Add(cmd *cobra.Command, app *app.App, reg *RegisterReport) {
@@ -46,6 +63,9 @@ func Add(cmd *cobra.Command, app *app.App, reg *RegisterReport) {
 	}
 
 	// Set defaults
+	if reg.Period == "" {
+		reg.Period = "none"
+	}
 	reportType := utils.NewEnum(&reg.Type, reportTypes, "reportType")
 	ncmd.Flags().Var(reportType, "type", fmt.Sprintf("report type (%s)", reportType.Values()))
 	ncmd.Flags().BoolVar(&reg.Combined, "combined", reg.Combined, "combined report (all accounts combined)")
@@ -56,6 +76,10 @@ func Add(cmd *cobra.Command, app *app.App, reg *RegisterReport) {
 	ncmd.Flags().BoolVar(&reg.ZeroStart, "zero", reg.ZeroStart, "start balance at zero")
 	ncmd.Flags().BoolVar(&reg.Split, "split", reg.Split, "split multiple counteraccounts into separate postings")
 	ncmd.Flags().BoolVar(&reg.Convert, "convert", reg.Convert, "convert postings to base currency")
+	ncmd.Flags().BoolVar(&reg.Related, "related", reg.Related, "show counter-postings instead of account postings")
+	periodType := utils.NewEnum(&reg.Period, registerPeriods, "period")
+	ncmd.Flags().Var(periodType, "period", fmt.Sprintf("aggregate postings by period (values %s)", periodType.Values()))
+	ncmd.Flags().StringVar(&reg.HTMLCSS, "htmlcss", reg.HTMLCSS, "HTML CSS (string or file:<css file>) for HTML output (inlined in HTML)")
 	ncmd.RunE = func(cmd *cobra.Command, args []string) error {
 		return reg.run(app, cmd, args)
 	}
@@ -65,6 +89,11 @@ func Add(cmd *cobra.Command, app *app.App, reg *RegisterReport) {
 
 func (reg *RegisterReport) run(rapp *app.App, cmd *cobra.Command, args []string) error {
 
+	// Ledger output is whole transactions, which already include the counter-postings
+	if reg.Type == "Ledger" && reg.Related {
+		return fmt.Errorf("--related is not supported with ledger output")
+	}
+
 	// Load up saved flags
 	b, err := rapp.LoadBook()
 	if err != nil {
@@ -88,6 +117,11 @@ func (reg *RegisterReport) run(rapp *app.App, cmd *cobra.Command, args []string)
 		}
 	}
 
+	d, err := reg.dates(rapp)
+	if err != nil {
+		return err
+	}
+
 	// Create printer
 	bp := rapp.NewBookPrinter(b.GetCCYDecimals())
 
@@ -99,24 +133,83 @@ func (reg *RegisterReport) run(rapp *app.App, cmd *cobra.Command, args []string)
 		if err != nil {
 			return fmt.Errorf("invalid regex: '%s': %w", arg, err)
 		}
-		rep = b.ExtractRegister(rapp.BaseCCY, re, reg.Split)
-		rep.FilterByDate(reg.BeginDate, reg.EndDate)
+		if reg.Type == "Ledger" {
+			return reg.showLedger(bp, b, re, d)
+		}
+		rep = reg.extract(b, rapp.BaseCCY, re, d)
+		if reg.Type == "HTML" {
+			return ShowHTML(bp, []string{""}, []book.RegistryReport{limitReport(rep, reg.Count, reg.Asc)}, true, reg.HTMLCSS)
+		}
 		return ShowReport(bp, rep, reg.Type, reg.Count, reg.Asc, true, true)
 	}
 
-	for _, acct := range b.Accounts(arg, !rapp.All) {
-		bp.Printf("\n%s\n", bp.Ansi(app.BlueUL, acct))
+	accts := b.Accounts(arg, !rapp.All)
+	if reg.Type == "Ledger" {
+		re, err := regexp.Compile(fmt.Sprintf("^(%s)$", quoteAccounts(accts)))
+		if err != nil {
+			return fmt.Errorf("failed compiling re for accounts: %w", err)
+		}
+		return reg.showLedger(bp, b, re, d)
+	}
+
+	// HTML is a single document with a register for each account
+	reps := make([]book.RegistryReport, 0, len(accts))
+	withAcct := reg.Related && reg.Period == "none"
+
+	for _, acct := range accts {
 		acctRe, err := regexp.Compile(fmt.Sprintf("^%s$", acct))
 		if err != nil {
 			return fmt.Errorf("failed compiling re for account '%s': %w", acct, err)
 		}
-		rep = b.ExtractRegister(rapp.BaseCCY, acctRe, reg.Split)
-		rep.FilterByDate(reg.BeginDate, reg.EndDate)
-		if err := ShowReport(bp, rep, reg.Type, reg.Count, reg.Asc, false, true); err != nil {
+		rep = reg.extract(b, rapp.BaseCCY, acctRe, d)
+		if reg.Type == "HTML" {
+			reps = append(reps, limitReport(rep, reg.Count, reg.Asc))
+			continue
+		}
+		bp.Printf("\n%s\n", bp.Ansi(app.BlueUL, acct))
+		if err := ShowReport(bp, rep, reg.Type, reg.Count, reg.Asc, withAcct, true); err != nil {
 			return fmt.Errorf("error writing report '%s': %w", acct, err)
 		}
 	}
 
+	if reg.Type == "HTML" {
+		return ShowHTML(bp, accts, reps, withAcct, reg.HTMLCSS)
+	}
+
 	return nil
 
 }
+
+// Dates and period of the register, with the configured fiscal year
+type registerDates struct {
+	min, max book.Date // 0 for no limit
+	by       string
+}
+
+func (reg *RegisterReport) dates(rapp *app.App) (registerDates, error) {
+	var d registerDates
+	var err error
+	if reg.BeginDate != "" {
+		if d.min, err = rapp.ParseDate(reg.BeginDate); err != nil {
+			return d, fmt.Errorf("begin date: %w", err)
+		}
+	}
+	if reg.EndDate != "" {
+		if d.max, err = rapp.ParseDate(reg.EndDate); err != nil {
+			return d, fmt.Errorf("asof date: %w", err)
+		}
+	}
+	d.by, err = rapp.Period(reg.Period)
+	return d, err
+}
+
+// Extract the register for the accounts, restricted to the dates and
+// aggregated by period
+func (reg *RegisterReport) extract(b *book.Book, baseccy string, re *regexp.Regexp, d registerDates) book.RegistryReport {
+	rep := b.ExtractRegister(baseccy, re, reg.Split, reg.Related)
+	rep.FilterByDates(d.min, d.max)
+	if d.by != "none" {
+		rep = rep.ByPeriod(d.by)
+	}
+	return rep
+}
@@ -11,10 +11,29 @@ var reportTypes = []string{
 	"Text",
 	"JSON",
 	"CSV",
+	"HTML",
+	"Ledger",
 }
 
 func ShowReport(b *app.BookPrinter, report book.RegistryReport, format string, count int, asc bool, withAcct bool, withBal bool) error {
 
+	report = limitReport(report, count, asc)
+
+	if format == "Text" {
+		return ShowText(b, report, withAcct, withBal)
+	} else if format == "JSON" {
+		return b.PrintJSON(report, true)
+	} else if format == "CSV" {
+		return ShowCSV(b, report)
+	} else {
+		return fmt.Errorf("invalid report type '%s', expected %s", format, strings.Join(reportTypes, ", "))
+	}
+}
+
+// Restrict the report to the first count entries (or last if negative) and
+// reverse it if not ascending
+func limitReport(report book.RegistryReport, count int, asc bool) book.RegistryReport {
+
 	// Restrict count counting from beginning
 	if count > 0 && len(report) > count {
 		report = (report)[0:count]
@@ -28,21 +47,13 @@ func ShowReport(b *app.BookPrinter, report book.RegistryReport, format string, c
 	// Reverse if requested
 	if !asc {
 		ndata := make([]*book.RegistryEntry, len(report), len(report))
-		for i := 0; i < len(report)/2; i++ {
-			ndata[len(report)-i-1] = (report)[i]
+		for i, r := range report {
+			ndata[len(report)-i-1] = r
 		}
 		report = ndata
 	}
 
-	if format == "Text" {
-		return ShowText(b, report, withAcct, withBal)
-	} else if format == "JSON" {
-		return b.PrintJSON(report, true)
-	} else if format == "CSV" {
-		return ShowCSV(b, report)
-	} else {
-		return fmt.Errorf("invalid report type '%s', expected %s", format, strings.Join(reportTypes, ", "))
-	}
+	return report
 }
 
 func ShowText(b *app.BookPrinter, report book.RegistryReport, withAcct bool, withBal bool) error {
@@ -94,7 +105,11 @@ func ShowText(b *app.BookPrinter, report book.RegistryReport, withAcct bool, wit
 		if withAcct {
 			row = append(row, app.ColumnString(p.Account))
 		}
-		row = append(row, app.ColumnString(p.CounterAccount))
+		if p.Count > 0 {
+			row = append(row, app.ColumnString(fmt.Sprintf("%d postings", p.Count)))
+		} else {
+			row = append(row, app.ColumnString(p.CounterAccount))
+		}
 		row = append(row, b.GetColumnMoney(p.CCY, p.Amount))
 		if withBal {
 			row = append(row, b.GetColumnMoney(p.CCY, p.Balance))
@@ -0,0 +1,247 @@
+package reports
+
+import (
+	"fmt"
+	"github.com/mescanne/goledger/book"
+	"github.com/mescanne/goledger/cmd/app"
+	"github.com/mescanne/goledger/cmd/utils"
+	"github.com/spf13/cobra"
+	"math/big"
+	"regexp"
+	"sort"
+)
+
+// Configuration for the cash-flow statement
+type CashFlowReport struct {
+	Cash      string // Cash accounts regex
+	Investing string // Investing accounts regex
+	Financing string // Financing accounts regex
+	Splitby   string
+	Begin     string
+	Convert   bool
+	Type      string
+}
+
+// Default configuration if none specified
+var DefaultCashFlow CashFlowReport = CashFlowReport{
+	Cash:      "^Asset:(Bank|Cash)(:.*)?$",
+	Investing: "^Asset:(Investment|Property|Vehicle)(:.*)?$",
+	Financing: "^(Liability:(Loan|Mortgage)|Equity)(:.*)?$",
+	Splitby:   "yearly",
+	Type:      "Text",
+}
+
+const cashflow_long = `Cash-flow statement
+
+Shows the opening cash, the cash flows from operating, investing and
+financing activity, and the closing cash for each period.
+
+The change in the cash accounts of each transaction is allocated to the
+other accounts of the transaction (eg Income:Salary or Asset:Investment),
+which are classified as investing or financing by their regexes, or as
+operating otherwise. Transfers between cash accounts are not flows.
+
+The flows reconcile exactly to the change in cash. Use asof= to end the
+statement, and --begin (rather than since=) to show periods from a date,
+so the opening cash includes all earlier postings.
+`
+
+func AddCashFlow(cmd *cobra.Command, app *app.App, cfg *CashFlowReport) {
+	if cfg.Cash == "" {
+		cfg.Cash = DefaultCashFlow.Cash
+	}
+	if cfg.Investing == "" {
+		cfg.Investing = DefaultCashFlow.Investing
+	}
+	if cfg.Financing == "" {
+		cfg.Financing = DefaultCashFlow.Financing
+	}
+	if cfg.Splitby == "" {
+		cfg.Splitby = DefaultCashFlow.Splitby
+	}
+	if cfg.Type == "" {
+		cfg.Type = DefaultCashFlow.Type
+	}
+
+	ncmd := &cobra.Command{
+		Use:               "cashflow [macros|ops...]",
+		Short:             "Cash-flow statement",
+		Long:              cashflow_long,
+		DisableAutoGenTag: true,
+	}
+	cmd.AddCommand(ncmd)
+
+	floorType := utils.NewEnum(&cfg.Splitby, book.FloorTypes, "floorType")
+	ncmd.Flags().Var(floorType, "splitby", fmt.Sprintf("period of statements (values %s)", floorType.Values()))
+	reportType := utils.NewEnum(&cfg.Type, []string{"Text", "Json", "CSV"}, "reportType")
+	ncmd.Flags().Var(reportType, "type", fmt.Sprintf("report type (%s)", reportType.Values()))
+	ncmd.Flags().StringVar(&cfg.Cash, "cash", cfg.Cash, "cash accounts regex")
+	ncmd.Flags().StringVar(&cfg.Investing, "investing", cfg.Investing, "investing accounts regex")
+	ncmd.Flags().StringVar(&cfg.Financing, "financing", cfg.Financing, "financing accounts regex")
+	ncmd.Flags().StringVar(&cfg.Begin, "begin", cfg.Begin, "show periods from begin date")
+	ncmd.Flags().BoolVar(&cfg.Convert, "convert", cfg.Convert, "convert to base currency")
+	ncmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return cfg.run(app, args)
+	}
+}
+
+func (cfg *CashFlowReport) run(app *app.App, args []string) error {
+	res := make([]*regexp.Regexp, 0, 3)
+	for _, r := range []string{cfg.Cash, cfg.Investing, cfg.Financing} {
+		re, err := regexp.Compile(r)
+		if err != nil {
+			return fmt.Errorf("failed compiling accounts '%s': %w", r, err)
+		}
+		res = append(res, re)
+	}
+
+	var begin book.Date
+	if cfg.Begin != "" {
+		var err error
+		if begin, err = app.ParseDate(cfg.Begin); err != nil {
+			return fmt.Errorf("begin date: %w", err)
+		}
+	}
+	by, err := app.Period(cfg.Splitby)
+	if err != nil {
+		return err
+	}
+
+	b, err := app.LoadBook()
+	if err != nil {
+		return err
+	}
+	if err = app.BookOps(b, args...); err != nil {
+		return err
+	}
+
+	if cfg.Convert {
+		if app.BaseCCY == "" {
+			return fmt.Errorf("unable to convert -- no CCY specified")
+		}
+		b.MapAmount(func(date book.Date, iccy string) (*big.Rat, string) {
+			rate, _ := b.GetPrice(date, iccy, app.BaseCCY)
+			return rate, app.BaseCCY
+		})
+	}
+
+	periods := make([]book.CashFlowPeriod, 0)
+	for _, p := range b.CashFlow(res[0], res[1], res[2], by) {
+		if begin == 0 || p.Date >= begin.Floor(by) {
+			periods = append(periods, p)
+		}
+	}
+
+	bp := app.NewBookPrinter(b.GetCCYDecimals())
+	if cfg.Type == "Json" {
+		return bp.PrintJSON(periods, true)
+	} else if cfg.Type == "CSV" {
+		return ShowCSVCashFlow(bp, periods)
+	}
+	return ShowCashFlow(bp, periods, by)
+}
+
+// Show a statement for each currency with a column for each period
+func ShowCashFlow(b *app.BookPrinter, periods []book.CashFlowPeriod, by string) error {
+	if len(periods) == 0 {
+		b.Printf("No cash flows\n")
+		return nil
+	}
+
+	byCCY := make(map[string][]book.CashFlowPeriod)
+	ccys := make([]string, 0, 1)
+	for _, p := range periods {
+		if _, ok := byCCY[p.CCY]; !ok {
+			ccys = append(ccys, p.CCY)
+		}
+		byCCY[p.CCY] = append(byCCY[p.CCY], p)
+	}
+	sort.Strings(ccys)
+
+	for i, ccy := range ccys {
+		if i > 0 {
+			b.Printf("\n")
+		}
+		showCashFlowCCY(b, ccy, byCCY[ccy], by)
+	}
+	return nil
+}
+
+func showCashFlowCCY(b *app.BookPrinter, ccy string, periods []book.CashFlowPeriod, by string) {
+	n := len(periods) + 1
+	fmts := make([]bool, n)
+	fmts[0] = true
+
+	rows := make([][]app.ColumnValue, 0)
+	header := make([]app.ColumnValue, n)
+	header[0] = app.ColumnString(b.Ansi(app.UL, "Cash flow"))
+	for i, p := range periods {
+		header[i+1] = app.ColumnRightString(b.Ansi(app.UL, p.Date.Label(by)))
+	}
+	rows = append(rows, header)
+
+	addRow := func(label string, amount func(p book.CashFlowPeriod) *big.Rat) {
+		row := make([]app.ColumnValue, n)
+		row[0] = app.ColumnString(label)
+		for i, p := range periods {
+			row[i+1] = b.GetColumnMoney(ccy, amount(p))
+		}
+		rows = append(rows, row)
+	}
+
+	addRow(b.Ansi(app.BlueUL, "Opening cash"), func(p book.CashFlowPeriod) *big.Rat { return p.Opening })
+	for _, class := range book.CashFlowClasses {
+
+		// Accounts of the class across all periods
+		accts := make([]string, 0)
+		seen := make(map[string]bool)
+		for _, p := range periods {
+			for _, l := range p.Lines {
+				if l.Class == class && !seen[l.Account] {
+					seen[l.Account] = true
+					accts = append(accts, l.Account)
+				}
+			}
+		}
+		if len(accts) == 0 {
+			continue
+		}
+		sort.Strings(accts)
+
+		rows = append(rows, nil)
+		addRow(b.Ansi(app.BlueUL, class), func(p book.CashFlowPeriod) *big.Rat { return p.ClassTotal(class) })
+		for _, acct := range accts {
+			acct := acct
+			addRow("  "+acct, func(p book.CashFlowPeriod) *big.Rat {
+				for _, l := range p.Lines {
+					if l.Class == class && l.Account == acct {
+						return l.Amount
+					}
+				}
+				return new(big.Rat)
+			})
+		}
+	}
+	rows = append(rows, nil)
+	addRow(b.Ansi(app.BlueUL, "Net change"), func(p book.CashFlowPeriod) *big.Rat { return p.Net() })
+	addRow(b.Ansi(app.BlueUL, "Closing cash"), func(p book.CashFlowPeriod) *big.Rat { return p.Closing })
+
+	b.PrintColumns(rows, fmts)
+}
+
+func ShowCSVCashFlow(b *app.BookPrinter, periods []book.CashFlowPeriod) error {
+	rows := make([][]string, 0)
+	rows = append(rows, []string{"date", "ccy", "class", "account", "amount"})
+	add := func(p book.CashFlowPeriod, class string, acct string, amt *big.Rat) {
+		f, _ := amt.Float64()
+		rows = append(rows, []string{p.Date.String(), p.CCY, class, acct, fmt.Sprintf("%f", f)})
+	}
+	for _, p := range periods {
+		add(p, "Opening", "", p.Opening)
+		for _, l := range p.Lines {
+			add(p, l.Class, l.Account, l.Amount)
+		}
+		add(p, "Closing", "", p.Closing)
+	}
+	return b.PrintCSV(rows)
+}
@@ -0,0 +1,191 @@
+package reports
+
+import (
+	"fmt"
+	"github.com/mescanne/goledger/book"
+	"github.com/mescanne/goledger/cmd/app"
+	"github.com/mescanne/goledger/cmd/utils"
+	"html"
+	"math/big"
+	"strings"
+)
+
+// Period labels and extra columns for the columnar report
+type columnarOptions struct {
+	By      string // Period for labels (see Date.Label)
+	Total   bool
+	Average bool
+	Change  bool
+}
+
+// Headers for the period and extra columns
+func (e columnarOptions) headers(r *book.ColumnarReport) []string {
+	h := make([]string, 0, len(r.Periods)+3)
+	for _, d := range r.Periods {
+		h = append(h, d.Label(e.By))
+	}
+	if e.Total {
+		h = append(h, "Total")
+	}
+	if e.Average {
+		h = append(h, "Average")
+	}
+	if e.Change {
+		h = append(h, "Change")
+	}
+	return h
+}
+
+// Amounts for the period and extra columns (nil if there is no value)
+func (e columnarOptions) amounts(row book.ColumnarRow) []*big.Rat {
+	a := append(make([]*big.Rat, 0, len(row.Amounts)+3), row.Amounts...)
+	if e.Total {
+		a = append(a, row.Total())
+	}
+	if e.Average {
+		a = append(a, row.Average())
+	}
+	if e.Change {
+		a = append(a, row.Change())
+	}
+	return a
+}
+
+func ShowColumnarTransactions(b *app.BookPrinter, r *book.ColumnarReport, e columnarOptions) error {
+	headers := e.headers(r)
+
+	// Only the account column shrinks to fit the terminal
+	fmts := make([]bool, len(headers)+1)
+	fmts[0] = true
+
+	header := make([]app.ColumnValue, len(headers)+1)
+	header[0] = app.ColumnString(b.Ansi(app.UL, "Account"))
+	for i, h := range headers {
+		header[i+1] = app.ColumnRightString(b.Ansi(app.UL, h))
+	}
+
+	rows := make([][]app.ColumnValue, 0, len(r.Rows)+10)
+	rows = append(rows, header)
+	for i, row := range r.Rows {
+		var t string
+		if row.Level == 0 {
+			t = b.Ansi(app.BlueUL, row.Term)
+		} else {
+			t = strings.Repeat("  ", row.Level) + row.Term
+		}
+
+		// Insert newline between top-level accounts
+		if i > 0 && row.Level == 0 {
+			rows = append(rows, nil)
+		}
+
+		cols := make([]app.ColumnValue, 0, len(headers)+1)
+		cols = append(cols, app.ColumnString(t))
+		for _, amt := range e.amounts(row) {
+			if amt == nil {
+				cols = append(cols, app.ColumnString(""))
+			} else {
+				cols = append(cols, b.GetColumnMoney(row.CCY, amt))
+			}
+		}
+		rows = append(rows, cols)
+	}
+
+	b.PrintColumns(rows, fmts)
+
+	return nil
+}
+
+func ShowCSVColumnarTransactions(b *app.BookPrinter, r *book.ColumnarReport, e columnarOptions) error {
+	rows := make([][]string, 0, len(r.Rows)+1)
+	rows = append(rows, append([]string{"account", "level", "ccy"}, e.headers(r)...))
+	for _, row := range r.Rows {
+		cols := []string{row.Account, fmt.Sprintf("%d", row.Level), row.CCY}
+		for _, amt := range e.amounts(row) {
+			if amt == nil {
+				cols = append(cols, "")
+			} else {
+				f, _ := amt.Float64()
+				cols = append(cols, fmt.Sprintf("%f", f))
+			}
+		}
+		rows = append(rows, cols)
+	}
+	return b.PrintCSV(rows)
+}
+
+func ShowHTMLColumnarTransactions(b *app.BookPrinter, r *book.ColumnarReport, e columnarOptions, HTMLCSS string) error {
+
+	HTMLCSS, err := utils.GetCSS(HTMLCSS, columnarStyleSheet)
+	if err != nil {
+		return err
+	}
+
+	b.Printf("<html><head><style>\n%s\n</style></head><body>\n", HTMLCSS)
+	b.Printf("<table class=\"columnar\">\n")
+
+	b.Printf("  <thead><tr><th class=\"account\">Account</th>")
+	for _, h := range e.headers(r) {
+		b.Printf("<th>%s</th>", html.EscapeString(h))
+	}
+	b.Printf("</tr></thead>\n")
+
+	b.Printf("  <tbody>\n")
+	zero := &big.Rat{}
+	for _, row := range r.Rows {
+		b.Printf("    <tr class=\"indent%d\"><td class=\"account\">%s</td>", row.Level, html.EscapeString(row.Term))
+		for _, amt := range e.amounts(row) {
+			if amt == nil {
+				b.Printf("<td></td>")
+				continue
+			}
+			sign := "neg"
+			if amt.Cmp(zero) >= 0 {
+				sign = "pos"
+			}
+			b.Printf("<td class=\"amount %s\">%s%s</td>", sign,
+				html.EscapeString(b.FormatSymbol(row.CCY)), b.FormatNumber(row.CCY, amt))
+		}
+		b.Printf("</tr>\n")
+	}
+	b.Printf("  </tbody>\n")
+
+	b.Printf("</table>\n")
+	b.Printf("</body></html>\n")
+
+	return nil
+}
+
+const columnarStyleSheet = `
+.columnar {
+	font-family: sans-serif;
+	border-collapse: collapse;
+	color: #00009f;
+}
+.columnar th {
+	text-align: right;
+	border-bottom: 1px solid black;
+	padding: 4px 8px;
+}
+.columnar td {
+	padding: 2px 8px;
+}
+.columnar .account {
+	text-align: left;
+}
+.columnar .amount {
+	text-align: right;
+	white-space: nowrap;
+}
+.columnar .neg {
+	color: #9f0000;
+}
+.columnar tr.indent0 {
+	font-weight: 900;
+	background: #e4f2f7;
+}
+.columnar tr.indent1 td.account { padding-left: 24px; }
+.columnar tr.indent2 td.account { padding-left: 40px; }
+.columnar tr.indent3 td.account { padding-left: 56px; }
+.columnar tr.indent4 td.account { padding-left: 72px; }
+`
@@ -33,16 +33,11 @@ func indentedString(c int) string {
 	return indents[c]
 }
 
-func ShowHTMLTransactions(b *app.BookPrinter, trans []book.Transaction, HTMLCSS string) error {
-
-	if HTMLCSS == "" {
-		HTMLCSS = styleSheet
-	} else {
-		var err error
-		HTMLCSS, err = utils.GetFileOrStr(HTMLCSS)
-		if err != nil {
-			return err
-		}
+func ShowHTMLTransactions(b *app.BookPrinter, trans []book.Transaction, HTMLCSS string, by string) error {
+
+	HTMLCSS, err := utils.GetCSS(HTMLCSS, styleSheet)
+	if err != nil {
+		return err
 	}
 
 	b.Printf("<html><head><style>\n%s\n</style></head><body>\n", HTMLCSS)
@@ -55,7 +50,7 @@ func ShowHTMLTransactions(b *app.BookPrinter, trans []book.Transaction, HTMLCSS
 		// Header
 		//
 		b.Printf("<div class=\"header\">\n")
-		b.Printf("  <div class=\"date\">%s</div>\n", posts.GetDate())
+		b.Printf("  <div class=\"date\">%s</div>\n", posts.GetDate().Label(by))
 		payee := posts.GetPayee()
 		if payee != "" {
 			b.Printf("  <div class=\"payee\">%s</div>\n", payee)
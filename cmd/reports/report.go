@@ -19,6 +19,10 @@ type TransactionReport struct {
 	Sum        bool
 	Type       string
 	Combineby  string
+	Columnar   bool
+	Total      bool
+	Average    bool
+	Change     bool
 }
 
 const (
@@ -56,7 +60,16 @@ Account Regexp:
   or Equity.
 
   This will leave just Asset and Liabilities.
-    
+
+Columnar:
+  With --columnar the summarised accounts are rows and each
+  period of --splitby (eg monthly) is a column, optionally
+  with the total, average and change from the previous period.
+
+  Example for monthly amounts since last year:
+  report --splitby monthly --columnar --total --average 'since=last year'
+
+  The Text, Json, CSV and HTML report types are supported.
 `
 
 func Add(cmd *cobra.Command, app *app.App, report *TransactionReport) {
@@ -72,7 +85,7 @@ func Add(cmd *cobra.Command, app *app.App, report *TransactionReport) {
 	// Set defaults
 	floorType := utils.NewEnum(&report.Combineby, append(book.FloorTypes, "skip"), "floorType")
 	ncmd.Flags().Var(floorType, "splitby", fmt.Sprintf("combine transactions by periodic date (values %s)", floorType.Values()))
-	reportType := utils.NewEnum(&report.Type, []string{"Text", "Ledger", "Json", "HTML", "Beancount"}, "reportType")
+	reportType := utils.NewEnum(&report.Type, []string{"Text", "Ledger", "Json", "HTML", "Beancount", "CSV"}, "reportType")
 	ncmd.Flags().Var(reportType, "type", fmt.Sprintf("report type (%s)", reportType.Values()))
 	ncmd.Flags().BoolVar(&report.Sum, "sum", report.Sum, "summarise transactions")
 	ncmd.Flags().BoolVar(&report.Convert, "convert", report.Convert, "convert to base currency")
@@ -80,6 +93,10 @@ func Add(cmd *cobra.Command, app *app.App, report *TransactionReport) {
 	ncmd.Flags().StringVar(&report.HTMLCSS, "htmlcss", report.HTMLCSS, "HTML CSS (string or file:<css file>) for HTML output (inlined in HTML)")
 	ncmd.Flags().StringVar(&report.Credit, "credit", report.Credit, "credit account regex for summary")
 	ncmd.Flags().StringVar(&report.Hidden, "hidden", report.Hidden, "hidden account in reports for summary")
+	ncmd.Flags().BoolVar(&report.Columnar, "columnar", report.Columnar, "summarise with accounts as rows and periods as columns")
+	ncmd.Flags().BoolVar(&report.Total, "total", report.Total, "total column for columnar")
+	ncmd.Flags().BoolVar(&report.Average, "average", report.Average, "average column for columnar")
+	ncmd.Flags().BoolVar(&report.Change, "change", report.Change, "change from previous period column for columnar")
 
 	// don't need to save it
 	macroNames := make([]string, 0, len(app.Macros))
@@ -94,6 +111,17 @@ func Add(cmd *cobra.Command, app *app.App, report *TransactionReport) {
 
 func (report *TransactionReport) run(app *app.App, cmd *cobra.Command, args []string) error {
 
+	if report.Columnar && (report.Type == "Ledger" || report.Type == "Beancount") {
+		return fmt.Errorf("columnar report not supported for type %s", report.Type)
+	}
+	if !report.Columnar && report.Type == "CSV" {
+		return fmt.Errorf("CSV report type requires --columnar")
+	}
+	by, err := app.Period(report.Combineby)
+	if err != nil {
+		return err
+	}
+
 	// Load up saved flags
 	b, err := app.LoadBook()
 	if err != nil {
@@ -107,8 +135,8 @@ func (report *TransactionReport) run(app *app.App, cmd *cobra.Command, args []st
 	}
 
 	// Always combine for reports
-	if report.Type != "Beancount" && report.Combineby != "skip" {
-		b.SplitBy(report.Combineby)
+	if report.Type != "Beancount" && by != "skip" {
+		b.SplitBy(by)
 	}
 
 	if report.Type != "Beancount" && report.Convert {
@@ -130,7 +158,7 @@ func (report *TransactionReport) run(app *app.App, cmd *cobra.Command, args []st
 	}
 
 	var trans []book.Transaction
-	if report.Type != "Beancount" && report.Sum {
+	if report.Type != "Beancount" && (report.Sum || report.Columnar) {
 		if app.BaseCCY == "" {
 			return fmt.Errorf("unable to convert -- no CCY specified")
 		}
@@ -141,16 +169,46 @@ func (report *TransactionReport) run(app *app.App, cmd *cobra.Command, args []st
 
 	bp := app.NewBookPrinter(b.GetCCYDecimals())
 
+	if report.Columnar {
+		return report.showColumnar(bp, trans, by)
+	}
+
 	// Need type of report now..
 	if report.Type == "Text" {
-		return ShowTransactions(bp, trans)
+		return ShowTransactions(bp, trans, by)
 	} else if report.Type == "Json" {
 		return bp.PrintJSON(trans, report.JsonPretty)
 	} else if report.Type == "HTML" {
-		return ShowHTMLTransactions(bp, trans, report.HTMLCSS)
+		return ShowHTMLTransactions(bp, trans, report.HTMLCSS, by)
 	} else if report.Type == "Beancount" {
 		return ShowBeancount(bp, trans)
 	} else {
 		return ShowLedger(bp, trans)
 	}
 }
+
+func (report *TransactionReport) showColumnar(bp *app.BookPrinter, trans []book.Transaction, by string) error {
+	r, err := book.Columnar(trans)
+	if err != nil {
+		return err
+	}
+
+	extras := columnarOptions{
+		By:      by,
+		Total:   report.Total,
+		Average: report.Average,
+		Change:  report.Change,
+	}
+
+	if report.Type == "Text" {
+		return ShowColumnarTransactions(bp, r, extras)
+	} else if report.Type == "Json" {
+		return bp.PrintJSON(r, report.JsonPretty)
+	} else if report.Type == "CSV" {
+		return ShowCSVColumnarTransactions(bp, r, extras)
+	} else if report.Type == "HTML" {
+		return ShowHTMLColumnarTransactions(bp, r, extras, report.HTMLCSS)
+	} else {
+		return fmt.Errorf("columnar report not supported for type %s", report.Type)
+	}
+}
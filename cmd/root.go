@@ -4,13 +4,22 @@ import (
 	"fmt"
 	"github.com/mescanne/goledger/cmd/accounts"
 	"github.com/mescanne/goledger/cmd/app"
+	"github.com/mescanne/goledger/cmd/chart"
+	"github.com/mescanne/goledger/cmd/check"
+	"github.com/mescanne/goledger/cmd/closing"
 	"github.com/mescanne/goledger/cmd/currencies"
 	"github.com/mescanne/goledger/cmd/download"
 	"github.com/mescanne/goledger/cmd/export"
 	"github.com/mescanne/goledger/cmd/generate"
 	"github.com/mescanne/goledger/cmd/importer"
+	"github.com/mescanne/goledger/cmd/loan"
+	"github.com/mescanne/goledger/cmd/prices"
+	"github.com/mescanne/goledger/cmd/reconcile"
 	"github.com/mescanne/goledger/cmd/register"
 	"github.com/mescanne/goledger/cmd/reports"
+	"github.com/mescanne/goledger/cmd/schedule"
+	"github.com/mescanne/goledger/cmd/search"
+	"github.com/mescanne/goledger/cmd/settle"
 	"github.com/mescanne/goledger/cmd/utils"
 	// "github.com/mescanne/goledger/cmd/web"
 	"github.com/spf13/cobra"
@@ -20,10 +29,17 @@ import (
 type Config struct {
 	app.App
 	Report     reports.TransactionReport
+	CashFlow   reports.CashFlowReport
 	Register   register.RegisterReport
 	ImportDefs map[string]*importer.ImportDef
 	Generate   map[string]*generate.Generate
 	Download   download.Download
+	Close      closing.CloseConfig
+	Settle     settle.SettleConfig
+	Chart      chart.ChartConfig
+	Accounts   accounts.AccountsConfig
+	Check      check.CheckConfig
+	Loans      map[string]*loan.LoanConfig
 	// Web        web.WebConfig
 	Export export.ExportReport
 }
@@ -31,7 +47,8 @@ type Config struct {
 // Execute command line program
 func Execute() error {
 	app := &Config{
-		App: app.DefaultApp,
+		App:      app.DefaultApp,
+		Accounts: accounts.DefaultAccounts,
 	}
 
 	// Load configuration
@@ -51,12 +68,22 @@ func Execute() error {
 	})
 
 	// Add sub-commands
-	accounts.Add(appCmd, &app.App)
+	accounts.Add(appCmd, &app.App, &app.Accounts)
 	reports.Add(appCmd, &app.App, &app.Report)
+	reports.AddCashFlow(appCmd, &app.App, &app.CashFlow)
 	register.Add(appCmd, &app.App, &app.Register)
 	importer.Add(appCmd, &app.App, app.ImportDefs)
 	generate.Add(appCmd, &app.App, app.Generate)
 	currencies.Add(appCmd, &app.App)
+	prices.Add(appCmd, &app.App)
+	reconcile.Add(appCmd, &app.App)
+	closing.Add(appCmd, &app.App, &app.Close)
+	settle.Add(appCmd, &app.App, &app.Settle)
+	schedule.Add(appCmd, &app.App)
+	search.Add(appCmd, &app.App)
+	chart.Add(appCmd, &app.App, &app.Chart)
+	check.Add(appCmd, &app.App, &app.Check)
+	loan.Add(appCmd, &app.App, app.Loans)
 	export.Add(appCmd, &app.App, &app.Export)
 	download.Add(appCmd, &app.Download)
 	utils.AddShell(appCmd)
@@ -0,0 +1,90 @@
+package schedule
+
+import (
+	"fmt"
+	"github.com/mescanne/goledger/book"
+	"github.com/mescanne/goledger/cmd/app"
+	"github.com/spf13/cobra"
+	"math/big"
+)
+
+const schedule_long = `Show a depreciation or amortisation plan
+
+Shows the periods generated by a depreciate= or amortise= operation (see
+help ops) for each matching posting, with the amount moved back from the
+holding account each period and the amount remaining.
+
+Any further operations (eg map=) are applied before the schedule.
+
+Example:
+  schedule 'amortise=/^Expense:Insurance$/Asset:Prepaid/12/'
+`
+
+func Add(root *cobra.Command, app *app.App) {
+	ncmd := &cobra.Command{
+		Use:               "schedule <depreciate=|amortise=> [macros|ops...]",
+		Short:             "Show a depreciation or amortisation plan",
+		Long:              schedule_long,
+		Args:              cobra.MinimumNArgs(1),
+		DisableAutoGenTag: true,
+	}
+	ncmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return run(app, args[0], args[1:])
+	}
+	root.AddCommand(ncmd)
+}
+
+func run(app *app.App, op string, args []string) error {
+	search, holding, sched, err := app.ParseScheduleOp(op)
+	if err != nil {
+		return err
+	}
+
+	b, err := app.LoadBook()
+	if err != nil {
+		return err
+	}
+	if err = app.BookOps(b, args...); err != nil {
+		return err
+	}
+
+	scheduled := b.Schedules(search, holding, sched)
+	if len(scheduled) == 0 {
+		return fmt.Errorf("no postings match '%s'", search)
+	}
+
+	showSchedules(app.NewBookPrinter(b.GetCCYDecimals()), scheduled)
+	return nil
+}
+
+func showSchedules(bp *app.BookPrinter, scheduled []book.ScheduledPosting) {
+	rows := make([][]app.ColumnValue, 0)
+	rows = append(rows, []app.ColumnValue{
+		app.ColumnString(bp.Ansi(app.UL, "Posting")),
+		app.ColumnString(bp.Ansi(app.UL, "Holding")),
+		app.ColumnString(bp.Ansi(app.UL, "Date")),
+		app.ColumnRightString(bp.Ansi(app.UL, "Amount")),
+		app.ColumnRightString(bp.Ansi(app.UL, "Remaining")),
+	})
+	for _, sp := range scheduled {
+		remaining := new(big.Rat).Set(sp.GetAmount())
+		rows = append(rows, []app.ColumnValue{
+			app.ColumnString(fmt.Sprintf("%s %s %s", sp.GetDate(), sp.GetPayee(), sp.GetAccount())),
+			app.ColumnString(sp.Holding),
+			app.ColumnString(""),
+			app.ColumnString(""),
+			bp.GetColumnMoney(sp.GetCCY(), new(big.Rat).Set(remaining)),
+		})
+		for _, e := range sp.Entries {
+			remaining.Sub(remaining, e.Amount)
+			rows = append(rows, []app.ColumnValue{
+				app.ColumnString(""),
+				app.ColumnString(""),
+				app.ColumnString(e.Date.String()),
+				bp.GetColumnMoney(sp.GetCCY(), e.Amount),
+				bp.GetColumnMoney(sp.GetCCY(), new(big.Rat).Set(remaining)),
+			})
+		}
+	}
+	bp.PrintColumns(rows, []bool{true, true, false, false, false})
+}
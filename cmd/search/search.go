@@ -0,0 +1,173 @@
+package search
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/antzucaro/matchr"
+	"github.com/mescanne/goledger/book"
+	"github.com/mescanne/goledger/cmd/app"
+	"github.com/spf13/cobra"
+	"regexp"
+	"strings"
+)
+
+const search_long = `Search transactions
+
+Search the payees, transaction notes, posting notes, accounts and dates of
+transactions for the terms, ignoring case. Transactions with the terms as a
+phrase are first, then those with some of the terms as words, then those with
+words similar to every term (eg misspellings), and the most recent first.
+
+Each transaction is shown with a posting: the one matched, or the largest if
+the payee, note or date matched, and the file and line it was loaded from.
+
+Example:
+
+  search --begin 2019-01-01 --asof 2020-01-01 plumber
+`
+
+// Minimum similarity of the terms for a fuzzy match
+const defaultSimilarity = 0.85
+
+func Add(root *cobra.Command, rapp *app.App) {
+	ncmd := &cobra.Command{
+		Use:               "search <terms...>",
+		Short:             "Search transactions",
+		Long:              search_long,
+		DisableAutoGenTag: true,
+	}
+	ncmd.Args = cobra.MinimumNArgs(1)
+
+	var beginDate, endDate, acct string
+	var count int
+	var useJson bool
+	similarity := defaultSimilarity
+	ncmd.Flags().StringVar(&beginDate, "begin", "", "begin date")
+	ncmd.Flags().StringVar(&endDate, "asof", "", "end date")
+	ncmd.Flags().StringVar(&acct, "account", "", "only transactions with postings to accounts matching regex")
+	ncmd.Flags().IntVar(&count, "count", 20, "count of results (0 = no limit)")
+	ncmd.Flags().Float64Var(&similarity, "similarity", similarity, "minimum similarity of terms to words (0.0 to 1.0) for fuzzy matches")
+	ncmd.Flags().BoolVar(&useJson, "json", false, "show results using json")
+
+	ncmd.RunE = func(cmd *cobra.Command, args []string) error {
+		b, err := rapp.LoadBook()
+		if err != nil {
+			return err
+		}
+
+		if beginDate != "" {
+			date, err := rapp.ParseDate(beginDate)
+			if err != nil {
+				return fmt.Errorf("begin date: %w", err)
+			}
+			b.FilterByDateSince(date)
+		}
+		if endDate != "" {
+			date, err := rapp.ParseDate(endDate)
+			if err != nil {
+				return fmt.Errorf("asof date: %w", err)
+			}
+			b.FilterByDateAsof(date)
+		}
+		if acct != "" {
+			re, err := regexp.Compile(acct)
+			if err != nil {
+				return fmt.Errorf("invalid regex: '%s': %w", acct, err)
+			}
+			b.FilterTransaction(func(date book.Date, payee string, posts book.Transaction) bool {
+				for _, p := range posts {
+					if re.MatchString(p.GetAccount()) {
+						return true
+					}
+				}
+				return false
+			})
+		}
+
+		results := b.Search(strings.Join(args, " "), func(a, b string) float64 {
+			return matchr.JaroWinkler(strings.ToUpper(a), strings.ToUpper(b), true)
+		}, similarity)
+		if count > 0 && len(results) > count {
+			results = results[0:count]
+		}
+
+		bp := rapp.NewBookPrinter(b.GetCCYDecimals())
+		if useJson {
+			return bp.PrintJSON(searchResults(results), true)
+		}
+		return showResults(bp, results)
+	}
+
+	root.AddCommand(ncmd)
+}
+
+// Source of the posting as file:line, or empty if not loaded from a file
+func source(p *book.Posting) string {
+	file, line := p.GetSource()
+	if file == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s:%d", file, line)
+}
+
+func showResults(b *app.BookPrinter, results []*book.SearchResult) error {
+	rows := make([][]app.ColumnValue, 0, len(results)+1)
+	rows = append(rows, []app.ColumnValue{
+		app.ColumnString(b.Ansi(app.UL, "Date")),
+		app.ColumnString(b.Ansi(app.UL, "Payee")),
+		app.ColumnString(b.Ansi(app.UL, "Account")),
+		app.ColumnRightString(b.Ansi(app.UL, "Amount")),
+		app.ColumnString(b.Ansi(app.UL, "Source")),
+	})
+	for _, r := range results {
+		rows = append(rows, []app.ColumnValue{
+			app.ColumnString(r.Transaction.GetDate().String()),
+			app.ColumnString(r.Transaction.GetPayee()),
+			app.ColumnString(r.Posting.GetAccount()),
+			b.GetColumnMoney(r.Posting.GetCCY(), r.Posting.GetAmount()),
+			app.ColumnString(source(r.Posting)),
+		})
+	}
+	b.PrintColumns(rows, []bool{false, true, true, false, true})
+	return nil
+}
+
+type searchResults []*book.SearchResult
+
+func (results searchResults) MarshalJSON() ([]byte, error) {
+
+	type JsonSearchResult struct {
+		Date    book.Date `json:"date"`
+		Payee   string    `json:"payee"`
+		TNote   string    `json:"tnote,omitempty"`
+		Account string    `json:"account"`
+		Note    string    `json:"note,omitempty"`
+		Amount  float64   `json:"amount"`
+		CCY     string    `json:"ccy"`
+		File    string    `json:"file,omitempty"`
+		Line    int       `json:"line,omitempty"`
+		Match   string    `json:"match"`
+		Score   float64   `json:"score"`
+	}
+
+	data := make([]*JsonSearchResult, 0, len(results))
+	for _, r := range results {
+		amt, _ := r.Posting.GetAmount().Float64()
+		file, line := r.Posting.GetSource()
+		data = append(data, &JsonSearchResult{
+			Date:    r.Transaction.GetDate(),
+			Payee:   r.Transaction.GetPayee(),
+			TNote:   r.Transaction.GetTransactionNote(),
+			Account: r.Posting.GetAccount(),
+			Note:    r.Posting.GetPostNote(),
+			Amount:  amt,
+			CCY:     r.Posting.GetCCY(),
+			File:    file,
+			Line:    line,
+			Match:   r.Match.String(),
+			Score:   r.Score,
+		})
+	}
+
+	return json.Marshal(data)
+}
@@ -0,0 +1,135 @@
+package settle
+
+import (
+	"fmt"
+	"github.com/mescanne/goledger/book"
+	"github.com/mescanne/goledger/cmd/app"
+	"github.com/spf13/cobra"
+	"math/big"
+	"regexp"
+)
+
+// Configuration for settling balances between people
+type SettleConfig struct {
+	Accounts string // Accounts regex, first captured group is the person
+	Self     string // Name for the owner of the book
+}
+
+// Default configuration if none specified
+var DefaultSettle SettleConfig = SettleConfig{
+	Accounts: "^(?:Asset:Receivable|Liability:Payable):([^:]+)",
+	Self:     "Me",
+}
+
+const settle_long = `Settle balances between people
+
+Nets the balances of the accounts for each person and shows the smallest set
+of transfers needed to settle up. With more than 16 people in a currency the
+transfers are matched greedily, needing at most one less than the number of
+people.
+
+The first captured group of the accounts regex is the person (or the whole
+account if there is none). A positive balance is owed by the person, such as
+a receivable, and a negative balance is owed to the person, such as a payable.
+The owner of the book (self) takes the opposite of the total balance.
+
+Shared expenses can be recorded using the split= operation, eg:
+
+  split=/^Expense:Rent$/Asset:Receivable:Alice=40%/
+
+Operations (eg asof=) are applied before settling.
+`
+
+func Add(root *cobra.Command, app *app.App, cfg *SettleConfig) {
+	if cfg.Accounts == "" {
+		cfg.Accounts = DefaultSettle.Accounts
+	}
+	if cfg.Self == "" {
+		cfg.Self = DefaultSettle.Self
+	}
+
+	var asJSON bool
+	ncmd := &cobra.Command{
+		Use:               "settle [macros|ops...]",
+		Short:             "Show the transfers to settle balances between people",
+		Long:              settle_long,
+		DisableAutoGenTag: true,
+	}
+	ncmd.Flags().StringVar(&cfg.Accounts, "accounts", cfg.Accounts, "accounts regex (first captured group is the person)")
+	ncmd.Flags().StringVar(&cfg.Self, "self", cfg.Self, "name for the owner of the book")
+	ncmd.Flags().BoolVar(&asJSON, "json", false, "output as JSON")
+	ncmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return cfg.run(app, asJSON, args)
+	}
+	root.AddCommand(ncmd)
+}
+
+func (cfg *SettleConfig) run(app *app.App, asJSON bool, args []string) error {
+	re, err := regexp.Compile(cfg.Accounts)
+	if err != nil {
+		return fmt.Errorf("failed compiling accounts '%s': %w", cfg.Accounts, err)
+	}
+
+	b, err := app.LoadBook()
+	if err != nil {
+		return err
+	}
+	if err = app.BookOps(b, args...); err != nil {
+		return err
+	}
+
+	// Balances by person, with self taking the opposite of the total
+	balances := make(map[string]map[string]*big.Rat)
+	add := func(name string, ccy string, amt *big.Rat) {
+		bals, ok := balances[name]
+		if !ok {
+			bals = make(map[string]*big.Rat)
+			balances[name] = bals
+		}
+		bal, ok := bals[ccy]
+		if !ok {
+			bal = new(big.Rat)
+			bals[ccy] = bal
+		}
+		bal.Add(bal, amt)
+	}
+	for _, ab := range b.AccountBalances(re, 0) {
+		name := ab.Account
+		if m := re.FindStringSubmatch(ab.Account); len(m) > 1 && m[1] != "" {
+			name = m[1]
+		}
+		add(name, ab.CCY, ab.Balance)
+		add(cfg.Self, ab.CCY, new(big.Rat).Neg(ab.Balance))
+	}
+
+	transfers := book.Settle(balances)
+
+	bp := app.NewBookPrinter(b.GetCCYDecimals())
+	if asJSON {
+		return bp.PrintJSON(transfers, true)
+	}
+	showTransfers(bp, transfers)
+	return nil
+}
+
+func showTransfers(bp *app.BookPrinter, transfers []book.Transfer) {
+	if len(transfers) == 0 {
+		bp.Printf("All settled\n")
+		return
+	}
+
+	rows := make([][]app.ColumnValue, 0, len(transfers)+1)
+	rows = append(rows, []app.ColumnValue{
+		app.ColumnString(bp.Ansi(app.UL, "From")),
+		app.ColumnString(bp.Ansi(app.UL, "To")),
+		app.ColumnRightString(bp.Ansi(app.UL, "Amount")),
+	})
+	for _, t := range transfers {
+		rows = append(rows, []app.ColumnValue{
+			app.ColumnString(t.From),
+			app.ColumnString(t.To),
+			bp.GetColumnMoney(t.CCY, t.Amount),
+		})
+	}
+	bp.PrintColumns(rows, []bool{true, true, false})
+}
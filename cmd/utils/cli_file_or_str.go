@@ -22,3 +22,12 @@ func GetFileOrStr(str string) (string, error) {
 
 	return string(b), nil
 }
+
+// Get the CSS for HTML output: defaultCSS if css is empty, otherwise css as a
+// string or file (see GetFileOrStr)
+func GetCSS(css string, defaultCSS string) (string, error) {
+	if css == "" {
+		return defaultCSS, nil
+	}
+	return GetFileOrStr(css)
+}
@@ -0,0 +1,71 @@
+package loader
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Mark postings as cleared in a ledger file by inserting a cleared (*)
+// marker at the start of each of the posting lines.
+//
+// Lines are 1-based. Lines already marked cleared are left as they are, and
+// a pending (!) marker is replaced.
+func MarkCleared(filename string, lines []int) error {
+	info, err := os.Stat(filename)
+	if err != nil {
+		return err
+	}
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	content := strings.Split(string(b), "\n")
+	for _, line := range lines {
+		if line < 1 || line > len(content) {
+			return fmt.Errorf("%s: line %d out of range", filename, line)
+		}
+
+		l := content[line-1]
+		posting := strings.TrimLeft(l, " \t")
+		indent := l[:len(l)-len(posting)]
+		if indent == "" || posting == "" {
+			return fmt.Errorf("%s:%d: not a posting: '%s'", filename, line, l)
+		}
+		if strings.HasPrefix(posting, "*") {
+			continue
+		}
+		if strings.HasPrefix(posting, "!") {
+			posting = strings.TrimLeft(posting[1:], " \t")
+		}
+		content[line-1] = indent + "* " + posting
+	}
+
+	return replaceFile(filename, []byte(strings.Join(content, "\n")), info.Mode())
+}
+
+// Replace the file with data by writing a temporary file in the same directory
+// and renaming it over the file, so the file is never left partly written.
+func replaceFile(filename string, data []byte, mode os.FileMode) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(filename), "."+filepath.Base(filename)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filename)
+}
@@ -20,10 +20,14 @@ type TransactionLoader interface {
 	NewTransaction(date book.Date, payee string, note string)
 	// Add a posting to the current transaction
 	AddPosting(acct string, ccy string, amt *big.Rat, note string)
+	// Set the source file and line for subsequent postings
+	SetSource(file string, line int)
+	// Set the cleared status for subsequent postings in the current transaction
+	SetCleared(cleared bool)
 	// Get the current transaction open balances (useful for implicit values)
 	GetLastCCYBals() map[string]*big.Rat
 	// Add a price for a unit (share price, currency, etc)
-	AddPrice(date book.Date, unit string, ccy string, val *big.Rat)
+	PriceLoader
 }
 
 type runeReader struct {
@@ -62,19 +66,28 @@ func (rr *basicReader) parseNote() string {
 	return buf.String()
 }
 
-func (rr *basicReader) parseTransaction() (book.Date, string, string) {
+func (rr *basicReader) parseTransaction() (book.Date, string, string, bool) {
 	date := rr.parseDate()
 	_ = rr.consumeWS()
-	if rr.ch == '*' {
-		rr.next()
-		_ = rr.consumeWS()
-	}
+	cleared := rr.parseCleared()
 	payee := rr.parsePayee()
 	note := rr.parseNote()
 	if rr.ch == eol {
 		rr.next()
 	}
-	return date, payee, note
+	return date, payee, note, cleared
+}
+
+// Parse an optional cleared (*) or pending (!) marker, returning
+// true if it is cleared
+func (rr *basicReader) parseCleared() bool {
+	if rr.ch != '*' && rr.ch != '!' {
+		return false
+	}
+	cleared := rr.ch == '*'
+	rr.next()
+	_ = rr.consumeWS()
+	return cleared
 }
 
 func (rr *basicReader) parseToEOL() string {
@@ -168,7 +181,7 @@ func (rr *basicReader) skipLine() {
 	}
 }
 
-func (rr *basicReader) parsePrice(loader TransactionLoader) {
+func (rr *basicReader) parsePrice(loader PriceLoader) {
 	if rr.ch != 'P' {
 		rr.stop("expected 'P', got '%c'", rr.ch)
 	}
@@ -221,11 +234,13 @@ func parseFileLocal(loader TransactionLoader, filename string, alias map[string]
 		}
 	}
 
+	cleared := false
 	rr := newRuneReader(bufio.NewReader(file))
 	for rr.ch != eof {
 
 		// Move forward to first non-whitespace
 		ws := rr.consumeWS()
+		loader.SetSource(filename, rr.row)
 
 		// Skip comment, eof
 		if rr.ch == ';' || rr.ch == eol || rr.ch == eof {
@@ -249,8 +264,9 @@ func parseFileLocal(loader TransactionLoader, filename string, alias map[string]
 
 			// Digit -- parse transaction
 			if rr.ch >= '0' && rr.ch <= '9' {
-				date, payee, note := rr.parseTransaction()
+				date, payee, note, tcleared := rr.parseTransaction()
 				loader.NewTransaction(book.Date(date), payee, note)
+				cleared = tcleared
 				continue
 			}
 
@@ -288,14 +304,15 @@ func parseFileLocal(loader TransactionLoader, filename string, alias map[string]
 		}
 
 		// indented means posting!
-		rr.parsePosting(loader, nmap)
+		rr.parsePosting(loader, nmap, cleared)
 	}
 
 	return
 }
 
-func (rr *basicReader) parsePosting(loader TransactionLoader, alias map[string]string) {
+func (rr *basicReader) parsePosting(loader TransactionLoader, alias map[string]string, cleared bool) {
 	_ = rr.consumeWS()
+	loader.SetCleared(rr.parseCleared() || cleared)
 	acct := rr.parseAccount()
 	nacct, ok := alias[acct]
 	if ok {
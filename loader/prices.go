@@ -0,0 +1,103 @@
+package loader
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"github.com/mescanne/goledger/book"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// PriceLoader - receives the prices from a parsed price file.
+type PriceLoader interface {
+	// Add a price for a unit (share price, currency, etc)
+	AddPrice(date book.Date, unit string, ccy string, val *big.Rat)
+}
+
+// Parse a price database file and load the prices into the PriceLoader.
+//
+// Files with a .csv extension are read as CSV with the columns date (YYYY-MM-DD
+// or DD-MM-YYYY), unit, ccy, and price (an optional header row is skipped). All other files are
+// read as ledger P lines.
+func ParsePriceFile(loader PriceLoader, filename string) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if strings.EqualFold(filepath.Ext(filename), ".csv") {
+		err = parsePriceCSV(loader, file)
+	} else {
+		err = parsePriceLedger(loader, file)
+	}
+	if err != nil {
+		return fmt.Errorf("parsing failure: %s: %w", filename, err)
+	}
+
+	return nil
+}
+
+func parsePriceLedger(loader PriceLoader, r io.Reader) (reterr error) {
+	defer func() {
+		if msg := recover(); msg != nil {
+			reterr = fmt.Errorf("%s", msg)
+		}
+	}()
+
+	rr := newRuneReader(bufio.NewReader(r))
+	for rr.ch != eof {
+		_ = rr.consumeWS()
+
+		// Skip comment, blank lines
+		if rr.ch == ';' || rr.ch == '#' || rr.ch == eol || rr.ch == eof {
+			rr.skipLine()
+			continue
+		}
+
+		rr.parsePrice(loader)
+	}
+
+	return nil
+}
+
+func parsePriceCSV(loader PriceLoader, r io.Reader) error {
+	csvr := csv.NewReader(r)
+	csvr.TrimLeadingSpace = true
+	csvr.FieldsPerRecord = 4
+	csvr.Comment = '#'
+
+	row := 0
+	for {
+		rec, err := csvr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		row++
+
+		date, err := book.ParseAbsoluteDate(rec[0])
+		if err != nil {
+			// Header row
+			if row == 1 {
+				continue
+			}
+			return fmt.Errorf("row %d: %w", row, err)
+		}
+
+		val, ok := big.NewRat(0, 1).SetString(strings.ReplaceAll(rec[3], ",", ""))
+		if !ok {
+			return fmt.Errorf("row %d: invalid price '%s'", row, rec[3])
+		}
+
+		loader.AddPrice(date, rec[1], rec[2], val)
+	}
+
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"github.com/mescanne/goledger/book"
 	"go.starlark.net/starlark"
+	"math/big"
 )
 
 type starlarkPosting book.Posting
@@ -138,3 +139,80 @@ func (s starlarkTransactions) Truth() starlark.Bool {
 func (s starlarkTransactions) Hash() (uint32, error) {
 	return 0, fmt.Errorf("cannot hash transactions")
 }
+
+type starlarkAccount struct {
+	n *book.AccountNode
+}
+
+func balancesToStarlark(bals map[string]*big.Rat) *starlark.Dict {
+	d := starlark.NewDict(len(bals))
+	for ccy, v := range bals {
+		f, _ := v.Float64()
+		d.SetKey(starlark.String(ccy), starlark.Float(f))
+	}
+	return d
+}
+
+func (s starlarkAccount) Attr(name string) (starlark.Value, error) {
+	switch name {
+	case "account":
+		return starlark.String(s.n.Account), nil
+	case "name":
+		return starlark.String(s.n.Name), nil
+	case "depth":
+		return starlark.MakeInt(s.n.Depth), nil
+	case "parent":
+		if s.n.Parent == nil {
+			return starlark.None, nil
+		}
+		return starlarkAccount{s.n.Parent}, nil
+	case "children":
+		children := make([]starlark.Value, 0, len(s.n.Children))
+		for _, c := range s.n.Children {
+			children = append(children, starlarkAccount{c})
+		}
+		return starlark.NewList(children), nil
+	case "balance":
+		return balancesToStarlark(s.n.Balance), nil
+	case "total":
+		return balancesToStarlark(s.n.Total), nil
+	case "postings":
+		return starlark.MakeInt(s.n.Postings), nil
+	case "transactions":
+		return starlark.MakeInt(s.n.Transactions), nil
+	case "first":
+		return starlark.String(s.n.First.String()), nil
+	case "last":
+		return starlark.String(s.n.Last.String()), nil
+	default:
+		return nil, nil
+	}
+}
+func (s starlarkAccount) AttrNames() []string {
+	return []string{
+		"account", "name", "depth", "parent", "children",
+		"balance", "total", "postings", "transactions", "first", "last",
+	}
+}
+func (s starlarkAccount) Iterate() starlark.Iterator {
+	return &starlarkIterator{s, 0}
+}
+func (s starlarkAccount) Index(i int) starlark.Value {
+	return starlarkAccount{s.n.Children[i]}
+}
+func (s starlarkAccount) Len() int {
+	return len(s.n.Children)
+}
+func (s starlarkAccount) String() string {
+	return fmt.Sprintf("account[%s]", s.n.Account)
+}
+func (s starlarkAccount) Type() string {
+	return "account"
+}
+func (s starlarkAccount) Freeze() {}
+func (s starlarkAccount) Truth() starlark.Bool {
+	return true
+}
+func (s starlarkAccount) Hash() (uint32, error) {
+	return 0, fmt.Errorf("cannot hash account")
+}
@@ -41,9 +41,9 @@ var asoff = starlark.NewBuiltin("asof",
 		if !ok {
 			return starlark.None, fmt.Errorf("%w: %s", userError, "bound to not a book")
 		}
-		dstr := book.DateFromString(date)
-		if dstr == book.Date(0) {
-			return starlark.None, fmt.Errorf("invalid date: '%s'", date)
+		dstr, err := book.ParseDate(date)
+		if err != nil {
+			return starlark.None, err
 		}
 		bb.b.FilterByDateAsof(dstr)
 		return val, nil
@@ -63,17 +63,18 @@ var sincef = starlark.NewBuiltin("since",
 		if !ok {
 			return starlark.None, fmt.Errorf("%w: %s", userError, "bound to not a book")
 		}
-		dstr := book.DateFromString(date)
-		if dstr == book.Date(0) {
-			return starlark.None, fmt.Errorf("invalid date: '%s'", date)
+		dstr, err := book.ParseDate(date)
+		if err != nil {
+			return starlark.None, err
 		}
 		bb.b.FilterByDateSince(dstr)
 		return val, nil
 	})
 
 type starlarkBook struct {
-	b      *book.Book
-	frozen bool
+	b       *book.Book
+	divider string
+	frozen  bool
 }
 
 func (s *starlarkBook) Attr(name string) (starlark.Value, error) {
@@ -82,6 +83,8 @@ func (s *starlarkBook) Attr(name string) (starlark.Value, error) {
 	switch name {
 	case "transactions":
 		return starlarkTransactions(s.b.Transactions()), nil
+	case "accounts":
+		return starlarkAccount{s.b.AccountTree(s.divider, "").Root}, nil
 	}
 
 	if s.frozen {
@@ -103,6 +106,7 @@ func (s *starlarkBook) Attr(name string) (starlark.Value, error) {
 func (s *starlarkBook) AttrNames() []string {
 	return []string{
 		"transactions",
+		"accounts",
 		"since",
 		"asof",
 		"map",
@@ -122,9 +126,11 @@ func (s *starlarkBook) Hash() (uint32, error) {
 	return 0, fmt.Errorf("cannot hash book")
 }
 
-func ConvertBookToStarlark(main *book.Book) starlark.Value {
+// Convert the book for Starlark, with accounts split into a tree by divider
+func ConvertBookToStarlark(main *book.Book, divider string) starlark.Value {
 	return &starlarkBook{
-		b:      main,
-		frozen: false,
+		b:       main,
+		divider: divider,
+		frozen:  false,
 	}
 }
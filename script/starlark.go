@@ -61,9 +61,9 @@ func GetBuilderFunction(bbuilder *book.Builder, account string, ccy string, coun
 		}
 
 		// Convert the date
-		ndate := book.DateFromString(string(date))
-		if ndate == book.Date(0) {
-			return nil, fmt.Errorf("invalid date %s", date)
+		ndate, err := book.ParseDate(string(date))
+		if err != nil {
+			return nil, err
 		}
 
 		// Convert the amount